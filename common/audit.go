@@ -0,0 +1,151 @@
+package common
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+const auditRedacted = "***"
+
+// auditSecretSuffixes mark the fields whose values never reach the audit log
+var auditSecretSuffixes = []string{"key", "secret", "token", "password", "code"}
+
+// auditSecretFields never reach the audit log either, header overrides often carry gateway tokens
+// and param overrides may as well
+var auditSecretFields = []string{"headeroverride", "paramoverride"}
+
+// auditUrlFields are logged without the credentials a url may carry
+var auditUrlFields = []string{"proxy"}
+
+func normalizeAuditField(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "")
+}
+
+// IsAuditSecretField reports whether the field, in snake or camel case, holds a secret
+func IsAuditSecretField(name string) bool {
+	name = normalizeAuditField(name)
+	for _, suffix := range auditSecretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return StringsContains(auditSecretFields, name)
+}
+
+// redactAuditUrls removes the user and password from the urls in the value, which is a url or the
+// before and after of one, a url that cannot be parsed is redacted
+func redactAuditUrls(v any) any {
+	switch value := v.(type) {
+	case string:
+		if value == "" {
+			return value
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.User == nil && strings.Contains(value, "@")) {
+			return auditRedacted
+		}
+		if u.User != nil {
+			u.User = nil
+			return strings.Replace(u.String(), "://", "://"+auditRedacted+"@", 1)
+		}
+		return u.String()
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for k, item := range value {
+			redacted[k] = redactAuditUrls(item)
+		}
+		return redacted
+	}
+	return v
+}
+
+// RedactAuditValue replaces the values of secret fields, recursing into objects and arrays
+func RedactAuditValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for k, item := range value {
+			if IsAuditSecretField(k) && item != nil && item != "" {
+				redacted[k] = auditRedacted
+			} else if StringsContains(auditUrlFields, normalizeAuditField(k)) {
+				redacted[k] = redactAuditUrls(item)
+			} else {
+				redacted[k] = RedactAuditValue(item)
+			}
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = RedactAuditValue(item)
+		}
+		return redacted
+	}
+	return v
+}
+
+func toAuditValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	var data []byte
+	switch value := v.(type) {
+	case []byte:
+		data = value
+	default:
+		var err error
+		data, err = json.Marshal(value)
+		if err != nil {
+			return nil
+		}
+	}
+	var result any
+	if json.Unmarshal(data, &result) != nil {
+		// bodies that are not json may hold secrets the redaction cannot find
+		return nil
+	}
+	return result
+}
+
+// AuditChanges returns the before/after diff as json, objects only keep the fields that changed,
+// the diff is taken before redacting so a changed secret still shows up, without its value
+func AuditChanges(before any, after any) string {
+	b := toAuditValue(before)
+	a := toAuditValue(after)
+	if b == nil && a == nil {
+		return ""
+	}
+	var changes any
+	bm, bok := b.(map[string]any)
+	am, aok := a.(map[string]any)
+	if bok && aok {
+		diff := make(map[string]any)
+		for k, v := range am {
+			if !reflect.DeepEqual(bm[k], v) {
+				diff[k] = map[string]any{"before": bm[k], "after": v}
+			}
+		}
+		for k, v := range bm {
+			if _, ok := am[k]; !ok {
+				diff[k] = map[string]any{"before": v, "after": nil}
+			}
+		}
+		changes = diff
+	} else {
+		diff := make(map[string]any)
+		if b != nil {
+			diff["before"] = b
+		}
+		if a != nil {
+			diff["after"] = a
+		}
+		changes = diff
+	}
+	data, err := json.Marshal(RedactAuditValue(changes))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
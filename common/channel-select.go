@@ -0,0 +1,34 @@
+package common
+
+import "encoding/json"
+
+const (
+	// ChannelSelectModeWeight picks channels by their static priority and weight
+	ChannelSelectModeWeight = "weight"
+	// ChannelSelectModeAdaptive scales the weights by the recent latency and error rate of the channels
+	ChannelSelectModeAdaptive = "adaptive"
+)
+
+// GroupChannelSelectMode holds the channel select mode of each group, groups not listed use ChannelSelectModeWeight
+var GroupChannelSelectMode = map[string]string{}
+
+func GroupChannelSelectMode2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupChannelSelectMode)
+	if err != nil {
+		SysError("error marshalling group channel select mode: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupChannelSelectModeByJSONString(jsonStr string) error {
+	GroupChannelSelectMode = make(map[string]string)
+	return json.Unmarshal([]byte(jsonStr), &GroupChannelSelectMode)
+}
+
+func GetGroupChannelSelectMode(group string) string {
+	mode, ok := GroupChannelSelectMode[group]
+	if !ok {
+		return ChannelSelectModeWeight
+	}
+	return mode
+}
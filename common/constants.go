@@ -40,6 +40,7 @@ var PasswordLoginEnabled = true
 var PasswordRegisterEnabled = true
 var EmailVerificationEnabled = false
 var GitHubOAuthEnabled = false
+var OIDCEnabled = false
 var WeChatAuthEnabled = false
 var TelegramOAuthEnabled = false
 var TurnstileCheckEnabled = false
@@ -74,6 +75,17 @@ var SMTPToken = ""
 var GitHubClientId = ""
 var GitHubClientSecret = ""
 
+// OIDC login, the provider is found through the discovery document of the issuer
+var OIDCName = "OIDC"
+var OIDCIssuer = ""
+var OIDCClientId = ""
+var OIDCClientSecret = ""
+var OIDCScopes = "openid profile email"
+var OIDCUsernameClaim = "preferred_username"
+var OIDCEmailClaim = "email"
+var OIDCGroupClaim = ""   // empty disables the group mapping
+var OIDCGroupMapping = "" // json object mapping claim values to groups
+
 var WeChatServerAddress = ""
 var WeChatServerToken = ""
 var WeChatAccountQRCodeImageURL = ""
@@ -85,11 +97,25 @@ var TelegramBotToken = ""
 var TelegramBotName = ""
 
 var QuotaForNewUser = 0
+
+// TwoFARequiredRole makes users of the role and above enable 2fa, 0 leaves it optional
+var TwoFARequiredRole = 0
 var QuotaForInviter = 0
 var QuotaForInvitee = 0
 var ChannelDisableThreshold = 5.0
 var AutomaticDisableChannelEnabled = false
 var AutomaticEnableChannelEnabled = false
+
+// the circuit breaker takes failing channels out of the selection for a while instead of disabling them
+var CircuitBreakerEnabled = false
+var CircuitBreakerThreshold = 5      // consecutive failures that open the breaker
+var CircuitBreakerCooldown = 60      // unit is second
+var CircuitBreakerHalfOpenRate = 0.1 // share of the traffic let through to a half-open channel
+
+// StickyRoutingEnabled sends the requests of a conversation to the channel and key that served it before
+var StickyRoutingEnabled = false
+var StickyRoutingHeader = "X-Session-Id" // request header carrying the session, the user field and the leading messages are used otherwise
+var StickyRoutingTTL = 3600              // unit is second
 var QuotaRemindThreshold = 1000
 var PreConsumedQuota = 500
 
@@ -111,6 +137,20 @@ var RelayTimeout = GetOrDefault("RELAY_TIMEOUT", 0) // unit is second
 
 var GeminiSafetySetting = GetOrDefaultString("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
 
+var FileStoragePath = GetOrDefaultString("FILE_STORAGE_PATH", "./files")
+var FileMaxSize = GetOrDefault("FILE_MAX_SIZE", 512)       // unit is MB
+var FileUserQuota = GetOrDefault("FILE_USER_QUOTA", 10240) // unit is MB, the total size of the files of a user, 0 means unlimited
+
+// BatchDiscount is applied to the quota of every request executed by the Batch API
+var BatchDiscount = 0.5
+var BatchConcurrency = GetOrDefault("BATCH_CONCURRENCY", 4)
+
+// ChannelScoreWindow is the length in seconds of the rolling window the adaptive channel selection looks at
+var ChannelScoreWindow = GetOrDefault("CHANNEL_SCORE_WINDOW", 300)
+
+// ChannelScoreMinRequests is the number of requests in the window below which a channel keeps its plain weight
+var ChannelScoreMinRequests = GetOrDefault("CHANNEL_SCORE_MIN_REQUESTS", 5)
+
 const (
 	RequestIdKey = "X-Oneapi-Request-Id"
 )
@@ -168,6 +208,17 @@ const (
 	RedemptionCodeStatusUsed     = 3 // also don't use 0
 )
 
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
 const (
 	ChannelStatusUnknown          = 0
 	ChannelStatusEnabled          = 1 // don't use 0, 0 is the default value!
@@ -175,6 +226,11 @@ const (
 	ChannelStatusAutoDisabled     = 3
 )
 
+const (
+	ChannelKeyModeRandom     = "random"
+	ChannelKeyModeRoundRobin = "round_robin"
+)
+
 const (
 	ChannelTypeUnknown        = 0
 	ChannelTypeOpenAI         = 1
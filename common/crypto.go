@@ -1,6 +1,18 @@
 package common
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TokenHashSecret salts the hashes of the api keys, it is generated once and kept in the options table
+var TokenHashSecret = ""
+
+// TokenKeyPrefixLength is how many characters of an api key are kept to tell the keys apart
+const TokenKeyPrefixLength = 8
 
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
@@ -12,3 +24,27 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func GenerateTokenHashSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// HashTokenKey returns the salted hash an api key is stored and looked up by, the key is without sk-
+func HashTokenKey(key string) string {
+	mac := hmac.New(sha256.New, []byte(TokenHashSecret))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// TokenKeyPrefix returns the part of an api key that is kept for display
+func TokenKeyPrefix(key string) string {
+	if len(key) > TokenKeyPrefixLength {
+		return key[:TokenKeyPrefixLength]
+	}
+	return key
+}
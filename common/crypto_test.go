@@ -0,0 +1,44 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestHashTokenKey(t *testing.T) {
+	previous := TokenHashSecret
+	defer func() { TokenHashSecret = previous }()
+	// the common HMAC-SHA256 example with the key "key"
+	TokenHashSecret = "key"
+	if got, want := HashTokenKey("The quick brown fox jumps over the lazy dog"), "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"; got != want {
+		t.Errorf("HashTokenKey = %s, want %s", got, want)
+	}
+	key := "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKL"
+	hash := HashTokenKey(key)
+	if len(hash) != 64 || HashTokenKey(key) != hash {
+		t.Errorf("HashTokenKey is not a stable hex sha256: %s", hash)
+	}
+	if HashTokenKey(key[:len(key)-1]+"M") == hash {
+		t.Error("different keys have the same hash")
+	}
+	TokenHashSecret = "other"
+	if HashTokenKey(key) == hash {
+		t.Error("the hash does not depend on the secret")
+	}
+}
+
+func TestTokenKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"abcdefghijklmnop", "abcdefgh"},
+		{"abcdefgh", "abcdefgh"},
+		{"abc", "abc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := TokenKeyPrefix(tt.key); got != tt.want {
+			t.Errorf("TokenKeyPrefix(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -3,8 +3,11 @@ package common
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"io"
+	"mime"
+	"mime/multipart"
 	"strings"
 )
 
@@ -43,3 +46,26 @@ func UnmarshalBodyReusable(c *gin.Context, v any) error {
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 	return nil
 }
+
+// ParseMultipartFormReusable parses a multipart/form-data body and keeps it
+// readable for the relay, callers should RemoveAll the returned form
+func ParseMultipartFormReusable(c *gin.Context) (*multipart.Form, error) {
+	requestBody, err := GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, errors.New("request body must be multipart/form-data")
+	}
+	form, err := multipart.NewReader(bytes.NewReader(requestBody), params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		return nil, err
+	}
+	// Reset request body
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	return form, nil
+}
@@ -0,0 +1,25 @@
+package common
+
+import "encoding/json"
+
+// GroupHedgeDelay holds the hedging threshold of each group in milliseconds, when the first channel
+// has not written a byte within it a second channel is raced, e.g. {"vip": 1500}
+var GroupHedgeDelay = map[string]int{}
+
+func GroupHedgeDelay2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupHedgeDelay)
+	if err != nil {
+		SysError("error marshalling group hedge delay: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupHedgeDelayByJSONString(jsonStr string) error {
+	GroupHedgeDelay = make(map[string]int)
+	return json.Unmarshal([]byte(jsonStr), &GroupHedgeDelay)
+}
+
+// GetGroupHedgeDelay returns the hedging threshold of the group in milliseconds, 0 if the group is not hedged
+func GetGroupHedgeDelay(group string) int {
+	return GroupHedgeDelay[group]
+}
@@ -13,28 +13,18 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+
+	ReencryptChannelKeys = flag.Bool("reencrypt-channel-keys", false, "encrypt all channel keys with CHANNEL_KEY_SECRET and exit")
 )
 
 func printHelp() {
 	fmt.Println("New API " + Version + " - All in one API service for OpenAI API.")
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/songquanpeng/one-api")
-	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--reencrypt-channel-keys] [--version] [--help]")
 }
 
 func init() {
-	flag.Parse()
-
-	if *PrintVersion {
-		fmt.Println(Version)
-		os.Exit(0)
-	}
-
-	if *PrintHelp {
-		printHelp()
-		os.Exit(0)
-	}
-
 	if os.Getenv("SESSION_SECRET") != "" {
 		ss := os.Getenv("SESSION_SECRET")
 		if ss == "random_string" {
@@ -48,6 +38,22 @@ func init() {
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
+}
+
+// ParseFlags parses the command line, it is called from main rather than init so that tests can parse their own flags
+func ParseFlags() {
+	flag.Parse()
+
+	if *PrintVersion {
+		fmt.Println(Version)
+		os.Exit(0)
+	}
+
+	if *PrintHelp {
+		printHelp()
+		os.Exit(0)
+	}
+
 	if *LogDir != "" {
 		var err error
 		*LogDir, err = filepath.Abs(*LogDir)
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIpAllowList parses a list of IPv4/IPv6 CIDRs separated by commas or new lines,
+// a bare address is treated as a single host
+func ParseIpAllowList(list string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0)
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	}) {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP 地址：%s", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR：%s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// IsIpInList reports whether the ip is inside one of the networks
+func IsIpInList(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
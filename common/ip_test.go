@@ -0,0 +1,57 @@
+package common
+
+import "testing"
+
+func TestParseIpAllowList(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		count   int
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"blank", " \n\t", 0, false},
+		{"single ipv4", "10.0.0.1", 1, false},
+		{"comma and newline", "10.0.0.1,192.168.0.0/16\n::1\r\n2001:db8::/32", 4, false},
+		{"invalid ip", "10.0.0.300", 0, true},
+		{"invalid cidr", "10.0.0.0/33", 0, true},
+		{"garbage", "localhost", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := ParseIpAllowList(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIpAllowList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			}
+			if !tt.wantErr && len(nets) != tt.count {
+				t.Fatalf("ParseIpAllowList(%q) returned %d networks, want %d", tt.list, len(nets), tt.count)
+			}
+		})
+	}
+}
+
+func TestIsIpInList(t *testing.T) {
+	nets, err := ParseIpAllowList("10.0.0.1, 192.168.0.0/16, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"192.168.10.20", true},
+		{"192.169.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:10.0.0.1", true},
+		{"", false},
+		{"not an ip", false},
+	}
+	for _, tt := range tests {
+		if got := IsIpInList(tt.ip, nets); got != tt.want {
+			t.Errorf("IsIpInList(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
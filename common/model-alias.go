@@ -0,0 +1,28 @@
+package common
+
+import "encoding/json"
+
+// ModelAliases maps the model names clients may request to the models serving them in every
+// group, the alias is resolved before a channel is selected, e.g. {"smart": "gpt-4o"}
+var ModelAliases = map[string]string{}
+
+func ModelAliases2JSONString() string {
+	jsonBytes, err := json.Marshal(ModelAliases)
+	if err != nil {
+		SysError("error marshalling model aliases: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelAliasesByJSONString(jsonStr string) error {
+	ModelAliases = make(map[string]string)
+	return json.Unmarshal([]byte(jsonStr), &ModelAliases)
+}
+
+// ResolveModelAlias returns the model the alias stands for, other names are returned as they are
+func ResolveModelAlias(model string) string {
+	if target, ok := ModelAliases[model]; ok && target != "" {
+		return target
+	}
+	return model
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GroupModelFallback holds the fallback chains of each group, a model maps to the models that serve
+// its requests in order when it has no healthy channel, e.g. {"default": {"gpt-4o": ["gpt-4-turbo"]}}
+var GroupModelFallback = map[string]map[string][]string{}
+
+func GroupModelFallback2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupModelFallback)
+	if err != nil {
+		SysError("error marshalling group model fallback: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+// parseGroupModelFallback rejects a chain that names a model twice, the relay walks a chain once from start to end
+func parseGroupModelFallback(jsonStr string) (map[string]map[string][]string, error) {
+	fallback := make(map[string]map[string][]string)
+	err := json.Unmarshal([]byte(jsonStr), &fallback)
+	if err != nil {
+		return nil, err
+	}
+	for group, chains := range fallback {
+		for model, chain := range chains {
+			seen := make(map[string]bool)
+			for _, fallbackModel := range chain {
+				if seen[fallbackModel] {
+					return nil, fmt.Errorf("分组 %s 中模型 %s 的降级链重复包含模型 %s", group, model, fallbackModel)
+				}
+				seen[fallbackModel] = true
+			}
+		}
+	}
+	return fallback, nil
+}
+
+func CheckGroupModelFallbackJSONString(jsonStr string) error {
+	_, err := parseGroupModelFallback(jsonStr)
+	return err
+}
+
+func UpdateGroupModelFallbackByJSONString(jsonStr string) error {
+	fallback, err := parseGroupModelFallback(jsonStr)
+	if err != nil {
+		return err
+	}
+	GroupModelFallback = fallback
+	return nil
+}
+
+// GetModelFallbacks returns the fallback chain of the model in the group, the model itself is left out
+func GetModelFallbacks(group string, model string) []string {
+	fallbacks := make([]string, 0)
+	for _, fallback := range GroupModelFallback[group][model] {
+		if fallback != model {
+			fallbacks = append(fallbacks, fallback)
+		}
+	}
+	return fallbacks
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateGroupModelFallback(t *testing.T) {
+	previous := GroupModelFallback
+	t.Cleanup(func() {
+		GroupModelFallback = previous
+	})
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"empty", `{}`, false},
+		{"chains", `{"default":{"gpt-4o":["gpt-4-turbo","gpt-4o","gpt-3.5-turbo"]},"vip":{"claude-3-opus":["claude-3-sonnet"]}}`, false},
+		{"invalid json", `{"default":`, true},
+		{"not a chain", `{"default":{"gpt-4o":"gpt-4-turbo"}}`, true},
+		{"model twice in a chain", `{"default":{"gpt-4o":["gpt-4-turbo","gpt-3.5-turbo","gpt-4-turbo"]}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			GroupModelFallback = map[string]map[string][]string{"default": {"kept": {"on-error"}}}
+			if err := CheckGroupModelFallbackJSONString(tt.json); (err != nil) != tt.wantErr {
+				t.Errorf("CheckGroupModelFallbackJSONString(%s) = %v, want error %v", tt.json, err, tt.wantErr)
+			}
+			err := UpdateGroupModelFallbackByJSONString(tt.json)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateGroupModelFallbackByJSONString(%s) = %v, want error %v", tt.json, err, tt.wantErr)
+			}
+			if tt.wantErr && len(GroupModelFallback["default"]["kept"]) != 1 {
+				t.Error("a rejected value replaced the fallback chains")
+			}
+		})
+	}
+}
+
+func TestGetModelFallbacks(t *testing.T) {
+	previous := GroupModelFallback
+	t.Cleanup(func() {
+		GroupModelFallback = previous
+	})
+	if err := UpdateGroupModelFallbackByJSONString(`{"default":{"gpt-4o":["gpt-4-turbo","gpt-4o","gpt-3.5-turbo"]}}`); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		group string
+		model string
+		want  string
+	}{
+		// the chain keeps its order and leaves the model itself out
+		{"default", "gpt-4o", "gpt-4-turbo,gpt-3.5-turbo"},
+		{"default", "gpt-4-turbo", ""},
+		{"vip", "gpt-4o", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(GetModelFallbacks(tt.group, tt.model), ","); got != tt.want {
+			t.Errorf("GetModelFallbacks(%s, %s) = %s, want %s", tt.group, tt.model, got, tt.want)
+		}
+	}
+}
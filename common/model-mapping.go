@@ -0,0 +1,142 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ModelRegexPrefix marks a model name as a regular expression matching the whole name,
+// e.g. "regex:^ft:gpt-4o-mini:.+$", a name containing * is a wildcard, e.g. "claude-3-*"
+const ModelRegexPrefix = "regex:"
+
+// IsModelPattern reports whether the model name of a channel or a mapping matches other names
+func IsModelPattern(name string) bool {
+	return strings.HasPrefix(name, ModelRegexPrefix) || strings.Contains(name, "*")
+}
+
+// the compiled patterns are shared by the channel cache, the mappings and the database selector
+var modelPatternLock sync.RWMutex
+var modelPatterns = make(map[string]*regexp.Regexp)
+
+// CompileModelPattern compiles a wildcard or regex model name, the * of a wildcard become capture groups
+func CompileModelPattern(pattern string) (*regexp.Regexp, error) {
+	modelPatternLock.RLock()
+	expr, ok := modelPatterns[pattern]
+	modelPatternLock.RUnlock()
+	if ok {
+		return expr, nil
+	}
+	var source string
+	if strings.HasPrefix(pattern, ModelRegexPrefix) {
+		source = "^(?:" + strings.TrimPrefix(pattern, ModelRegexPrefix) + ")$"
+	} else {
+		source = "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, "(.*)") + "$"
+	}
+	expr, err := regexp.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("模型匹配规则 %s 无效：%s", pattern, err.Error())
+	}
+	modelPatternLock.Lock()
+	modelPatterns[pattern] = expr
+	modelPatternLock.Unlock()
+	return expr, nil
+}
+
+// ModelNameMatches reports whether the model is served by the model name of a channel, an exact name or a pattern
+func ModelNameMatches(name string, model string) bool {
+	if name == model {
+		return true
+	}
+	if !IsModelPattern(name) {
+		return false
+	}
+	expr, err := CompileModelPattern(name)
+	return err == nil && expr.MatchString(model)
+}
+
+type modelMappingRule struct {
+	pattern  string
+	expr     *regexp.Regexp
+	template string
+}
+
+// ModelMapping maps the requested model to the upstream one. Exact names take precedence over
+// patterns, which are tried from the longest to the shortest. The target of a regex may refer to its
+// groups as $1, the * of a wildcard target are replaced with what the * of the pattern matched, e.g.
+// {"gpt-4": "gpt-4-0613", "claude-3-*": "anthropic.claude-3-*", "regex:^o1-(.+)$": "o1-$1-2024"}
+type ModelMapping struct {
+	exact map[string]string
+	rules []modelMappingRule
+}
+
+func ParseModelMapping(jsonStr string) (*ModelMapping, error) {
+	mapping := &ModelMapping{exact: make(map[string]string)}
+	if jsonStr == "" || jsonStr == "{}" {
+		return mapping, nil
+	}
+	modelMap := make(map[string]string)
+	err := json.Unmarshal([]byte(jsonStr), &modelMap)
+	if err != nil {
+		return nil, err
+	}
+	for name, target := range modelMap {
+		if target == "" {
+			continue
+		}
+		if !IsModelPattern(name) {
+			mapping.exact[name] = target
+			continue
+		}
+		expr, err := CompileModelPattern(name)
+		if err != nil {
+			return nil, err
+		}
+		template := target
+		if !strings.HasPrefix(name, ModelRegexPrefix) {
+			template = wildcardTemplate(target)
+		}
+		mapping.rules = append(mapping.rules, modelMappingRule{pattern: name, expr: expr, template: template})
+	}
+	sort.Slice(mapping.rules, func(i, j int) bool {
+		if len(mapping.rules[i].pattern) != len(mapping.rules[j].pattern) {
+			return len(mapping.rules[i].pattern) > len(mapping.rules[j].pattern)
+		}
+		return mapping.rules[i].pattern < mapping.rules[j].pattern
+	})
+	return mapping, nil
+}
+
+// wildcardTemplate turns the * of a wildcard target into the groups of the pattern in order
+func wildcardTemplate(target string) string {
+	parts := strings.Split(strings.ReplaceAll(target, "$", "$$"), "*")
+	var builder strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			builder.WriteString("${" + strconv.Itoa(i) + "}")
+		}
+		builder.WriteString(part)
+	}
+	return builder.String()
+}
+
+// Map returns the upstream model and whether a rule of the mapping applied
+func (m *ModelMapping) Map(model string) (string, bool) {
+	if m == nil {
+		return model, false
+	}
+	if target, ok := m.exact[model]; ok {
+		return target, true
+	}
+	for _, rule := range m.rules {
+		match := rule.expr.FindStringSubmatchIndex(model)
+		if match != nil {
+			return string(rule.expr.ExpandString(nil, rule.template, model, match)), true
+		}
+	}
+	return model, false
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestCompileModelPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		model   string
+		want    bool
+	}{
+		{"claude-3-*", "claude-3-haiku-20240307", true},
+		{"claude-3-*", "claude-3-", true},
+		{"claude-3-*", "claude-2.1", false},
+		{"claude-3-*", "x-claude-3-haiku", false},
+		{"gpt-4o.*", "gpt-4o.mini", true},
+		{"gpt-4o.*", "gpt-4o-mini", false},
+		{"*-preview", "o1-preview", true},
+		{"regex:^ft:gpt-4o-mini:.+$", "ft:gpt-4o-mini:org:1", true},
+		{"regex:gpt-4|o1", "o1", true},
+		{"regex:gpt-4|o1", "gpt-4o", false},
+		{"regex:o1-(mini|preview)", "o1-mini", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.model, func(t *testing.T) {
+			expr, err := CompileModelPattern(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.MatchString(tt.model); got != tt.want {
+				t.Errorf("%s matches %s = %v, want %v", tt.pattern, tt.model, got, tt.want)
+			}
+		})
+	}
+	if _, err := CompileModelPattern("regex:gpt-(4"); err == nil {
+		t.Error("an invalid regex compiled")
+	}
+}
+
+func TestModelNameMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  bool
+	}{
+		{"gpt-4o", "gpt-4o", true},
+		{"gpt-4o", "gpt-4o-mini", false},
+		{"gpt-4o*", "gpt-4o-mini", true},
+		{"regex:gpt-(4", "gpt-4", false},
+	}
+	for _, tt := range tests {
+		if got := ModelNameMatches(tt.name, tt.model); got != tt.want {
+			t.Errorf("ModelNameMatches(%q, %q) = %v, want %v", tt.name, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestParseModelMapping(t *testing.T) {
+	mapping := `{
+		"gpt-4": "gpt-4-0613",
+		"gpt-4*": "gpt-4-turbo",
+		"claude-3-*": "anthropic.claude-3-*",
+		"claude-3-5-*": "anthropic.claude-3-5-*-v2",
+		"*-to-*": "*-from-*",
+		"regex:^o1-(.+)$": "o1-$1-2024",
+		"price-*": "$literal-*",
+		"unused": ""
+	}`
+	tests := []struct {
+		model  string
+		want   string
+		mapped bool
+	}{
+		{"gpt-4", "gpt-4-0613", true},
+		{"gpt-4o", "gpt-4-turbo", true},
+		{"claude-3-haiku", "anthropic.claude-3-haiku", true},
+		{"claude-3-5-sonnet", "anthropic.claude-3-5-sonnet-v2", true},
+		{"a-to-b", "a-from-b", true},
+		{"o1-mini", "o1-mini-2024", true},
+		{"price-x", "$literal-x", true},
+		{"unused", "unused", false},
+		{"gemini-pro", "gemini-pro", false},
+	}
+	m, err := ParseModelMapping(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, mapped := m.Map(tt.model)
+			if got != tt.want || mapped != tt.mapped {
+				t.Errorf("Map(%q) = %q, %v, want %q, %v", tt.model, got, mapped, tt.want, tt.mapped)
+			}
+		})
+	}
+}
+
+func TestParseModelMappingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"empty object", "{}", false},
+		{"invalid json", `{"gpt-4":`, true},
+		{"not a string", `{"gpt-4":1}`, true},
+		{"invalid regex", `{"regex:gpt-(4":"gpt-4"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseModelMapping(tt.mapping); (err != nil) != tt.wantErr {
+				t.Errorf("ParseModelMapping(%s) = %v, want error %v", tt.mapping, err, tt.wantErr)
+			}
+		})
+	}
+	var m *ModelMapping
+	if got, mapped := m.Map("gpt-4"); got != "gpt-4" || mapped {
+		t.Errorf("nil mapping mapped gpt-4 to %q", got)
+	}
+}
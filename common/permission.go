@@ -0,0 +1,168 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// permissions guard the admin routes, root users hold all of them
+const (
+	PermissionChannelsRead  = "channels:read"
+	PermissionChannelsWrite = "channels:write"
+	PermissionUsersRead     = "users:read"
+	PermissionUsersManage   = "users:manage"
+	PermissionLogsRead      = "logs:read"
+	PermissionLogsManage    = "logs:manage"
+	PermissionBillingManage = "billing:manage"
+	PermissionOptionsRead   = "options:read"
+	PermissionOptionsWrite  = "options:write"
+)
+
+var AllPermissions = []string{
+	PermissionChannelsRead,
+	PermissionChannelsWrite,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionLogsRead,
+	PermissionLogsManage,
+	PermissionBillingManage,
+	PermissionOptionsRead,
+	PermissionOptionsWrite,
+}
+
+// DefaultAdminPermissions are held by admin users without an admin role, the options stay with root
+var DefaultAdminPermissions = []string{
+	PermissionChannelsRead,
+	PermissionChannelsWrite,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionLogsRead,
+	PermissionLogsManage,
+	PermissionBillingManage,
+}
+
+// AdminRoles maps an admin role name to the permissions it is composed of
+var AdminRoles = map[string][]string{
+	"support": {
+		PermissionChannelsRead,
+		PermissionUsersRead,
+		PermissionLogsRead,
+	},
+	"operator": {
+		PermissionChannelsRead,
+		PermissionChannelsWrite,
+		PermissionLogsRead,
+	},
+	"billing": {
+		PermissionUsersRead,
+		PermissionLogsRead,
+		PermissionBillingManage,
+	},
+}
+
+// the options that decide who may sign in and how are changed by root users only, options:write does not cover them
+var rootOnlyOptionPrefixes = []string{"TwoFA", "OIDC", "SMTP", "GitHub", "WeChat", "Telegram", "Turnstile"}
+var rootOnlyOptions = map[string]bool{
+	"AdminRoles":                    true,
+	"TokenHashSecret":               true,
+	"ServerAddress":                 true,
+	"RegisterEnabled":               true,
+	"PasswordRegisterEnabled":       true,
+	"PasswordLoginEnabled":          true,
+	"EmailVerificationEnabled":      true,
+	"EmailDomainRestrictionEnabled": true,
+	"EmailAliasRestrictionEnabled":  true,
+	"EmailDomainWhitelist":          true,
+}
+
+func IsRootOnlyOption(key string) bool {
+	if rootOnlyOptions[key] {
+		return true
+	}
+	for _, prefix := range rootOnlyOptionPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func AdminRoles2JSONString() string {
+	jsonBytes, err := json.Marshal(AdminRoles)
+	if err != nil {
+		SysError("error marshalling admin roles: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func parseAdminRoles(jsonStr string) (map[string][]string, error) {
+	roles := make(map[string][]string)
+	err := json.Unmarshal([]byte(jsonStr), &roles)
+	if err != nil {
+		return nil, err
+	}
+	for name, permissions := range roles {
+		if name == "" {
+			return nil, fmt.Errorf("角色名称不能为空")
+		}
+		for _, permission := range permissions {
+			if !IsValidPermission(permission) {
+				return nil, fmt.Errorf("角色 %s 包含未知权限 %s", name, permission)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// CheckAdminRolesJSONString validates the roles before they are saved
+func CheckAdminRolesJSONString(jsonStr string) error {
+	_, err := parseAdminRoles(jsonStr)
+	return err
+}
+
+func UpdateAdminRolesByJSONString(jsonStr string) error {
+	roles, err := parseAdminRoles(jsonStr)
+	if err != nil {
+		return err
+	}
+	AdminRoles = roles
+	return nil
+}
+
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRolePermissions returns the permissions of a user role and admin role, common users have none
+// and an admin role that no longer exists grants nothing
+func GetRolePermissions(role int, adminRole string) []string {
+	if role >= RoleRootUser {
+		return AllPermissions
+	}
+	if role < RoleAdminUser {
+		return []string{}
+	}
+	if adminRole == "" {
+		return DefaultAdminPermissions
+	}
+	permissions, ok := AdminRoles[adminRole]
+	if !ok {
+		return []string{}
+	}
+	return permissions
+}
+
+func HasPermission(role int, adminRole string, permission string) bool {
+	for _, p := range GetRolePermissions(role, adminRole) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
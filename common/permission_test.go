@@ -0,0 +1,31 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestIsRootOnlyOption(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"AdminRoles", true},
+		{"TokenHashSecret", true},
+		{"TwoFARequiredRole", true},
+		{"OIDCIssuer", true},
+		{"OIDCGroupMapping", true},
+		{"SMTPServer", true},
+		{"GitHubClientSecret", true},
+		{"RegisterEnabled", true},
+		{"PasswordLoginEnabled", true},
+		{"ServerAddress", true},
+		{"ModelRatio", false},
+		{"Notice", false},
+		{"BatchDiscount", false},
+	}
+	for _, tt := range tests {
+		if got := IsRootOnlyOption(tt.key); got != tt.want {
+			t.Errorf("IsRootOnlyOption(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
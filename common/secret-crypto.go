@@ -0,0 +1,251 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// the secrets stored in the database, like the channel keys, are sealed in an envelope: every value is
+// encrypted with a data key of its own and the data key is wrapped with the master key, so rotating the
+// master key only wraps the data keys again. A sealed value is "enc:v2:<master key id>:<data key>:<value>".
+const sealedPrefix = "enc:"
+const sealedV2Prefix = "enc:v2:"
+
+// masterKeySalt salts the scrypt derivation of the master keys, they may be passphrases
+var masterKeySalt = []byte("one-api master key")
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// the master key data keys are wrapped with, and the one being rotated away from
+var currentMasterKey *masterKey
+var oldMasterKey *masterKey
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func deriveKey(secret []byte, info string, size int) ([]byte, error) {
+	key := make([]byte, size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(info)), key)
+	return key, err
+}
+
+func newMasterKey(secret string) (*masterKey, error) {
+	// scrypt slows down guessing a passphrase, the wrapping key and the id are derived from its output with hkdf
+	stretched, err := scrypt.Key([]byte(secret), masterKeySalt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	wrapKey, err := deriveKey(stretched, "one-api data key wrapping", 32)
+	if err != nil {
+		return nil, err
+	}
+	id, err := deriveKey(stretched, "one-api master key id", 4)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return &masterKey{id: hex.EncodeToString(id), aead: aead}, nil
+}
+
+func sealBytes(aead cipher.AEAD, plain []byte, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, additionalData)), nil
+}
+
+func openBytes(aead cipher.AEAD, sealed string, additionalData []byte) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+}
+
+// readSecretEnv reads the secret from the env variable, or from the file the env variable with the _FILE suffix points to
+func readSecretEnv(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// InitMasterKeys loads the master key from CHANNEL_KEY_SECRET, and the previous one from CHANNEL_KEY_OLD_SECRET
+// while rotating, the secrets are stored in plain text without a master key
+func InitMasterKeys() error {
+	secret, err := readSecretEnv("CHANNEL_KEY_SECRET")
+	if err != nil {
+		return err
+	}
+	oldSecret, err := readSecretEnv("CHANNEL_KEY_OLD_SECRET")
+	if err != nil {
+		return err
+	}
+	currentMasterKey, oldMasterKey = nil, nil
+	if secret == "" {
+		if oldSecret != "" {
+			return errors.New("CHANNEL_KEY_OLD_SECRET is set without CHANNEL_KEY_SECRET")
+		}
+		return nil
+	}
+	currentMasterKey, err = newMasterKey(secret)
+	if err != nil {
+		return err
+	}
+	if oldSecret != "" {
+		oldMasterKey, err = newMasterKey(oldSecret)
+		if err != nil {
+			return err
+		}
+	}
+	SysLog("secrets are encrypted with master key " + currentMasterKey.id)
+	return nil
+}
+
+func MasterKeyEnabled() bool {
+	return currentMasterKey != nil
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// NeedsResealing reports whether the value is stored in plain text or its data key is wrapped with another master key than the current one
+func NeedsResealing(value string) bool {
+	if currentMasterKey == nil {
+		return false
+	}
+	return !strings.HasPrefix(value, sealedV2Prefix+currentMasterKey.id+":")
+}
+
+// SealSecret encrypts the value with a new data key wrapped with the current master key, it is returned as is without a master key
+func SealSecret(value string) (string, error) {
+	master := currentMasterKey
+	if master == nil || value == "" || IsSealed(value) {
+		return value, nil
+	}
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := sealBytes(dataAEAD, []byte(value), []byte(sealedV2Prefix))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := sealBytes(master.aead, dataKey, []byte(master.id))
+	if err != nil {
+		return "", err
+	}
+	return sealedV2Prefix + master.id + ":" + wrapped + ":" + data, nil
+}
+
+// unwrapDataKey returns the data key of a sealed value and the value still encrypted with it
+func unwrapDataKey(value string) ([]byte, string, error) {
+	if !strings.HasPrefix(value, sealedV2Prefix) {
+		return nil, "", errors.New("unsupported encrypted value")
+	}
+	parts := strings.Split(strings.TrimPrefix(value, sealedV2Prefix), ":")
+	if len(parts) != 3 {
+		return nil, "", errors.New("invalid encrypted value")
+	}
+	var master *masterKey
+	for _, m := range []*masterKey{currentMasterKey, oldMasterKey} {
+		if m != nil && m.id == parts[0] {
+			master = m
+		}
+	}
+	if master == nil {
+		return nil, "", fmt.Errorf("the master key %s the value is encrypted with is not configured", parts[0])
+	}
+	dataKey, err := openBytes(master.aead, parts[1], []byte(master.id))
+	if err != nil {
+		return nil, "", err
+	}
+	return dataKey, parts[2], nil
+}
+
+// OpenSecret decrypts a value sealed by SealSecret, a plain text value is returned as is
+func OpenSecret(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	dataKey, data, err := unwrapDataKey(value)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := openBytes(dataAEAD, data, []byte(sealedV2Prefix))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// ResealSecret brings the value up to the current master key, a plain text value is sealed and the data key
+// of a value wrapped with the old master key is wrapped again, the value itself is not encrypted again
+func ResealSecret(value string) (string, error) {
+	if !NeedsResealing(value) {
+		return value, nil
+	}
+	if !IsSealed(value) {
+		return SealSecret(value)
+	}
+	// the value is opened first so that a damaged one is not carried over
+	_, err := OpenSecret(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, data, err := unwrapDataKey(value)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := sealBytes(currentMasterKey.aead, dataKey, []byte(currentMasterKey.id))
+	if err != nil {
+		return "", err
+	}
+	return sealedV2Prefix + currentMasterKey.id + ":" + wrapped + ":" + data, nil
+}
@@ -0,0 +1,185 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setMasterKeys loads the master keys as InitMasterKeys does at startup
+func setMasterKeys(t *testing.T, secret string, oldSecret string) {
+	t.Helper()
+	currentMasterKey, oldMasterKey = nil, nil
+	t.Cleanup(func() {
+		currentMasterKey, oldMasterKey = nil, nil
+	})
+	t.Setenv("CHANNEL_KEY_SECRET", secret)
+	t.Setenv("CHANNEL_KEY_OLD_SECRET", oldSecret)
+	if err := InitMasterKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sealedParts splits a sealed value into its master key id, wrapped data key and encrypted value
+func sealedParts(t *testing.T, value string) []string {
+	t.Helper()
+	parts := strings.Split(strings.TrimPrefix(value, sealedV2Prefix), ":")
+	if !strings.HasPrefix(value, sealedV2Prefix) || len(parts) != 3 {
+		t.Fatalf("%q is not a sealed value", value)
+	}
+	return parts
+}
+
+// tamper changes a character in the middle of the encrypted value
+func tamper(value string) string {
+	i := len(value) - 10
+	c := byte('A')
+	if value[i] == c {
+		c = 'B'
+	}
+	return value[:i] + string(c) + value[i+1:]
+}
+
+func TestSealSecret(t *testing.T) {
+	setMasterKeys(t, "", "")
+	if value, err := SealSecret("sk-plain"); value != "sk-plain" || err != nil {
+		t.Errorf("SealSecret without a master key = %q, %v", value, err)
+	}
+
+	setMasterKeys(t, "secret-a", "")
+	sealed, err := SealSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := SealSecret("sk-upstream")
+	tests := []struct {
+		name string
+		got  bool
+	}{
+		{"sealed", IsSealed(sealed) && !strings.Contains(sealed, "sk-upstream")},
+		{"a data key per value", sealedParts(t, again)[1] != sealedParts(t, sealed)[1]},
+		{"not sealed twice", func() bool { value, _ := SealSecret(sealed); return value == sealed }()},
+		{"empty value stays empty", func() bool { value, _ := SealSecret(""); return value == "" }()},
+		{"up to date", !NeedsResealing(sealed)},
+		{"plain value needs sealing", NeedsResealing("sk-plain")},
+	}
+	for _, tt := range tests {
+		if !tt.got {
+			t.Errorf("%s failed", tt.name)
+		}
+	}
+	for _, value := range []string{sealed, again} {
+		if plain, err := OpenSecret(value); plain != "sk-upstream" || err != nil {
+			t.Errorf("OpenSecret = %q, %v", plain, err)
+		}
+	}
+}
+
+func TestOpenSecretErrors(t *testing.T) {
+	setMasterKeys(t, "secret-a", "")
+	sealed, err := SealSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := SealSecret("sk-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := sealedParts(t, sealed)
+	id := currentMasterKey.id
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"unknown version", "enc:v1:" + id + ":" + parts[2]},
+		{"missing data key", sealedV2Prefix + id + ":" + parts[2]},
+		{"unknown master key", sealedV2Prefix + "00000000:" + parts[1] + ":" + parts[2]},
+		{"invalid base64", sealedV2Prefix + id + ":!!:" + parts[2]},
+		{"too short", sealedV2Prefix + id + ":" + parts[1] + ":AAAA"},
+		{"tampered value", tamper(sealed)},
+		{"data key of another value", sealedV2Prefix + id + ":" + sealedParts(t, other)[1] + ":" + parts[2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenSecret(tt.value); err == nil {
+				t.Errorf("OpenSecret(%q) did not fail", tt.value)
+			}
+		})
+	}
+	if plain, err := OpenSecret("sk-plain"); plain != "sk-plain" || err != nil {
+		t.Errorf("OpenSecret of a plain value = %q, %v", plain, err)
+	}
+}
+
+func TestResealSecret(t *testing.T) {
+	setMasterKeys(t, "secret-a", "")
+	underA, err := SealSecret("sk-upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setMasterKeys(t, "secret-b", "secret-a")
+	if !NeedsResealing(underA) {
+		t.Error("a value under the old master key does not need resealing")
+	}
+	if plain, err := OpenSecret(underA); plain != "sk-upstream" || err != nil {
+		t.Fatalf("OpenSecret with the old master key = %q, %v", plain, err)
+	}
+	underB, err := ResealSecret(underA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partsA, partsB := sealedParts(t, underA), sealedParts(t, underB)
+	if NeedsResealing(underB) || partsB[0] == partsA[0] {
+		t.Errorf("resealed value %q is not under the new master key", underB)
+	}
+	if partsB[2] != partsA[2] {
+		t.Error("resealing encrypted the value again instead of only wrapping its data key")
+	}
+	if value, err := ResealSecret(underB); value != underB || err != nil {
+		t.Errorf("ResealSecret of an up to date value = %q, %v", value, err)
+	}
+	sealed, err := ResealSecret("sk-plain")
+	if err != nil || NeedsResealing(sealed) {
+		t.Errorf("ResealSecret of a plain value = %q, %v", sealed, err)
+	}
+	if _, err := ResealSecret(tamper(underA)); err == nil {
+		t.Error("a damaged value was resealed")
+	}
+
+	setMasterKeys(t, "secret-b", "")
+	if _, err := OpenSecret(underA); err == nil {
+		t.Error("a value under a dropped master key was opened")
+	}
+	if plain, err := OpenSecret(underB); plain != "sk-upstream" || err != nil {
+		t.Errorf("OpenSecret after the rotation = %q, %v", plain, err)
+	}
+}
+
+func TestInitMasterKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("secret-a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setMasterKeys(t, "secret-a", "")
+	id := currentMasterKey.id
+
+	t.Setenv("CHANNEL_KEY_SECRET", "")
+	t.Setenv("CHANNEL_KEY_SECRET_FILE", path)
+	if err := InitMasterKeys(); err != nil || currentMasterKey == nil || currentMasterKey.id != id {
+		t.Errorf("the master key read from the file is not the one of the env variable: %v", err)
+	}
+
+	t.Setenv("CHANNEL_KEY_SECRET_FILE", "")
+	t.Setenv("CHANNEL_KEY_OLD_SECRET", "secret-a")
+	if err := InitMasterKeys(); err == nil {
+		t.Error("an old master key without a current one was accepted")
+	}
+
+	t.Setenv("CHANNEL_KEY_OLD_SECRET", "")
+	t.Setenv("CHANNEL_KEY_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	if err := InitMasterKeys(); err == nil {
+		t.Error("a missing secret file was accepted")
+	}
+}
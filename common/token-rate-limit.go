@@ -0,0 +1,211 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenRateLimitWindow is the sliding window the per-token rpm and tpm limits count over
+const TokenRateLimitWindow = time.Minute
+
+// tokenConcurrencyExpiration bounds how long a slot of a crashed node stays taken, a lease older than
+// that is dropped the next time a slot of the token is acquired
+const tokenConcurrencyExpiration = 30 * time.Minute
+
+// slidingWindowScript trims the window and returns whether the weight fits in it, the used weight and the
+// time of the oldest entry, members are "weight:unique" so the window can be summed
+var slidingWindowScript = `
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local weight = tonumber(ARGV[4])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local members = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+local used = 0
+local oldest = now
+for i = 1, #members, 2 do
+	used = used + tonumber(string.match(members[i], '^(%d+):'))
+	if i == 1 then
+		oldest = tonumber(members[i + 1])
+	end
+end
+if weight > 0 then
+	if limit > 0 and used + weight > limit then
+		return {0, used, oldest}
+	end
+	redis.call('ZADD', KEYS[1], now, weight .. ':' .. ARGV[5])
+	redis.call('PEXPIRE', KEYS[1], window)
+	used = used + weight
+end
+return {1, used, oldest}
+`
+
+// acquireConcurrencyScript drops the leases older than the expiration and adds the new one if the token
+// has a free slot, members are the lease ids scored by the time they were taken
+var acquireConcurrencyScript = `
+local now = tonumber(ARGV[1])
+local expiration = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - expiration)
+if redis.call('ZCARD', KEYS[1]) >= limit then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], expiration)
+return 1
+`
+
+type windowEntry struct {
+	time   int64
+	weight int
+}
+
+var windowStore = make(map[string][]windowEntry)
+var concurrencyStore = make(map[string]map[string]int64)
+var tokenRateLimitMutex sync.Mutex
+
+func memorySlidingWindow(key string, limit int, weight int, now int64) (bool, int, int64) {
+	tokenRateLimitMutex.Lock()
+	defer tokenRateLimitMutex.Unlock()
+	entries := windowStore[key]
+	start := now - TokenRateLimitWindow.Milliseconds()
+	i := 0
+	for i < len(entries) && entries[i].time <= start {
+		i++
+	}
+	entries = entries[i:]
+	used := 0
+	for _, entry := range entries {
+		used += entry.weight
+	}
+	oldest := now
+	if len(entries) > 0 {
+		oldest = entries[0].time
+	}
+	if weight > 0 {
+		if limit > 0 && used+weight > limit {
+			windowStore[key] = entries
+			return false, used, oldest
+		}
+		entries = append(entries, windowEntry{time: now, weight: weight})
+		used += weight
+	}
+	if len(entries) == 0 {
+		delete(windowStore, key)
+	} else {
+		windowStore[key] = entries
+	}
+	return true, used, oldest
+}
+
+// slidingWindow adds the weight to the window unless it would exceed the limit, a zero weight only reads
+// the window, it returns the weight used in the window and when the window frees up again
+func slidingWindow(key string, limit int, weight int) (bool, int, time.Duration) {
+	now := time.Now().UnixMilli()
+	ok, used, oldest := true, 0, now
+	if RedisEnabled {
+		result, err := RDB.Eval(context.Background(), slidingWindowScript, []string{key},
+			now, TokenRateLimitWindow.Milliseconds(), limit, weight, GetUUID()).Result()
+		if err != nil {
+			// the limits are best effort, an unavailable redis does not fail the request
+			SysError("token rate limit: " + err.Error())
+			return true, 0, 0
+		}
+		values := result.([]any)
+		ok = values[0].(int64) == 1
+		used = int(values[1].(int64))
+		oldest = values[2].(int64)
+	} else {
+		ok, used, oldest = memorySlidingWindow(key, limit, weight, now)
+	}
+	reset := time.Duration(oldest+TokenRateLimitWindow.Milliseconds()-now) * time.Millisecond
+	if reset < 0 {
+		reset = 0
+	}
+	return ok, used, reset
+}
+
+// TakeTokenRequest counts a request against the rpm limit of a token
+func TakeTokenRequest(tokenId int, limit int) (bool, int, time.Duration) {
+	ok, used, reset := slidingWindow(fmt.Sprintf("tokenRPM:%d", tokenId), limit, 1)
+	return ok, limit - used, reset
+}
+
+// CheckTokenTokens reports whether the token used less than its tpm limit in the window
+func CheckTokenTokens(tokenId int, limit int) (bool, int, time.Duration) {
+	_, used, reset := slidingWindow(fmt.Sprintf("tokenTPM:%d", tokenId), 0, 0)
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return used < limit, remaining, reset
+}
+
+// RecordTokenTokens adds the tokens a finished request used to the tpm window of its token
+func RecordTokenTokens(tokenId int, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	slidingWindow(fmt.Sprintf("tokenTPM:%d", tokenId), 0, tokens)
+}
+
+func memoryAcquireConcurrency(key string, lease string, limit int, now int64) bool {
+	tokenRateLimitMutex.Lock()
+	defer tokenRateLimitMutex.Unlock()
+	leases := concurrencyStore[key]
+	if leases == nil {
+		leases = make(map[string]int64)
+		concurrencyStore[key] = leases
+	}
+	for id, taken := range leases {
+		if taken <= now-tokenConcurrencyExpiration.Milliseconds() {
+			delete(leases, id)
+		}
+	}
+	if len(leases) >= limit {
+		return false
+	}
+	leases[lease] = now
+	return true
+}
+
+func memoryReleaseConcurrency(key string, lease string) {
+	tokenRateLimitMutex.Lock()
+	defer tokenRateLimitMutex.Unlock()
+	delete(concurrencyStore[key], lease)
+	if len(concurrencyStore[key]) == 0 {
+		delete(concurrencyStore, key)
+	}
+}
+
+// AcquireTokenConcurrency takes one of the concurrent request slots of a token, the returned lease has
+// to be passed to ReleaseTokenConcurrency once the request is done
+func AcquireTokenConcurrency(tokenId int, limit int) (string, bool) {
+	key := fmt.Sprintf("tokenConcurrency:%d", tokenId)
+	lease := GetUUID()
+	now := time.Now().UnixMilli()
+	if RedisEnabled {
+		result, err := RDB.Eval(context.Background(), acquireConcurrencyScript, []string{key},
+			now, tokenConcurrencyExpiration.Milliseconds(), limit, lease).Int()
+		if err != nil {
+			SysError("token concurrency limit: " + err.Error())
+			return "", true
+		}
+		return lease, result == 1
+	}
+	return lease, memoryAcquireConcurrency(key, lease, limit, now)
+}
+
+func ReleaseTokenConcurrency(tokenId int, lease string) {
+	if lease == "" {
+		return
+	}
+	key := fmt.Sprintf("tokenConcurrency:%d", tokenId)
+	if RedisEnabled {
+		RDB.ZRem(context.Background(), key, lease)
+		return
+	}
+	memoryReleaseConcurrency(key, lease)
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestMemorySlidingWindow(t *testing.T) {
+	window := TokenRateLimitWindow.Milliseconds()
+	type call struct {
+		at         int64
+		weight     int
+		wantOk     bool
+		wantUsed   int
+		wantOldest int64
+	}
+	tests := []struct {
+		name  string
+		limit int
+		calls []call
+	}{
+		{"rpm within the limit", 3, []call{
+			{0, 1, true, 1, 0},
+			{10, 1, true, 2, 0},
+			{20, 1, true, 3, 0},
+			{30, 1, false, 3, 0},
+		}},
+		{"rpm frees up after the window", 2, []call{
+			{0, 1, true, 1, 0},
+			{100, 1, true, 2, 0},
+			{window - 1, 1, false, 2, 0},
+			{window, 1, true, 2, 100},
+			{window + 100, 1, true, 2, window},
+		}},
+		{"tpm is read with a zero weight", 0, []call{
+			{0, 500, true, 500, 0},
+			{10, 700, true, 1200, 0},
+			{20, 0, true, 1200, 0},
+			{window, 0, true, 700, 10},
+			{window + 10, 0, true, 0, window + 10},
+		}},
+		{"weight above the limit", 100, []call{
+			{0, 60, true, 60, 0},
+			{10, 50, false, 60, 0},
+			{20, 40, true, 100, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "test:" + tt.name
+			defer delete(windowStore, key)
+			for i, c := range tt.calls {
+				ok, used, oldest := memorySlidingWindow(key, tt.limit, c.weight, c.at)
+				if ok != c.wantOk || used != c.wantUsed || oldest != c.wantOldest {
+					t.Errorf("call %d at %d = %v, %d, %d, want %v, %d, %d", i, c.at, ok, used, oldest, c.wantOk, c.wantUsed, c.wantOldest)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryConcurrencyLeases(t *testing.T) {
+	key := "test:concurrency"
+	defer delete(concurrencyStore, key)
+	expiration := tokenConcurrencyExpiration.Milliseconds()
+	tests := []struct {
+		name    string
+		lease   string
+		at      int64
+		release string
+		want    bool
+	}{
+		{"first slot", "a", 0, "", true},
+		{"second slot", "b", 10, "", true},
+		{"limit reached", "c", 20, "", false},
+		{"released slot is free again", "d", 30, "a", true},
+		{"releasing an unknown lease frees nothing", "e", 40, "x", false},
+		{"stale lease is dropped", "f", expiration + 10, "", true},
+		{"stale lease dropped once", "g", expiration + 20, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.release != "" {
+				memoryReleaseConcurrency(key, tt.release)
+			}
+			if got := memoryAcquireConcurrency(key, tt.lease, 2, tt.at); got != tt.want {
+				t.Errorf("acquire %s at %d = %v, want %v", tt.lease, tt.at, got, tt.want)
+			}
+		})
+	}
+}
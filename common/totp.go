@@ -0,0 +1,95 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP follows RFC 6238 with the defaults authenticator apps expect: sha1, 6 digits and 30 second steps
+const totpPeriod = 30
+const totpDigits = 6
+
+// totpSkew is how many steps a code may lag behind or run ahead of the server clock
+const totpSkew = 1
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// GetTOTPURI returns the otpauth uri authenticator apps enroll the secret with
+func GetTOTPURI(account string, secret string) string {
+	label := url.PathEscape(SystemName + ":" + account)
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", SystemName)
+	values.Set("digits", fmt.Sprintf("%d", totpDigits))
+	values.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+func getTOTPCode(key []byte, step int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// ValidateTOTP checks the code against the secret and returns the time step it belongs to,
+// callers reject steps that were already used so a code cannot be replayed
+func ValidateTOTP(secret string, code string) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(getTOTPCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns single use codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(count int) ([]string, error) {
+	const chars = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, 0, count)
+	buf := make([]byte, 10)
+	for i := 0; i < count; i++ {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		code := make([]byte, 0, 11)
+		for j, b := range buf {
+			if j == 5 {
+				code = append(code, '-')
+			}
+			code = append(code, chars[int(b)%len(chars)])
+		}
+		codes = append(codes, string(code))
+	}
+	return codes, nil
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetTOTPCode(t *testing.T) {
+	// the sha1 vectors of RFC 6238, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := getTOTPCode(key, tt.time/totpPeriod); got != tt.want {
+			t.Errorf("getTOTPCode at %d = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := time.Now().Unix() / totpPeriod
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		step   int64
+		want   bool
+	}{
+		{"current step", secret, getTOTPCode(key, current), current, true},
+		{"previous step", secret, getTOTPCode(key, current-1), current - 1, true},
+		{"next step", secret, getTOTPCode(key, current+1), current + 1, true},
+		{"lower case secret", strings.ToLower(secret), getTOTPCode(key, current), current, true},
+		{"spaces in code", secret, getTOTPCode(key, current)[:3] + " " + getTOTPCode(key, current)[3:], current, true},
+		{"too old", secret, getTOTPCode(key, current-3), 0, false},
+		{"too new", secret, getTOTPCode(key, current+3), 0, false},
+		{"short code", secret, "12345", 0, false},
+		{"long code", secret, "1234567", 0, false},
+		{"invalid secret", "not base32!", "123456", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the codes of far steps may collide with a near one by chance
+			if !tt.want && tt.code != "" && len(tt.code) == totpDigits && tt.secret == secret {
+				for step := current - totpSkew; step <= current+totpSkew; step++ {
+					if getTOTPCode(key, step) == tt.code {
+						t.Skip("code collides with a valid step")
+					}
+				}
+			}
+			step, ok := ValidateTOTP(tt.secret, tt.code)
+			if ok != tt.want || (ok && step != tt.step) {
+				t.Errorf("ValidateTOTP(%q) = %d, %v, want %d, %v", tt.code, step, ok, tt.step, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("recovery code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
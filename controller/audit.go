@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditExportLimit caps how many audit logs one export returns, narrow the time range to export more
+const auditExportLimit = 10000
+
+func getAuditLogFilter(c *gin.Context) *model.AuditLogFilter {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return &model.AuditLogFilter{
+		Username:       c.Query("username"),
+		Action:         c.Query("action"),
+		TargetType:     c.Query("target_type"),
+		TargetId:       c.Query("target_id"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+// setAuditChange tells the audit middleware which object the request changed and how,
+// before is nil for creations and after is nil for deletions
+func setAuditChange(c *gin.Context, targetId any, before any, after any) {
+	c.Set("audit_target_id", fmt.Sprintf("%v", targetId))
+	if before != nil {
+		c.Set("audit_before", before)
+	}
+	if after != nil {
+		c.Set("audit_after", after)
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	if p < 0 {
+		p = 0
+	}
+	if pageSize <= 0 {
+		pageSize = common.ItemsPerPage
+	}
+	logs, total, err := model.GetAuditLogs(getAuditLogFilter(c), p*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+		"total":   total,
+	})
+}
+
+// csvCell keeps spreadsheet apps from running a value as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@") {
+		return "'" + value
+	}
+	return value
+}
+
+// ExportAuditLogs downloads the audit logs matching the filter as csv
+func ExportAuditLogs(c *gin.Context) {
+	logs, _, err := model.GetAuditLogs(getAuditLogFilter(c), 0, auditExportLimit)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit-%s.csv\"", time.Now().Format("20060102150405")))
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"id", "time", "user_id", "username", "action", "target_type", "target_id", "success", "ip", "request_id", "changes"})
+	for _, log := range logs {
+		_ = writer.Write([]string{
+			strconv.Itoa(log.Id),
+			time.Unix(log.CreatedAt, 0).Format(time.RFC3339),
+			strconv.Itoa(log.UserId),
+			csvCell(log.Username),
+			log.Action,
+			log.TargetType,
+			csvCell(log.TargetId),
+			strconv.FormatBool(log.Success),
+			log.Ip,
+			log.RequestId,
+			csvCell(log.Changes),
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,514 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const batchMaxRequests = 50000
+
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+}
+
+func optionalTimestamp(timestamp int64) *int64 {
+	if timestamp == 0 {
+		return nil
+	}
+	return &timestamp
+}
+
+func optionalString(str string) *string {
+	if str == "" {
+		return nil
+	}
+	return &str
+}
+
+func batch2OpenAIBatch(batch *model.Batch) dto.OpenAIBatch {
+	openAIBatch := dto.OpenAIBatch{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		OutputFileId:     optionalString(batch.OutputFileId),
+		ErrorFileId:      optionalString(batch.ErrorFileId),
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.BatchRequestCounts{
+			Total:     batch.RequestTotal,
+			Completed: batch.RequestCompleted,
+			Failed:    batch.RequestFailed,
+		},
+	}
+	if batch.Errors != "" {
+		_ = json.Unmarshal([]byte(batch.Errors), &openAIBatch.Errors)
+	}
+	if batch.Metadata != "" {
+		_ = json.Unmarshal([]byte(batch.Metadata), &openAIBatch.Metadata)
+	}
+	return openAIBatch
+}
+
+func CreateBatch(c *gin.Context) {
+	var batchRequest dto.BatchRequest
+	err := c.ShouldBindJSON(&batchRequest)
+	if err != nil {
+		relayInvalidRequest(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !batchEndpoints[batchRequest.Endpoint] {
+		relayInvalidRequest(c, http.StatusBadRequest, fmt.Sprintf("unsupported endpoint: '%s'", batchRequest.Endpoint))
+		return
+	}
+	if batchRequest.CompletionWindow != "24h" {
+		relayInvalidRequest(c, http.StatusBadRequest, "completion_window must be '24h'")
+		return
+	}
+	userId := c.GetInt("id")
+	inputFile, err := model.GetFileByFileId(userId, batchRequest.InputFileId)
+	if err != nil {
+		relayInvalidRequest(c, http.StatusBadRequest, fmt.Sprintf("No such File object: %s", batchRequest.InputFileId))
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		relayInvalidRequest(c, http.StatusBadRequest, "input file must be uploaded with purpose 'batch'")
+		return
+	}
+	metadata := ""
+	if len(batchRequest.Metadata) > 0 {
+		jsonData, _ := json.Marshal(batchRequest.Metadata)
+		metadata = string(jsonData)
+	}
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetUUID(),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		Endpoint:         batchRequest.Endpoint,
+		InputFileId:      inputFile.FileId,
+		CompletionWindow: batchRequest.CompletionWindow,
+		Status:           common.BatchStatusValidating,
+		Metadata:         metadata,
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*60*60,
+	}
+	err = batch.Insert()
+	if err != nil {
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, batch2OpenAIBatch(batch))
+}
+
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	batches, err := model.GetUserBatches(c.GetInt("id"), 0, limit)
+	if err != nil {
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	data := make([]dto.OpenAIBatch, 0, len(batches))
+	for _, batch := range batches {
+		data = append(data, batch2OpenAIBatch(batch))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"has_more": false,
+	})
+}
+
+func RetrieveBatch(c *gin.Context) {
+	batch, err := model.GetBatchByBatchId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		relayInvalidRequest(c, http.StatusNotFound, fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	c.JSON(http.StatusOK, batch2OpenAIBatch(batch))
+}
+
+func CancelBatch(c *gin.Context) {
+	batch, err := model.GetBatchByBatchId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		relayInvalidRequest(c, http.StatusNotFound, fmt.Sprintf("No such Batch object: %s", c.Param("id")))
+		return
+	}
+	cancelled, err := batch.Cancel()
+	if err != nil {
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !cancelled {
+		relayInvalidRequest(c, http.StatusConflict, fmt.Sprintf("Cannot cancel a batch with status '%s'", batch.Status))
+		return
+	}
+	batch, _ = model.GetBatchById(batch.Id)
+	c.JSON(http.StatusOK, batch2OpenAIBatch(batch))
+}
+
+// AutomaticallyProcessBatches picks up unfinished batches, including the
+// ones interrupted by a restart, and executes them in the background
+func AutomaticallyProcessBatches() {
+	running := &sync.Map{}
+	for {
+		time.Sleep(time.Duration(10) * time.Second)
+		for _, batch := range model.GetAllUnfinishedBatches() {
+			if _, loaded := running.LoadOrStore(batch.Id, true); loaded {
+				continue
+			}
+			batch := batch
+			common.SafeGoroutine(func() {
+				defer running.Delete(batch.Id)
+				processBatch(batch)
+			})
+		}
+	}
+}
+
+func processBatch(batch *model.Batch) {
+	ctx := context.WithValue(context.Background(), common.RequestIdKey, batch.BatchId)
+	var lines []dto.BatchInputLine
+	if batch.Status == common.BatchStatusValidating || batch.Status == common.BatchStatusInProgress {
+		var batchErrors []dto.BatchErrorLine
+		var err error
+		lines, batchErrors, err = readBatchInput(batch)
+		if err != nil {
+			batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "invalid_input_file", Message: err.Error()})
+		}
+		if len(batchErrors) > 0 {
+			common.LogError(ctx, fmt.Sprintf("batch validation failed: %v", batchErrors))
+			jsonData, _ := json.Marshal(dto.BatchErrors{Object: "list", Data: batchErrors})
+			from := batch.Status
+			batch.Errors = string(jsonData)
+			batch.Status = common.BatchStatusFailed
+			batch.FailedAt = common.GetTimestamp()
+			ok, err := batch.UpdateStatus(from, "errors", "failed_at")
+			if err != nil {
+				common.LogError(ctx, "update batch failed: "+err.Error())
+				return
+			}
+			if ok {
+				return
+			}
+			// cancelled in the meantime, it is finalized as cancelled below
+		}
+	}
+	if batch.Status == common.BatchStatusValidating {
+		batch.RequestTotal = len(lines)
+		batch.Status = common.BatchStatusInProgress
+		batch.InProgressAt = common.GetTimestamp()
+		if _, err := batch.UpdateStatus(common.BatchStatusValidating, "request_total", "in_progress_at"); err != nil {
+			common.LogError(ctx, "update batch failed: "+err.Error())
+			return
+		}
+	}
+	if batch.Status == common.BatchStatusInProgress {
+		common.LogInfo(ctx, fmt.Sprintf("开始执行批处理，共 %d 个请求，已完成 %d 个", batch.RequestTotal, batch.RequestCompleted+batch.RequestFailed))
+		err := executeBatch(ctx, batch, lines)
+		if err != nil {
+			common.LogError(ctx, "execute batch failed: "+err.Error())
+			return
+		}
+	}
+	err := finalizeBatch(batch)
+	if err != nil {
+		common.LogError(ctx, "finalize batch failed: "+err.Error())
+	}
+}
+
+func readBatchInput(batch *model.Batch) ([]dto.BatchInputLine, []dto.BatchErrorLine, error) {
+	inputFile, err := model.GetFileByFileId(batch.UserId, batch.InputFileId)
+	if err != nil {
+		return nil, nil, errors.New("input file not found")
+	}
+	reader, err := service.GetFileStore().Open(inputFile.StoreName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	var lines []dto.BatchInputLine
+	var batchErrors []dto.BatchErrorLine
+	customIds := make(map[string]bool)
+	bufReader := bufio.NewReader(reader)
+	for lineNumber := 1; ; lineNumber++ {
+		data, err := bufReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			var line dto.BatchInputLine
+			if jsonErr := json.Unmarshal(data, &line); jsonErr != nil {
+				batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "invalid_json_line", Message: "This line is not parseable as valid JSON.", Line: lineNumber})
+			} else if line.CustomId == "" || customIds[line.CustomId] {
+				batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "invalid_custom_id", Message: "custom_id must be present and unique.", Param: "custom_id", Line: lineNumber})
+			} else if line.Method != http.MethodPost {
+				batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "invalid_method", Message: "Only POST is supported.", Param: "method", Line: lineNumber})
+			} else if line.Url != batch.Endpoint {
+				batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "mismatched_url", Message: "The url must match the endpoint of the batch.", Param: "url", Line: lineNumber})
+			} else {
+				customIds[line.CustomId] = true
+				lines = append(lines, line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(lines)+len(batchErrors) == 0 {
+		batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "empty_file", Message: "The input file is empty."})
+	}
+	if len(lines) > batchMaxRequests {
+		batchErrors = append(batchErrors, dto.BatchErrorLine{Code: "too_many_requests", Message: fmt.Sprintf("A batch may contain at most %d requests.", batchMaxRequests)})
+	}
+	return lines, batchErrors, nil
+}
+
+func batchOutputName(batch *model.Batch) string {
+	return batch.BatchId + "_output.jsonl"
+}
+
+func batchErrorName(batch *model.Batch) string {
+	return batch.BatchId + "_error.jsonl"
+}
+
+// executeBatch runs the remaining requests in chunks of BatchConcurrency,
+// the results of a chunk are appended before the counters are saved so an
+// interrupted batch resumes at the first unfinished chunk
+func executeBatch(ctx context.Context, batch *model.Batch, lines []dto.BatchInputLine) error {
+	store := service.GetFileStore()
+	concurrency := common.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	for start := batch.RequestCompleted + batch.RequestFailed; start < len(lines); start += concurrency {
+		current, err := model.GetBatchById(batch.Id)
+		if err != nil {
+			return err
+		}
+		if current.Status == common.BatchStatusCancelling {
+			batch.Status = current.Status
+			batch.CancellingAt = current.CancellingAt
+			return nil
+		}
+		if common.GetTimestamp() > batch.ExpiresAt {
+			batch.Status = common.BatchStatusExpired
+			batch.ExpiredAt = common.GetTimestamp()
+			_, err = batch.UpdateStatus(common.BatchStatusInProgress, "expired_at")
+			return err
+		}
+
+		end := start + concurrency
+		if end > len(lines) {
+			end = len(lines)
+		}
+		results := make([]*dto.BatchOutputLine, end-start)
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i-start] = executeBatchLine(batch, lines[i])
+			}(i)
+		}
+		wg.Wait()
+
+		var output, errorOutput bytes.Buffer
+		for _, result := range results {
+			jsonData, _ := json.Marshal(result)
+			if result.Error == nil && result.Response.StatusCode/100 == 2 {
+				output.Write(jsonData)
+				output.WriteByte('\n')
+				batch.RequestCompleted++
+			} else {
+				errorOutput.Write(jsonData)
+				errorOutput.WriteByte('\n')
+				batch.RequestFailed++
+			}
+		}
+		if output.Len() > 0 {
+			if err := store.Append(batchOutputName(batch), output.Bytes()); err != nil {
+				return err
+			}
+		}
+		if errorOutput.Len() > 0 {
+			if err := store.Append(batchErrorName(batch), errorOutput.Bytes()); err != nil {
+				return err
+			}
+		}
+		if err := batch.UpdateProgress(); err != nil {
+			return err
+		}
+	}
+	common.LogInfo(ctx, fmt.Sprintf("批处理执行完成，成功 %d 个，失败 %d 个", batch.RequestCompleted, batch.RequestFailed))
+	batch.Status = common.BatchStatusFinalizing
+	batch.FinalizingAt = common.GetTimestamp()
+	_, err := batch.UpdateStatus(common.BatchStatusInProgress, "finalizing_at")
+	return err
+}
+
+type batchContextKey struct{}
+
+var batchEngine *gin.Engine
+var batchEngineOnce sync.Once
+
+// getBatchEngine returns the engine batch requests are replayed through, it
+// shares the relay pipeline with the public routes except for authentication
+func getBatchEngine() *gin.Engine {
+	batchEngineOnce.Do(func() {
+		batchEngine = gin.New()
+		batchEngine.Use(gin.Recovery(), middleware.RequestId(), batchTokenAuth)
+		for endpoint := range batchEndpoints {
+			batchEngine.POST(endpoint, middleware.Distribute(), Relay)
+		}
+	})
+	return batchEngine
+}
+
+func batchTokenAuth(c *gin.Context) {
+	batch := c.Request.Context().Value(batchContextKey{}).(*model.Batch)
+	token, err := model.GetTokenById(batch.TokenId)
+	if err == nil {
+		err = token.CheckStatus()
+	}
+	if err == nil {
+		var userEnabled bool
+		userEnabled, err = model.CacheIsUserEnabled(token.UserId)
+		if err == nil && !userEnabled {
+			err = errors.New("用户已被封禁")
+		}
+	}
+	if err != nil {
+		relayInvalidRequest(c, http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+	middleware.SetupContextForToken(c, token)
+	c.Set("batch_id", batch.BatchId)
+	c.Next()
+}
+
+func executeBatchLine(batch *model.Batch, line dto.BatchInputLine) *dto.BatchOutputLine {
+	result := &dto.BatchOutputLine{
+		Id:       "batch_req_" + common.GetUUID(),
+		CustomId: line.CustomId,
+	}
+	var body struct {
+		Stream bool `json:"stream"`
+	}
+	if err := json.Unmarshal(line.Body, &body); err != nil {
+		result.Error = &dto.BatchOutputError{Code: "invalid_request", Message: "body must be a JSON object"}
+		return result
+	}
+	if body.Stream {
+		result.Error = &dto.BatchOutputError{Code: "invalid_request", Message: "stream is not supported in batch requests"}
+		return result
+	}
+
+	ctx := context.WithValue(context.Background(), batchContextKey{}, batch)
+	req, err := http.NewRequestWithContext(ctx, line.Method, line.Url, bytes.NewReader(line.Body))
+	if err != nil {
+		result.Error = &dto.BatchOutputError{Code: "invalid_request", Message: err.Error()}
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	getBatchEngine().ServeHTTP(recorder, req)
+
+	responseBody := recorder.Body.Bytes()
+	if !json.Valid(responseBody) {
+		responseBody, _ = json.Marshal(string(responseBody))
+	}
+	result.Response = &dto.BatchOutputResponse{
+		StatusCode: recorder.Code,
+		RequestId:  recorder.Header().Get(common.RequestIdKey),
+		Body:       responseBody,
+	}
+	return result
+}
+
+// finalizeBatch publishes the output and error files and moves the batch to its final status
+func finalizeBatch(batch *model.Batch) error {
+	now := common.GetTimestamp()
+	if batch.OutputFileId == "" {
+		file, err := createBatchResultFile(batch, batchOutputName(batch), now)
+		if err != nil {
+			return err
+		}
+		if file != nil {
+			batch.OutputFileId = file.FileId
+		}
+	}
+	if batch.ErrorFileId == "" {
+		file, err := createBatchResultFile(batch, batchErrorName(batch), now)
+		if err != nil {
+			return err
+		}
+		if file != nil {
+			batch.ErrorFileId = file.FileId
+		}
+	}
+	from := batch.Status
+	switch batch.Status {
+	case common.BatchStatusFinalizing:
+		batch.Status = common.BatchStatusCompleted
+		batch.CompletedAt = now
+	case common.BatchStatusCancelling:
+		batch.Status = common.BatchStatusCancelled
+		batch.CancelledAt = now
+	}
+	ok, err := batch.UpdateStatus(from, "output_file_id", "error_file_id", "completed_at", "cancelled_at")
+	if err == nil && !ok {
+		err = fmt.Errorf("batch status changed to %s while finalizing", batch.Status)
+	}
+	return err
+}
+
+func createBatchResultFile(batch *model.Batch, storeName string, now int64) (*model.File, error) {
+	size, err := service.GetFileStore().Size(storeName)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	file := &model.File{
+		FileId:    "file-" + common.GetUUID(),
+		UserId:    batch.UserId,
+		Filename:  storeName,
+		Purpose:   "batch_output",
+		Bytes:     size,
+		StoreName: storeName,
+		CreatedAt: now,
+	}
+	return file, file.Insert()
+}
@@ -93,7 +93,19 @@ func GetResponseBody(method, url string, channel *model.Channel, headers http.He
 	for k := range headers {
 		req.Header.Add(k, headers.Get(k))
 	}
-	res, err := service.GetHttpClient().Do(req)
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	err = service.ApplyHeaderOverride(req.Header, channel.GetHeaderOverride(), keys[0], "")
+	if err != nil {
+		return nil, err
+	}
+	client, err := service.GetHttpClientWithProxy(channel.GetProxy())
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -201,6 +213,12 @@ func updateChannelAIGC2DBalance(channel *model.Channel) (float64, error) {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	// the balance of a multi-key channel is the one of its first key
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return 0, err
+	}
+	channel.Key = keys[0]
 	baseURL := common.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
 		channel.BaseURL = &baseURL
@@ -23,7 +23,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func testChannel(channel *model.Channel, testModel string) (err error, openaiErr *dto.OpenAIError) {
+func testChannel(channel *model.Channel, key string, testModel string) (err error, openaiErr *dto.OpenAIError) {
 	if channel.Type == common.ChannelTypeMidjourney {
 		return errors.New("midjourney channel test is not supported"), nil
 	}
@@ -35,10 +35,12 @@ func testChannel(channel *model.Channel, testModel string) (err error, openaiErr
 		Body:   nil,
 		Header: make(http.Header),
 	}
-	c.Request.Header.Set("Authorization", "Bearer "+channel.Key)
+	c.Request.Header.Set("Authorization", "Bearer "+key)
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("channel", channel.Type)
 	c.Set("base_url", channel.GetBaseURL())
+	c.Set("proxy", channel.GetProxy())
+	c.Set("header_override", channel.GetHeaderOverride())
 	switch channel.Type {
 	case common.ChannelTypeAzure:
 		c.Set("api_version", channel.Other)
@@ -65,18 +67,12 @@ func testChannel(channel *model.Channel, testModel string) (err error, openaiErr
 			testModel = adaptor.GetModelList()[0]
 		}
 	} else {
-		modelMapping := *channel.ModelMapping
-		if modelMapping != "" && modelMapping != "{}" {
-			modelMap := make(map[string]string)
-			err := json.Unmarshal([]byte(modelMapping), &modelMap)
-			if err != nil {
-				openaiErr := service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError).Error
-				return err, &openaiErr
-			}
-			if modelMap[testModel] != "" {
-				testModel = modelMap[testModel]
-			}
+		modelMapping, err := model.GetModelMapping(channel.GetModelMapping())
+		if err != nil {
+			openaiErr := service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError).Error
+			return err, &openaiErr
 		}
+		testModel, _ = modelMapping.Map(common.ResolveModelAlias(testModel))
 	}
 
 	request := buildTestRequest()
@@ -154,8 +150,16 @@ func TestChannel(c *gin.Context) {
 		return
 	}
 	testModel := c.Query("model")
+	key, _, err := model.SelectChannelKey(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	tik := time.Now()
-	err, _ = testChannel(channel, testModel)
+	err, _ = testChannel(channel, key, testModel)
 	tok := time.Now()
 	milliseconds := tok.Sub(tik).Milliseconds()
 	go channel.UpdateResponseTime(milliseconds)
@@ -201,8 +205,16 @@ func testAllChannels(notify bool) error {
 	go func() {
 		for _, channel := range channels {
 			isChannelEnabled := channel.Status == common.ChannelStatusEnabled
+			key, keyId, err := model.SelectChannelKey(channel)
+			if err != nil {
+				common.SysError(err.Error())
+				if isChannelEnabled {
+					service.DisableChannel(channel.Id, channel.Name, err.Error())
+				}
+				continue
+			}
 			tik := time.Now()
-			err, openaiErr := testChannel(channel, "")
+			err, openaiErr := testChannel(channel, key, "")
 			tok := time.Now()
 			milliseconds := tok.Sub(tik).Milliseconds()
 
@@ -220,7 +232,13 @@ func testAllChannels(notify bool) error {
 				ban = false
 			}
 			if isChannelEnabled && service.ShouldDisableChannel(openaiErr, -1) && ban {
-				service.DisableChannel(channel.Id, channel.Name, err.Error())
+				if keyId != 0 {
+					service.DisableChannelKey(channel.Id, keyId, channel.Name, err.Error())
+				} else if common.CircuitBreakerEnabled {
+					model.TripChannelBreaker(channel.Id)
+				} else {
+					service.DisableChannel(channel.Id, channel.Name, err.Error())
+				}
 			}
 			if !isChannelEnabled && service.ShouldEnableChannel(err, openaiErr, channel.Status) {
 				service.EnableChannel(channel.Id, channel.Name)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/service"
 	"strconv"
 	"strings"
 )
@@ -90,7 +91,15 @@ func FetchUpstreamModels(c *gin.Context) {
 		return
 	}
 	url := fmt.Sprintf("%s/v1/models", *channel.BaseURL)
-	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	key, _, err := model.SelectChannelKey(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(key))
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -186,6 +195,16 @@ func GetChannel(c *gin.Context) {
 	return
 }
 
+// GetChannelScores returns the rolling latency and error scores the adaptive channel selection uses
+func GetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelScores(channelId),
+	})
+}
+
 func AddChannel(c *gin.Context) {
 	channel := model.Channel{}
 	err := c.ShouldBindJSON(&channel)
@@ -196,8 +215,20 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	err = validateChannel(&channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	channel.CreatedTime = common.GetTimestamp()
+	// a multi-key channel keeps all its keys, otherwise one channel is added for each key
 	keys := strings.Split(channel.Key, "\n")
+	if channel.IsMultiKey() {
+		keys = []string{channel.Key}
+	}
 	channels := make([]model.Channel, 0, len(keys))
 	for _, key := range keys {
 		if key == "" {
@@ -222,10 +253,48 @@ func AddChannel(c *gin.Context) {
 	return
 }
 
+// validateChannel checks the proxy, the model patterns, the model mapping and the overrides of a channel before it is saved
+func validateChannel(channel *model.Channel) error {
+	if channel.GetProxy() != "" {
+		_, err := service.ParseProxyURL(channel.GetProxy())
+		if err != nil {
+			return fmt.Errorf("无效的代理地址：%s", err.Error())
+		}
+	}
+	for _, name := range strings.Split(channel.Models, ",") {
+		if len(name) > 64 {
+			return fmt.Errorf("模型名称 %s 过长", name)
+		}
+		if common.IsModelPattern(name) {
+			_, err := common.CompileModelPattern(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := common.ParseModelMapping(channel.GetModelMapping())
+	if err != nil {
+		return fmt.Errorf("无效的模型重定向：%s", err.Error())
+	}
+	err = service.CheckParamOverride(channel.GetParamOverride())
+	if err != nil {
+		return fmt.Errorf("无效的参数覆盖：%s", err.Error())
+	}
+	err = service.CheckHeaderOverride(channel.GetHeaderOverride())
+	if err != nil {
+		return fmt.Errorf("无效的请求头覆盖：%s", err.Error())
+	}
+	return nil
+}
+
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	origin, err := model.GetChannelById(id, true)
+	if err == nil {
+		setAuditChange(c, id, origin, nil)
+	}
 	channel := model.Channel{Id: id}
-	err := channel.Delete()
+	err = channel.Delete()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -297,6 +366,15 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	err = validateChannel(&channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	origin, _ := model.GetChannelById(channel.Id, true)
 	err = channel.Update()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -305,6 +383,9 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	model.ResetChannelBreakers(channel.Id)
+	updated, _ := model.GetChannelById(channel.Id, true)
+	setAuditChange(c, channel.Id, origin, updated)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -312,3 +393,129 @@ func UpdateChannel(c *gin.Context) {
 	})
 	return
 }
+
+// GetChannelKeys returns the status and usage of every key of a multi-key channel
+func GetChannelKeys(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	keys, err := model.GetChannelKeyStatuses(channel)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    keys,
+	})
+}
+
+// GetChannelKey reveals the key of a channel, the route is guarded by 2fa
+func GetChannelKey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	key, err := channel.GetPlainKey()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// revealing a key is recorded even though nothing changes
+	setAuditChange(c, id, nil, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    key,
+	})
+}
+
+// ReencryptChannelKeys encrypts the channel keys stored in plain text or under the old master key with the current one
+func ReencryptChannelKeys(c *gin.Context) {
+	count, err := model.ReencryptChannelKeys()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	// the 2fa secrets are sealed with the same master key
+	_, err = model.ReencryptTwoFASecrets()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+type UpdateChannelKeyRequest struct {
+	KeyId  int `json:"key_id"`
+	Status int `json:"status"`
+}
+
+// UpdateChannelKey enables or disables one key of a multi-key channel
+func UpdateChannelKey(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	req := UpdateChannelKeyRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Status != common.ChannelStatusEnabled && req.Status != common.ChannelStatusManuallyDisabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的状态",
+		})
+		return
+	}
+	key, err := model.GetChannelKeyById(req.KeyId)
+	if err != nil || key.ChannelId != id {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "密钥不存在",
+		})
+		return
+	}
+	err = model.UpdateChannelKeyStatusById(key.Id, req.Status, "")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
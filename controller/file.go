@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+)
+
+var filePurposes = map[string]bool{
+	"batch":      true,
+	"fine-tune":  true,
+	"assistants": true,
+	"vision":     true,
+	"user_data":  true,
+}
+
+func relayInvalidRequest(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": dto.OpenAIError{
+			Message: common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
+			Type:    "invalid_request_error",
+		},
+	})
+}
+
+func file2OpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    "processed",
+	}
+}
+
+const maxFilePageSize = 100
+
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > maxFilePageSize {
+		limit = maxFilePageSize
+	}
+	afterId := 0
+	if after := c.Query("after"); after != "" {
+		file, err := model.GetFileByFileId(c.GetInt("id"), after)
+		if err != nil {
+			relayInvalidRequest(c, http.StatusBadRequest, fmt.Sprintf("No such File object: %s", after))
+			return
+		}
+		afterId = file.Id
+	}
+	// one more file than asked for tells whether there are more
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), afterId, limit+1)
+	if err != nil {
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	data := make([]dto.OpenAIFile, 0, len(files))
+	for _, file := range files {
+		data = append(data, file2OpenAIFile(file))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"data":     data,
+		"has_more": hasMore,
+	})
+}
+
+// isBodyTooLarge reports whether reading the body failed because it is larger than http.MaxBytesReader allows
+func isBodyTooLarge(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
+
+func UploadFile(c *gin.Context) {
+	maxSize := int64(common.FileMaxSize) << 20
+	tooLarge := fmt.Sprintf("file is larger than the maximum size of %d MB", common.FileMaxSize)
+	// the multipart encoding adds a little to the size of the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	_, err := c.MultipartForm()
+	if err != nil {
+		if isBodyTooLarge(err) {
+			relayInvalidRequest(c, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		relayInvalidRequest(c, http.StatusBadRequest, "invalid multipart form: "+err.Error())
+		return
+	}
+	purpose := c.PostForm("purpose")
+	if !filePurposes[purpose] {
+		relayInvalidRequest(c, http.StatusBadRequest, fmt.Sprintf("invalid purpose: '%s'", purpose))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		relayInvalidRequest(c, http.StatusBadRequest, "field file is required")
+		return
+	}
+	if fileHeader.Size > maxSize {
+		relayInvalidRequest(c, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	if common.FileUserQuota > 0 {
+		used, err := model.GetUserFilesBytes(c.GetInt("id"))
+		if err != nil {
+			relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if used+fileHeader.Size > int64(common.FileUserQuota)<<20 {
+			relayInvalidRequest(c, http.StatusForbidden, fmt.Sprintf("the files of the user would exceed the storage quota of %d MB, delete some files first", common.FileUserQuota))
+			return
+		}
+	}
+	src, err := fileHeader.Open()
+	if err != nil {
+		relayInvalidRequest(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer src.Close()
+
+	fileId := "file-" + common.GetUUID()
+	size, err := service.GetFileStore().Save(fileId, src)
+	if err != nil {
+		common.LogError(c.Request.Context(), "save file failed: "+err.Error())
+		relayInvalidRequest(c, http.StatusInternalServerError, "save file failed")
+		return
+	}
+	file := &model.File{
+		FileId:    fileId,
+		UserId:    c.GetInt("id"),
+		Filename:  fileHeader.Filename,
+		Purpose:   purpose,
+		Bytes:     size,
+		StoreName: fileId,
+		CreatedAt: common.GetTimestamp(),
+	}
+	err = file.Insert()
+	if err != nil {
+		_ = service.GetFileStore().Delete(fileId)
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, file2OpenAIFile(file))
+}
+
+func getUserFile(c *gin.Context) *model.File {
+	file, err := model.GetFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		relayInvalidRequest(c, http.StatusNotFound, fmt.Sprintf("No such File object: %s", c.Param("id")))
+		return nil
+	}
+	return file
+}
+
+func RetrieveFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	c.JSON(http.StatusOK, file2OpenAIFile(file))
+}
+
+func RetrieveFileContent(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	reader, err := service.GetFileStore().Open(file.StoreName)
+	if err != nil {
+		common.LogError(c.Request.Context(), "open file failed: "+err.Error())
+		relayInvalidRequest(c, http.StatusInternalServerError, "open file failed")
+		return
+	}
+	defer reader.Close()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": disposition,
+	})
+}
+
+func DeleteFile(c *gin.Context) {
+	file := getUserFile(c)
+	if file == nil {
+		return
+	}
+	err := file.Delete()
+	if err != nil {
+		relayInvalidRequest(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = service.GetFileStore().Delete(file.StoreName)
+	if err != nil {
+		common.LogError(c.Request.Context(), "delete file failed: "+err.Error())
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleteResponse{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
@@ -91,8 +91,24 @@ func UpdateMidjourneyTaskBulk() {
 			// 使用带有超时的 context 创建新的请求
 			req = req.WithContext(ctx)
 			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
-			resp, err := service.GetHttpClient().Do(req)
+			key, _, err := model.SelectChannelKey(midjourneyChannel)
+			if err != nil {
+				cancel()
+				common.LogError(ctx, fmt.Sprintf("Get Task channel key error: %v", err))
+				continue
+			}
+			req.Header.Set("mj-api-secret", key)
+			err = service.ApplyHeaderOverride(req.Header, midjourneyChannel.GetHeaderOverride(), key, "")
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("Get Task header override error: %v", err))
+				continue
+			}
+			client, err := service.GetHttpClientWithProxy(midjourneyChannel.GetProxy())
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("Get Task http client error: %v", err))
+				continue
+			}
+			resp, err := client.Do(req)
 			if err != nil {
 				common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
 				continue
@@ -15,6 +15,7 @@ import (
 	"one-api/relay/channel/moonshot"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
+	"sort"
 )
 
 // https://platform.openai.com/docs/api-reference/models/list
@@ -146,7 +147,7 @@ func ListModels(c *gin.Context) {
 		})
 		return
 	}
-	models := model.GetGroupModels(user.Group)
+	models := listGroupModels(user.Group)
 	userOpenAiModels := make([]dto.OpenAIModels, 0)
 	permission := getPermission()
 	for _, s := range models {
@@ -170,6 +171,36 @@ func ListModels(c *gin.Context) {
 	})
 }
 
+// listGroupModels returns the models a group may request, the wildcard and regex models of its channels
+// cannot be listed, the aliases standing for a model the group is served are listed instead
+func listGroupModels(group string) []string {
+	groupModels := model.GetGroupModels(group)
+	models := make([]string, 0, len(groupModels))
+	patterns := make([]string, 0)
+	for _, name := range groupModels {
+		if common.IsModelPattern(name) {
+			patterns = append(patterns, name)
+		} else {
+			models = append(models, name)
+		}
+	}
+	aliases := make([]string, 0, len(common.ModelAliases))
+	for alias, target := range common.ModelAliases {
+		if common.StringsContains(models, alias) {
+			continue
+		}
+		served := common.StringsContains(models, target)
+		for _, pattern := range patterns {
+			served = served || common.ModelNameMatches(pattern, target)
+		}
+		if served {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return append(models, aliases...)
+}
+
 func ChannelListModels(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"success": true,
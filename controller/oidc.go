@@ -0,0 +1,465 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IdToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// the discovery document is fetched once per issuer and kept for an hour
+var oidcDiscoveryLock sync.Mutex
+var oidcDiscovery *OIDCDiscovery
+var oidcDiscoveryIssuer string
+var oidcDiscoveryTime time.Time
+
+var oidcClient = http.Client{
+	Timeout: 5 * time.Second,
+}
+
+func getOIDCDiscovery() (*OIDCDiscovery, error) {
+	issuer := common.OIDCIssuer
+	oidcDiscoveryLock.Lock()
+	defer oidcDiscoveryLock.Unlock()
+	if oidcDiscovery != nil && oidcDiscoveryIssuer == issuer && time.Since(oidcDiscoveryTime) < time.Hour {
+		return oidcDiscovery, nil
+	}
+	res, err := oidcClient.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		common.SysLog(err.Error())
+		return nil, errors.New("无法连接至 OIDC 服务器，请稍后重试！")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取 OIDC 配置失败，状态码：%d", res.StatusCode)
+	}
+	var discovery OIDCDiscovery
+	err = json.NewDecoder(res.Body).Decode(&discovery)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, errors.New("OIDC 配置非法，请检查 Issuer 设置")
+	}
+	oidcDiscovery = &discovery
+	oidcDiscoveryIssuer = issuer
+	oidcDiscoveryTime = time.Now()
+	return oidcDiscovery, nil
+}
+
+func getOIDCRedirectURI() string {
+	return strings.TrimSuffix(constant.ServerAddress, "/") + "/oauth/oidc"
+}
+
+// OIDCAuthURL returns the authorization url the browser is sent to, with a new state and nonce kept in the session
+func OIDCAuthURL(c *gin.Context) {
+	if !common.OIDCEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 OIDC 登录以及注册",
+		})
+		return
+	}
+	discovery, err := getOIDCDiscovery()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	state := common.GetRandomString(12)
+	nonce := common.GetRandomString(16)
+	session.Set("oauth_state", state)
+	session.Set("oidc_nonce", nonce)
+	err = session.Save()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", common.OIDCClientId)
+	values.Set("redirect_uri", getOIDCRedirectURI())
+	values.Set("scope", common.OIDCScopes)
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    discovery.AuthorizationEndpoint + separator + values.Encode(),
+	})
+}
+
+// parseIdToken returns the claims of the id token, the token comes straight from the token endpoint
+// over tls so its signature is not verified, its issuer, audience, expiry and nonce are checked, the nonce
+// of the session must not be empty
+func parseIdToken(idToken string, nonce string) (map[string]any, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("OIDC id_token 格式非法")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[string]any)
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != common.OIDCIssuer {
+		return nil, errors.New("OIDC id_token 的签发者不匹配")
+	}
+	audienceMatched := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audienceMatched = aud == common.OIDCClientId
+	case []any:
+		for _, item := range aud {
+			if item == common.OIDCClientId {
+				audienceMatched = true
+			}
+		}
+	}
+	if !audienceMatched {
+		return nil, errors.New("OIDC id_token 的受众不匹配")
+	}
+	if exp, ok := claims["exp"].(float64); !ok || int64(exp) < time.Now().Unix() {
+		return nil, errors.New("OIDC id_token 已过期")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, errors.New("OIDC id_token 的 nonce 不匹配")
+	}
+	return claims, nil
+}
+
+// getOIDCClaimsByCode exchanges the code for the tokens and returns the claims of the id token merged with the userinfo ones
+func getOIDCClaimsByCode(code string, nonce string) (map[string]any, error) {
+	if code == "" {
+		return nil, errors.New("无效的参数")
+	}
+	discovery, err := getOIDCDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", getOIDCRedirectURI())
+	values.Set("client_id", common.OIDCClientId)
+	values.Set("client_secret", common.OIDCClientSecret)
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := oidcClient.Do(req)
+	if err != nil {
+		common.SysLog(err.Error())
+		return nil, errors.New("无法连接至 OIDC 服务器，请稍后重试！")
+	}
+	defer res.Body.Close()
+	var tokenResponse OIDCTokenResponse
+	err = json.NewDecoder(res.Body).Decode(&tokenResponse)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK || tokenResponse.IdToken == "" {
+		return nil, fmt.Errorf("OIDC 授权失败，状态码：%d", res.StatusCode)
+	}
+	claims, err := parseIdToken(tokenResponse.IdToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if discovery.UserInfoEndpoint != "" && tokenResponse.AccessToken != "" {
+		req, err = http.NewRequest("GET", discovery.UserInfoEndpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tokenResponse.AccessToken))
+		res2, err := oidcClient.Do(req)
+		if err != nil {
+			common.SysLog(err.Error())
+			return nil, errors.New("无法连接至 OIDC 服务器，请稍后重试！")
+		}
+		defer res2.Body.Close()
+		userInfo := make(map[string]any)
+		err = json.NewDecoder(res2.Body).Decode(&userInfo)
+		if err != nil {
+			return nil, err
+		}
+		// the userinfo of another subject must not be mixed in
+		if sub, ok := userInfo["sub"]; ok && sub == claims["sub"] {
+			for k, v := range userInfo {
+				if _, ok := claims[k]; !ok {
+					claims[k] = v
+				}
+			}
+		}
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("返回值非法，用户字段为空，请稍后重试！")
+	}
+	return claims, nil
+}
+
+// getOIDCClaim looks up a claim by name, dots address nested claims, e.g. realm_access.roles
+func getOIDCClaim(claims map[string]any, name string) any {
+	if name == "" {
+		return nil
+	}
+	var value any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+func getOIDCStringClaim(claims map[string]any, name string) string {
+	value, _ := getOIDCClaim(claims, name).(string)
+	return value
+}
+
+// getOIDCGroup maps the group claim to a group, the claim may be a string or a list of strings, the first value
+// found in the mapping wins, without a mapping a value naming an existing group is used as it is
+func getOIDCGroup(claims map[string]any) string {
+	var values []string
+	switch value := getOIDCClaim(claims, common.OIDCGroupClaim).(type) {
+	case string:
+		values = []string{value}
+	case []any:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	mapping := make(map[string]string)
+	if common.OIDCGroupMapping != "" {
+		err := json.Unmarshal([]byte(common.OIDCGroupMapping), &mapping)
+		if err != nil {
+			common.SysError("failed to unmarshal oidc group mapping: " + err.Error())
+			return ""
+		}
+	}
+	for _, value := range values {
+		if len(mapping) == 0 {
+			if _, ok := common.GroupRatio[value]; ok {
+				return value
+			}
+		} else if group, ok := mapping[value]; ok {
+			return group
+		}
+	}
+	return ""
+}
+
+// consumeOIDCSession returns the nonce of the authorization and drops it with the state, so that the
+// callback cannot be replayed with them
+func consumeOIDCSession(c *gin.Context) string {
+	session := sessions.Default(c)
+	nonce, _ := session.Get("oidc_nonce").(string)
+	session.Delete("oauth_state")
+	session.Delete("oidc_nonce")
+	err := session.Save()
+	if err != nil {
+		common.SysError("failed to save the session: " + err.Error())
+	}
+	return nonce
+}
+
+func OIDCOAuth(c *gin.Context) {
+	session := sessions.Default(c)
+	state := c.Query("state")
+	if state == "" || session.Get("oauth_state") == nil || state != session.Get("oauth_state").(string) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "state is empty or not same",
+		})
+		return
+	}
+	nonce := consumeOIDCSession(c)
+	username := session.Get("username")
+	if username != nil {
+		OIDCBind(c, nonce)
+		return
+	}
+
+	if !common.OIDCEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 OIDC 登录以及注册",
+		})
+		return
+	}
+	code := c.Query("code")
+	claims, err := getOIDCClaimsByCode(code, nonce)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user := model.User{
+		OidcId: claims["sub"].(string),
+	}
+	group := getOIDCGroup(claims)
+	if model.IsOidcIdAlreadyTaken(user.OidcId) {
+		err := user.FillUserByOidcId()
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		// the provider stays in charge of the group of the users it maps
+		if group != "" && group != user.Group && user.Status == common.UserStatusEnabled {
+			user.Group = group
+			err = user.Update(false)
+			if err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				return
+			}
+		}
+	} else {
+		if common.RegisterEnabled {
+			user.Username = getOIDCStringClaim(claims, common.OIDCUsernameClaim)
+			if user.Username == "" || len(user.Username) > 12 || model.IsUsernameAlreadyTaken(user.Username) {
+				user.Username = "oidc_" + strconv.Itoa(model.GetMaxUserId()+1)
+			}
+			user.DisplayName = getOIDCStringClaim(claims, "name")
+			if user.DisplayName == "" || len([]rune(user.DisplayName)) > 20 {
+				user.DisplayName = common.OIDCName + " User"
+			}
+			email := getOIDCStringClaim(claims, common.OIDCEmailClaim)
+			if email != "" && len(email) <= 50 && !model.IsEmailAlreadyTaken(email) {
+				user.Email = email
+			}
+			if group != "" {
+				user.Group = group
+			}
+			user.Role = common.RoleCommonUser
+			user.Status = common.UserStatusEnabled
+
+			if err := user.Insert(0); err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": err.Error(),
+				})
+				return
+			}
+		} else {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "管理员关闭了新用户注册",
+			})
+			return
+		}
+	}
+
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	setupLogin(&user, c)
+}
+
+func OIDCBind(c *gin.Context, nonce string) {
+	if !common.OIDCEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员未开启通过 OIDC 登录以及注册",
+		})
+		return
+	}
+	code := c.Query("code")
+	claims, err := getOIDCClaimsByCode(code, nonce)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user := model.User{
+		OidcId: claims["sub"].(string),
+	}
+	if model.IsOidcIdAlreadyTaken(user.OidcId) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该 OIDC 账户已被绑定",
+		})
+		return
+	}
+	session := sessions.Default(c)
+	id := session.Get("id")
+	user.Id = id.(int)
+	err = user.FillUserById()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user.OidcId = claims["sub"].(string)
+	err = user.Update(false)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "bind",
+	})
+	return
+}
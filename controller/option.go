@@ -41,7 +41,21 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	if common.IsRootOnlyOption(option.Key) && c.GetInt("role") != common.RoleRootUser {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅超级管理员可以修改该配置项",
+		})
+		return
+	}
 	switch option.Key {
+	case "TokenHashSecret":
+		// changing it would invalidate every api key
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "该配置项不可修改",
+		})
+		return
 	case "GitHubOAuthEnabled":
 		if option.Value == "true" && common.GitHubClientId == "" {
 			c.JSON(http.StatusOK, gin.H{
@@ -50,6 +64,40 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "OIDCEnabled":
+		if option.Value == "true" && (common.OIDCIssuer == "" || common.OIDCClientId == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 OIDC 登录，请先填入 Issuer、Client Id 以及 Client Secret！",
+			})
+			return
+		}
+	case "OIDCGroupMapping":
+		if option.Value != "" && !json.Valid([]byte(option.Value)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "OIDC 分组映射不是合法的 JSON 字符串",
+			})
+			return
+		}
+	case "AdminRoles":
+		err = common.CheckAdminRolesJSONString(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "管理角色配置无效：" + err.Error(),
+			})
+			return
+		}
+	case "GroupModelFallback":
+		err = common.CheckGroupModelFallbackJSONString(option.Value)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "模型降级配置无效：" + err.Error(),
+			})
+			return
+		}
 	case "EmailDomainRestrictionEnabled":
 		if option.Value == "true" && len(common.EmailDomainWhitelist) == 0 {
 			c.JSON(http.StatusOK, gin.H{
@@ -75,6 +123,14 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	before := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
+	if common.IsAuditSecretField(option.Key) {
+		setAuditChange(c, option.Key, "***", "***")
+	} else {
+		setAuditChange(c, option.Key, before, option.Value)
+	}
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
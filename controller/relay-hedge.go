@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/middleware"
+	"one-api/model"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// hedgeRace decides which attempt of a hedged request answers the client,
+// the first attempt that writes wins and the others are cancelled
+type hedgeRace struct {
+	lock    sync.Mutex
+	winner  int // index of the winning attempt, -1 while undecided
+	cancels []context.CancelFunc
+}
+
+// join registers a new attempt, it fails once the race is decided
+func (r *hedgeRace) join(cancel context.CancelFunc) (int, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.winner != -1 {
+		return 0, false
+	}
+	r.cancels = append(r.cancels, cancel)
+	return len(r.cancels) - 1, true
+}
+
+// claim makes the attempt the winner if the race is undecided and reports whether it is the winner
+func (r *hedgeRace) claim(attempt int) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.winner == -1 {
+		r.winner = attempt
+		for i, cancel := range r.cancels {
+			if i != attempt {
+				cancel()
+			}
+		}
+	}
+	return r.winner == attempt
+}
+
+func (r *hedgeRace) getWinner() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.winner
+}
+
+func (r *hedgeRace) lost(attempt int) bool {
+	winner := r.getWinner()
+	return winner != -1 && winner != attempt
+}
+
+// hedgeWriter holds back the headers of an attempt until its first write wins the race,
+// everything the losing attempt writes is dropped
+type hedgeWriter struct {
+	gin.ResponseWriter
+	race    *hedgeRace
+	attempt int
+	header  http.Header
+	status  int
+	size    int
+	won     bool
+}
+
+func (w *hedgeWriter) commit() bool {
+	if w.won {
+		return true
+	}
+	if !w.race.claim(w.attempt) {
+		return false
+	}
+	w.won = true
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.won {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	if !w.commit() {
+		w.size += len(data)
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if !w.commit() {
+		w.size += len(s)
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+	if w.size == 0 {
+		return -1
+	}
+	return w.size
+}
+
+func (w *hedgeWriter) Written() bool {
+	if w.won {
+		return w.ResponseWriter.Written()
+	}
+	return w.size != 0
+}
+
+// hedgeAttempt is one of the attempts of a hedged request, run on a copy of the context
+type hedgeAttempt struct {
+	c         *gin.Context
+	channelId int
+	startTime time.Time
+	err       *dto.OpenAIErrorWithStatusCode
+	lost      bool
+}
+
+// getHedgeDelay returns how long the first attempt may stay silent before a second channel is raced,
+// 0 if the request is not hedged
+func getHedgeDelay(c *gin.Context, relayMode int, group string) time.Duration {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0
+	}
+	switch relayMode {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions,
+		relayconstant.RelayModeClaudeMessages, relayconstant.RelayModeGemini:
+	default:
+		return 0
+	}
+	return time.Duration(common.GetGroupHedgeDelay(group)) * time.Millisecond
+}
+
+// releaseChannel gives back the request slot a selector reserved for a channel that ends up unused
+func releaseChannel(channel *model.Channel) {
+	if channel.HasLimits() {
+		model.ReleaseChannel(channel.Id)
+	}
+}
+
+// selectHedgeChannel picks a channel other than the one of the first attempt, nil if there is none
+func selectHedgeChannel(group string, servingModel string, channelId int) *model.Channel {
+	for retry := 0; retry < 2; retry++ {
+		for i := 0; i < 3; i++ {
+			channel, err := model.CacheGetRandomSatisfiedChannel(group, servingModel, retry)
+			if err != nil {
+				break
+			}
+			if channel.Id != channelId {
+				return channel
+			}
+			releaseChannel(channel)
+		}
+	}
+	return nil
+}
+
+// relayHedged relays the request with the channel set up in the context, and races a second channel
+// if nothing was written within the delay. The context ends up with the channel of the winner, or of
+// the attempt that failed last if none of them answered.
+func relayHedged(c *gin.Context, relayMode int, writer *firstWriteRecorder, group string, servingModel string, delay time.Duration) *dto.OpenAIErrorWithStatusCode {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return relayAttempt(c, relayMode, writer, c.GetInt("channel_id"), servingModel)
+	}
+	race := &hedgeRace{winner: -1}
+	finished := make(chan *hedgeAttempt, 2)
+	attempts := make([]*hedgeAttempt, 0, 2)
+	useChannel := c.GetStringSlice("use_channel")
+	start := func(channel *model.Channel) bool {
+		cp := c.Copy()
+		cp.Request = c.Request.Clone(c.Request.Context())
+		cp.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		if channel != nil {
+			// the limiter slot of the first channel stays with the original context
+			cp.Set("channel_limited", false)
+			if middleware.SetupContextForSelectedChannel(cp, channel, c.GetString("original_model")) != nil {
+				return false
+			}
+		}
+		upstreamCtx, cancel := context.WithCancel(context.Background())
+		index, ok := race.join(cancel)
+		if !ok {
+			cancel()
+			return false
+		}
+		cp.Writer = &hedgeWriter{ResponseWriter: writer, race: race, attempt: index, header: make(http.Header)}
+		cp.Set("upstream_context", upstreamCtx)
+		cp.Set("hedge_lost", func() bool { return race.lost(index) })
+		attempt := &hedgeAttempt{c: cp, channelId: cp.GetInt("channel_id"), startTime: time.Now()}
+		attempts = append(attempts, attempt)
+		useChannel = append(useChannel, fmt.Sprintf("%d", attempt.channelId))
+		for _, a := range attempts {
+			a.c.Set("use_channel", useChannel)
+			a.c.Set("hedged", len(attempts) > 1)
+		}
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					common.SysError(fmt.Sprintf("panic detected in hedged attempt: %v, stack: %s", r, string(debug.Stack())))
+					attempt.err = service.OpenAIErrorWrapperLocal(fmt.Errorf("panic detected: %v", r), "new_api_panic", http.StatusInternalServerError)
+				}
+				attempt.lost = race.lost(index)
+				cancel()
+				finished <- attempt
+			}()
+			attempt.err = relayHandler(cp, relayMode)
+		}()
+		return true
+	}
+
+	start(nil)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	var last *hedgeAttempt
+	for pending > 0 {
+		select {
+		case attempt := <-finished:
+			pending--
+			last = attempt
+		case <-timer.C:
+			channel := selectHedgeChannel(group, servingModel, attempts[0].channelId)
+			if channel != nil && start(channel) {
+				pending++
+				common.LogInfo(c.Request.Context(), fmt.Sprintf("channel #%d did not answer within %s, hedging with channel #%d", attempts[0].channelId, delay, channel.Id))
+			} else if channel != nil {
+				releaseChannel(channel)
+			}
+		}
+	}
+
+	result := last
+	if winner := race.getWinner(); winner != -1 {
+		result = attempts[winner]
+	}
+	// the attempt that lost was cancelled, which says nothing about its channel
+	for _, attempt := range attempts {
+		if attempt.lost {
+			continue
+		}
+		recordChannelOutcome(writer, attempt.channelId, servingModel, attempt.startTime, attempt.err)
+		if attempt.err != nil {
+			holdSaturatedChannel(attempt.c, attempt.channelId, attempt.err)
+			go processChannelError(attempt.c, attempt.channelId, attempt.c.GetInt("channel_key_id"), attempt.err)
+		}
+	}
+	c.Set("use_channel", useChannel)
+	if result != attempts[0] {
+		// the original context takes over the channel of the second attempt along with its limiter slot
+		if c.GetBool("channel_limited") {
+			model.ReleaseChannel(c.GetInt("channel_id"))
+		}
+		c.Set("channel_limited", result.c.GetBool("channel_limited"))
+		c.Set("channel_id", result.channelId)
+		c.Set("channel_key_id", result.c.GetInt("channel_key_id"))
+	} else if len(attempts) > 1 && attempts[1].c.GetBool("channel_limited") {
+		model.ReleaseChannel(attempts[1].channelId)
+	}
+	return result.err
+}
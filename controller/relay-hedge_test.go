@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"testing"
+)
+
+func TestHedgeRace(t *testing.T) {
+	race := &hedgeRace{winner: -1}
+	ctx0, cancel0 := context.WithCancel(context.Background())
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	first, ok0 := race.join(cancel0)
+	second, ok1 := race.join(cancel1)
+	if !ok0 || !ok1 || first != 0 || second != 1 {
+		t.Fatalf("join = %d, %v and %d, %v, want 0 and 1", first, ok0, second, ok1)
+	}
+	if race.lost(first) || race.lost(second) {
+		t.Fatal("an attempt lost an undecided race")
+	}
+
+	// the second attempt answers first, the first one is cancelled
+	if !race.claim(second) {
+		t.Fatal("the first claim did not win")
+	}
+	if race.claim(first) {
+		t.Error("a second claim won the decided race")
+	}
+	if ctx0.Err() == nil || ctx1.Err() != nil {
+		t.Errorf("cancelled attempts: first %v, second %v, want only the first", ctx0.Err(), ctx1.Err())
+	}
+	if race.getWinner() != second || !race.lost(first) || race.lost(second) {
+		t.Errorf("winner = %d, lost = %v, %v", race.getWinner(), race.lost(first), race.lost(second))
+	}
+	if _, ok := race.join(func() {}); ok {
+		t.Error("an attempt joined a decided race")
+	}
+	cancel1()
+}
+
+func TestHedgeWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	race := &hedgeRace{winner: -1}
+	index0, _ := race.join(func() {})
+	index1, _ := race.join(func() {})
+	loser := &hedgeWriter{ResponseWriter: c.Writer, race: race, attempt: index0, header: make(http.Header)}
+	winner := &hedgeWriter{ResponseWriter: c.Writer, race: race, attempt: index1, header: make(http.Header)}
+
+	// headers are held back until the first write
+	winner.Header().Set("Content-Type", "text/event-stream")
+	winner.WriteHeader(http.StatusAccepted)
+	loser.Header().Set("Content-Type", "application/json")
+	loser.WriteHeader(http.StatusTooManyRequests)
+	if recorder.Header().Get("Content-Type") != "" || c.Writer.Written() {
+		t.Fatal("headers were written before the race was decided")
+	}
+	if winner.Status() != http.StatusAccepted || winner.Written() || winner.Size() != -1 {
+		t.Errorf("pending writer status %d, written %v, size %d", winner.Status(), winner.Written(), winner.Size())
+	}
+
+	if _, err := winner.WriteString("data: first\n\n"); err != nil {
+		t.Fatal(err)
+	}
+	// the loser keeps writing until it notices it was cancelled, none of it reaches the client
+	if n, err := loser.Write([]byte("{\"error\":\"rate limited\"}")); n != 24 || err != nil {
+		t.Errorf("loser Write = %d, %v", n, err)
+	}
+	loser.Header().Set("X-Loser", "1")
+	loser.WriteHeader(http.StatusInternalServerError)
+	loser.Flush()
+	if _, err := winner.Write([]byte("data: second\n\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Code != http.StatusAccepted || recorder.Header().Get("Content-Type") != "text/event-stream" || recorder.Header().Get("X-Loser") != "" {
+		t.Errorf("response status %d with headers %v", recorder.Code, recorder.Header())
+	}
+	if got := recorder.Body.String(); got != "data: first\n\ndata: second\n\n" {
+		t.Errorf("body = %q", got)
+	}
+	if !race.lost(index0) || race.lost(index1) {
+		t.Error("the accounting does not skip the loser only")
+	}
+	if loser.Status() != http.StatusInternalServerError || loser.Size() != 24 || !loser.Written() {
+		t.Errorf("loser status %d, size %d, written %v", loser.Status(), loser.Size(), loser.Written())
+	}
+	if winner.Size() != c.Writer.Size() || !winner.Written() {
+		t.Errorf("winner size %d, want %d", winner.Size(), c.Writer.Size())
+	}
+}
+
+func TestHedgeLost(t *testing.T) {
+	race := &hedgeRace{winner: -1}
+	index0, _ := race.join(func() {})
+	index1, _ := race.join(func() {})
+	contexts := make([]*gin.Context, 2)
+	for i, index := range []int{index0, index1} {
+		contexts[i], _ = gin.CreateTestContext(httptest.NewRecorder())
+		index := index
+		contexts[i].Set("hedge_lost", func() bool { return race.lost(index) })
+	}
+	plain, _ := gin.CreateTestContext(httptest.NewRecorder())
+	race.claim(index0)
+	// the tpm of the channel is not counted for the attempt that lost
+	if relaycommon.HedgeLost(contexts[0]) || !relaycommon.HedgeLost(contexts[1]) || relaycommon.HedgeLost(plain) {
+		t.Errorf("HedgeLost = %v, %v, %v, want false, true, false",
+			relaycommon.HedgeLost(contexts[0]), relaycommon.HedgeLost(contexts[1]), relaycommon.HedgeLost(plain))
+	}
+}
+
+func TestGetHedgeDelay(t *testing.T) {
+	previous := common.GroupHedgeDelay
+	t.Cleanup(func() {
+		common.GroupHedgeDelay = previous
+	})
+	common.GroupHedgeDelay = map[string]int{"fast": 1500}
+	tests := []struct {
+		name            string
+		relayMode       int
+		group           string
+		specificChannel bool
+		want            int64
+	}{
+		{"chat", relayconstant.RelayModeChatCompletions, "fast", false, 1500},
+		{"claude messages", relayconstant.RelayModeClaudeMessages, "fast", false, 1500},
+		{"embeddings are not hedged", relayconstant.RelayModeEmbeddings, "fast", false, 0},
+		{"group without hedging", relayconstant.RelayModeChatCompletions, "default", false, 0},
+		{"specific channel", relayconstant.RelayModeChatCompletions, "fast", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.specificChannel {
+				c.Set("specific_channel_id", "1")
+			}
+			if got := getHedgeDelay(c, tt.relayMode, tt.group).Milliseconds(); got != tt.want {
+				t.Errorf("getHedgeDelay = %dms, want %dms", got, tt.want)
+			}
+		})
+	}
+}
@@ -12,17 +12,28 @@ import (
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/relay/channel/claude"
+	"one-api/relay/channel/gemini"
 	"one-api/relay/constant"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"strings"
+	"time"
 )
 
 func relayHandler(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	var err *dto.OpenAIErrorWithStatusCode
 	switch relayMode {
+	case relayconstant.RelayModeImagesEdits:
+		fallthrough
+	case relayconstant.RelayModeImagesVariations:
+		fallthrough
 	case relayconstant.RelayModeImagesGenerations:
 		err = relay.RelayImageHelper(c, relayMode)
+	case relayconstant.RelayModeClaudeMessages:
+		err = relay.ClaudeHelper(c)
+	case relayconstant.RelayModeGemini:
+		err = relay.GeminiHelper(c)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
 	case relayconstant.RelayModeAudioTranslation:
@@ -42,32 +53,46 @@ func Relay(c *gin.Context) {
 	channelId := c.GetInt("channel_id")
 	group := c.GetString("group")
 	originalModel := c.GetString("original_model")
-	openaiErr := relayHandler(c, relayMode)
-	c.Set("use_channel", []string{fmt.Sprintf("%d", channelId)})
-	if openaiErr != nil {
-		go processChannelError(c, channelId, openaiErr)
+	// the model the request is served with, it moves along the fallback chain of the original model
+	servingModel := originalModel
+	// the position in the fallback chain the next fallback is looked for from
+	nextFallback := 0
+	if fallbackModel := c.GetString("fallback_model"); fallbackModel != "" {
+		servingModel = fallbackModel
+		nextFallback = c.GetInt("fallback_index") + 1
+	}
+	writer := &firstWriteRecorder{ResponseWriter: c.Writer}
+	c.Writer = writer
+	var openaiErr *dto.OpenAIErrorWithStatusCode
+	if delay := getHedgeDelay(c, relayMode, group); delay > 0 {
+		openaiErr = relayHedged(c, relayMode, writer, group, servingModel, delay)
 	} else {
-		retryTimes = 0
+		openaiErr = relayAttempt(c, relayMode, writer, channelId, servingModel)
 	}
-	for i := 0; shouldRetry(c, channelId, openaiErr, retryTimes) && i < retryTimes; i++ {
-		channel, err := model.CacheGetRandomSatisfiedChannel(group, originalModel, i)
-		if err != nil {
-			common.LogError(c.Request.Context(), fmt.Sprintf("CacheGetRandomSatisfiedChannel failed: %s", err.Error()))
+	openaiErr = retryChannels(c, relayMode, writer, group, originalModel, servingModel, openaiErr, retryTimes)
+	// the fallback chain is walked whenever the error is worth a retry, even if retries are disabled
+	for shouldRetry(c, channelId, openaiErr, 1) {
+		channel, fallbackModel, fallbackIndex := middleware.SelectFallbackChannel(c, group, originalModel, nextFallback)
+		if channel == nil {
 			break
 		}
-		channelId = channel.Id
-		useChannel := c.GetStringSlice("use_channel")
-		useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
-		c.Set("use_channel", useChannel)
-		common.LogInfo(c.Request.Context(), fmt.Sprintf("using channel #%d to retry (remain times %d)", channel.Id, i))
-		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
-
-		requestBody, err := common.GetRequestBody(c)
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-		openaiErr = relayHandler(c, relayMode)
-		if openaiErr != nil {
-			go processChannelError(c, channelId, openaiErr)
+		nextFallback = fallbackIndex + 1
+		common.LogInfo(c.Request.Context(), fmt.Sprintf("model %s failed, falling back to model %s", servingModel, fallbackModel))
+		servingModel = fallbackModel
+		c.Set("fallback_model", fallbackModel)
+		c.Set("fallback_index", fallbackIndex)
+		err := middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		if err != nil {
+			openaiErr = service.OpenAIErrorWrapperLocal(err, "channel_key_error", http.StatusServiceUnavailable)
+			continue
 		}
+		requestBody, _ := common.GetRequestBody(c)
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		openaiErr = relayAttempt(c, relayMode, writer, channel.Id, servingModel)
+		openaiErr = retryChannels(c, relayMode, writer, group, originalModel, servingModel, openaiErr, retryTimes)
+	}
+	if stickyKey := c.GetString("sticky_key"); stickyKey != "" && openaiErr == nil {
+		model.SetStickyChannel(stickyKey, c.GetInt("channel_id"), c.GetInt("channel_key_id"))
 	}
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
@@ -80,12 +105,59 @@ func Relay(c *gin.Context) {
 			openaiErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
 		openaiErr.Error.Message = common.MessageWithRequestId(openaiErr.Error.Message, requestId)
+		switch relayMode {
+		case relayconstant.RelayModeClaudeMessages:
+			c.JSON(openaiErr.StatusCode, claude.ErrorOpenAI2Claude(openaiErr))
+			return
+		case relayconstant.RelayModeGemini:
+			c.JSON(openaiErr.StatusCode, gemini.ErrorOpenAI2Gemini(openaiErr))
+			return
+		}
 		c.JSON(openaiErr.StatusCode, gin.H{
 			"error": openaiErr.Error,
 		})
 	}
 }
 
+// relayAttempt relays the request with the channel set up in the context
+func relayAttempt(c *gin.Context, relayMode int, writer *firstWriteRecorder, channelId int, servingModel string) *dto.OpenAIErrorWithStatusCode {
+	useChannel := c.GetStringSlice("use_channel")
+	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
+	c.Set("use_channel", useChannel)
+	startTime := time.Now()
+	openaiErr := relayHandler(c, relayMode)
+	recordChannelOutcome(writer, channelId, servingModel, startTime, openaiErr)
+	if openaiErr != nil {
+		holdSaturatedChannel(c, channelId, openaiErr)
+		go processChannelError(c, channelId, c.GetInt("channel_key_id"), openaiErr)
+	}
+	return openaiErr
+}
+
+// retryChannels retries a failed request with other channels of the serving model
+func retryChannels(c *gin.Context, relayMode int, writer *firstWriteRecorder, group string, originalModel string, servingModel string, openaiErr *dto.OpenAIErrorWithStatusCode, retryTimes int) *dto.OpenAIErrorWithStatusCode {
+	channelId := c.GetInt("channel_id")
+	for i := 0; shouldRetry(c, channelId, openaiErr, retryTimes) && i < retryTimes; i++ {
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, servingModel, i)
+		if err != nil {
+			common.LogError(c.Request.Context(), fmt.Sprintf("CacheGetRandomSatisfiedChannel failed: %s", err.Error()))
+			break
+		}
+		channelId = channel.Id
+		common.LogInfo(c.Request.Context(), fmt.Sprintf("using channel #%d to retry (remain times %d)", channel.Id, i))
+		err = middleware.SetupContextForSelectedChannel(c, channel, originalModel)
+		if err != nil {
+			openaiErr = service.OpenAIErrorWrapperLocal(err, "channel_key_error", http.StatusServiceUnavailable)
+			continue
+		}
+
+		requestBody, _ := common.GetRequestBody(c)
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		openaiErr = relayAttempt(c, relayMode, writer, channelId, servingModel)
+	}
+	return openaiErr
+}
+
 func shouldRetry(c *gin.Context, channelId int, openaiErr *dto.OpenAIErrorWithStatusCode, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
@@ -125,11 +197,61 @@ func shouldRetry(c *gin.Context, channelId int, openaiErr *dto.OpenAIErrorWithSt
 	return true
 }
 
-func processChannelError(c *gin.Context, channelId int, err *dto.OpenAIErrorWithStatusCode) {
+// firstWriteRecorder remembers when the first byte of the response was written,
+// for streams this is the time to first token
+type firstWriteRecorder struct {
+	gin.ResponseWriter
+	firstWrite time.Time
+}
+
+func (w *firstWriteRecorder) Write(data []byte) (int, error) {
+	if w.firstWrite.IsZero() {
+		w.firstWrite = time.Now()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *firstWriteRecorder) WriteString(s string) (int, error) {
+	if w.firstWrite.IsZero() {
+		w.firstWrite = time.Now()
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// recordChannelOutcome feeds the result of a relay attempt to the adaptive channel selection,
+// local errors and bad requests are caused by the client and say nothing about the channel
+func recordChannelOutcome(writer *firstWriteRecorder, channelId int, modelName string, startTime time.Time, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	if openaiErr != nil && (openaiErr.LocalError || openaiErr.StatusCode == http.StatusBadRequest) {
+		return
+	}
+	var ttft time.Duration
+	if writer.firstWrite.After(startTime) {
+		ttft = writer.firstWrite.Sub(startTime)
+	}
+	model.RecordChannelOutcome(channelId, modelName, time.Since(startTime), ttft, openaiErr == nil)
+}
+
+// holdSaturatedChannel keeps a channel with rate limits out of the selection for the rest
+// of the minute after its upstream answered 429, so the retry and later requests skip it
+func holdSaturatedChannel(c *gin.Context, channelId int, openaiErr *dto.OpenAIErrorWithStatusCode) {
+	if openaiErr.StatusCode == http.StatusTooManyRequests && !openaiErr.LocalError && c.GetBool("channel_limited") {
+		model.MarkChannelSaturated(channelId)
+	}
+}
+
+func processChannelError(c *gin.Context, channelId int, keyId int, err *dto.OpenAIErrorWithStatusCode) {
 	autoBan := c.GetBool("auto_ban")
 	common.LogError(c.Request.Context(), fmt.Sprintf("relay error (channel #%d, status code: %d): %s", channelId, err.StatusCode, err.Error.Message))
 	if service.ShouldDisableChannel(&err.Error, err.StatusCode) && autoBan {
 		channelName := c.GetString("channel_name")
+		if keyId != 0 {
+			service.DisableChannelKey(channelId, keyId, channelName, err.Error.Message)
+			return
+		}
+		if common.CircuitBreakerEnabled {
+			model.TripChannelBreaker(channelId)
+			return
+		}
 		service.DisableChannel(channelId, channelName, err.Error.Message)
 	}
 }
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	relayconstant "one-api/relay/constant"
 	"strconv"
+	"strings"
 )
 
 func GetAllTokens(c *gin.Context) {
@@ -123,6 +125,35 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.RPMLimit < 0 || token.TPMLimit < 0 || token.ConcurrencyLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "速率限制不能为负数",
+		})
+		return
+	}
+	if len(token.AllowIps) > 1024 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "IP 白名单过长",
+		})
+		return
+	}
+	if _, err := common.ParseIpAllowList(token.AllowIps); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	scopes, err := relayconstant.NormalizeScopes(token.Scopes)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
@@ -134,6 +165,11 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota:     token.UnlimitedQuota,
 		ModelLimitsEnabled: token.ModelLimitsEnabled,
 		ModelLimits:        token.ModelLimits,
+		RPMLimit:           token.RPMLimit,
+		TPMLimit:           token.TPMLimit,
+		ConcurrencyLimit:   token.ConcurrencyLimit,
+		AllowIps:           strings.TrimSpace(token.AllowIps),
+		Scopes:             scopes,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -143,9 +179,11 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	// only the hash of the key is stored, this is the one time the key is shown
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data":    cleanToken,
 	})
 	return
 }
@@ -187,6 +225,35 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if token.RPMLimit < 0 || token.TPMLimit < 0 || token.ConcurrencyLimit < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "速率限制不能为负数",
+		})
+		return
+	}
+	if len(token.AllowIps) > 1024 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "IP 白名单过长",
+		})
+		return
+	}
+	if _, err := common.ParseIpAllowList(token.AllowIps); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	scopes, err := relayconstant.NormalizeScopes(token.Scopes)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -221,6 +288,11 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.ModelLimitsEnabled = token.ModelLimitsEnabled
 		cleanToken.ModelLimits = token.ModelLimits
+		cleanToken.RPMLimit = token.RPMLimit
+		cleanToken.TPMLimit = token.TPMLimit
+		cleanToken.ConcurrencyLimit = token.ConcurrencyLimit
+		cleanToken.AllowIps = strings.TrimSpace(token.AllowIps)
+		cleanToken.Scopes = scopes
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+)
+
+// pendingTwoFALoginTimeout is how many seconds the second login step may take after the first one
+const pendingTwoFALoginTimeout = 300
+
+type TwoFARequest struct {
+	Code string `json:"code"`
+}
+
+// setupPendingTwoFALogin remembers the user that passed the first login step, the session is created by Login2FA
+func setupPendingTwoFALogin(user *model.User, c *gin.Context) {
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	session := sessions.Default(c)
+	session.Clear()
+	session.Set("pending_2fa_id", user.Id)
+	session.Set("pending_2fa_time", common.GetTimestamp())
+	err := session.Save()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "请输入两步验证码",
+		"success": true,
+		"data": gin.H{
+			"require_2fa": true,
+		},
+	})
+}
+
+func Login2FA(c *gin.Context) {
+	var req TwoFARequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil || req.Code == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+	session := sessions.Default(c)
+	id, ok := session.Get("pending_2fa_id").(int)
+	pendingTime, _ := session.Get("pending_2fa_time").(int64)
+	if !ok || common.GetTimestamp()-pendingTime > pendingTwoFALoginTimeout {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "登录已过期，请重新登录",
+			"success": false,
+		})
+		return
+	}
+	twoFA, err := model.GetTwoFAByUserId(id)
+	if err != nil || twoFA == nil || !twoFA.Verify(req.Code) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "验证码错误或已过期",
+			"success": false,
+		})
+		return
+	}
+	user := model.User{Id: id}
+	err = user.FillUserById()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+	if user.Status != common.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+	createLoginSession(&user, c, true)
+}
+
+func GetTwoFAStatus(c *gin.Context) {
+	twoFA, err := model.GetTwoFAByUserId(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	enabled := twoFA != nil && twoFA.Enabled
+	remaining := 0
+	if enabled {
+		remaining = twoFA.RemainingRecoveryCodes()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":                  enabled,
+			"required":                 model.IsTwoFARequired(c.GetInt("role")),
+			"remaining_recovery_codes": remaining,
+		},
+	})
+}
+
+// SetupTwoFA returns a new secret to enroll, it replaces the secret of an earlier setup that was not enabled
+func SetupTwoFA(c *gin.Context) {
+	twoFA, err := model.SetupTwoFA(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	secret, err := twoFA.GetSecret()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret": secret,
+			"uri":    common.GetTOTPURI(c.GetString("username"), secret),
+		},
+	})
+}
+
+func EnableTwoFA(c *gin.Context) {
+	var req TwoFARequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	twoFA, err := model.GetTwoFAByUserId(c.GetInt("id"))
+	if err == nil && twoFA == nil {
+		err = errors.New("请先获取两步验证密钥")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	codes, err := twoFA.Enable(req.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set("two_fa_verified_at", common.GetTimestamp())
+	_ = session.Save()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    codes,
+	})
+}
+
+// getEnabledTwoFA returns the enabled 2fa of the user if the code verifies against it
+func getEnabledTwoFA(c *gin.Context) (*model.TwoFA, bool) {
+	var req TwoFARequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return nil, false
+	}
+	twoFA, err := model.GetTwoFAByUserId(c.GetInt("id"))
+	if err != nil || twoFA == nil || !twoFA.Enabled {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "两步验证未启用",
+		})
+		return nil, false
+	}
+	if !twoFA.Verify(req.Code) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "验证码错误或已过期",
+		})
+		return nil, false
+	}
+	return twoFA, true
+}
+
+func DisableTwoFA(c *gin.Context) {
+	if model.IsTwoFARequired(c.GetInt("role")) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员要求你的账户启用两步验证，无法关闭",
+		})
+		return
+	}
+	if _, ok := getEnabledTwoFA(c); !ok {
+		return
+	}
+	err := model.DisableTwoFA(c.GetInt("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func RegenerateTwoFARecoveryCodes(c *gin.Context) {
+	twoFA, ok := getEnabledTwoFA(c)
+	if !ok {
+		return
+	}
+	codes, err := twoFA.RegenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    codes,
+	})
+}
+
+// VerifyTwoFA lets the session perform sensitive operations for a while
+func VerifyTwoFA(c *gin.Context) {
+	if _, ok := getEnabledTwoFA(c); !ok {
+		return
+	}
+	session := sessions.Default(c)
+	session.Set("two_fa_verified_at", common.GetTimestamp())
+	err := session.Save()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
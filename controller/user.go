@@ -59,9 +59,24 @@ func Login(c *gin.Context) {
 	setupLogin(&user, c)
 }
 
-// setup session & cookies and then return user info
+// setup session & cookies and then return user info, users with 2fa enabled have to pass Login2FA first
 func setupLogin(user *model.User, c *gin.Context) {
+	if model.IsTwoFAEnabled(user.Id) {
+		setupPendingTwoFALogin(user, c)
+		return
+	}
+	createLoginSession(user, c, false)
+}
+
+func createLoginSession(user *model.User, c *gin.Context, twoFAVerified bool) {
 	session := sessions.Default(c)
+	session.Delete("pending_2fa_id")
+	session.Delete("pending_2fa_time")
+	if twoFAVerified {
+		session.Set("two_fa_verified_at", common.GetTimestamp())
+	} else {
+		session.Delete("two_fa_verified_at")
+	}
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
@@ -79,6 +94,8 @@ func setupLogin(user *model.User, c *gin.Context) {
 		Username:    user.Username,
 		DisplayName: user.DisplayName,
 		Role:        user.Role,
+		AdminRole:   user.AdminRole,
+		Permissions: common.GetRolePermissions(user.Role, user.AdminRole),
 		Status:      user.Status,
 		Group:       user.Group,
 	}
@@ -293,6 +310,7 @@ func GenerateAccessToken(c *gin.Context) {
 		})
 		return
 	}
+	setAuditChange(c, id, nil, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -376,6 +394,7 @@ func GetSelf(c *gin.Context) {
 		})
 		return
 	}
+	user.Permissions = common.GetRolePermissions(user.Role, user.AdminRole)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -449,6 +468,23 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser.Quota != originUser.Quota && !common.HasPermission(myRole, c.GetString("admin_role"), common.PermissionBillingManage) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权修改用户额度，缺少权限 " + common.PermissionBillingManage,
+		})
+		return
+	}
+	if myRole != common.RoleRootUser {
+		// only root assigns admin roles, otherwise admins could grant permissions they do not hold
+		updatedUser.AdminRole = originUser.AdminRole
+	} else if _, ok := common.AdminRoles[updatedUser.AdminRole]; updatedUser.AdminRole != "" && !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理角色不存在",
+		})
+		return
+	}
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
@@ -460,6 +496,9 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if updatedUser, err := model.GetUserById(updatedUser.Id, false); err == nil {
+		setAuditChange(c, originUser.Id, originUser, updatedUser)
+	}
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
@@ -542,6 +581,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	setAuditChange(c, id, originUser, nil)
 	err = model.HardDeleteUserById(id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -665,6 +705,8 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	before := gin.H{"role": user.Role, "status": user.Status}
+	setAuditChange(c, user.Id, before, gin.H{"action": req.Action, "role": user.Role, "status": user.Status})
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -724,6 +766,14 @@ func ManageUser(c *gin.Context) {
 			return
 		}
 		user.Role = common.RoleCommonUser
+	case "reset_2fa":
+		if err := model.DisableTwoFA(user.Id); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 
 	if err := user.Update(false); err != nil {
@@ -733,6 +783,7 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	setAuditChange(c, user.Id, before, gin.H{"action": req.Action, "role": user.Role, "status": user.Status})
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -43,6 +43,21 @@ type OpenAIFunction struct {
 	Parameters  any    `json:"parameters,omitempty"`
 }
 
+func (r GeneralOpenAIRequest) ParseTools() []OpenAITools {
+	if r.Tools == nil {
+		return nil
+	}
+	var tools []OpenAITools
+	jsonData, err := json.Marshal(r.Tools)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(jsonData, &tools); err != nil {
+		return nil
+	}
+	return tools
+}
+
 func (r GeneralOpenAIRequest) GetMaxTokens() int64 {
 	return int64(r.MaxTokens)
 }
@@ -90,6 +105,21 @@ const (
 	ContentTypeImageURL = "image_url"
 )
 
+func (m Message) ParseToolCalls() []ToolCall {
+	if m.ToolCalls == nil {
+		return nil
+	}
+	var toolCalls []ToolCall
+	jsonData, err := json.Marshal(m.ToolCalls)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(jsonData, &toolCalls); err != nil {
+		return nil
+	}
+	return toolCalls
+}
+
 func (m Message) StringContent() string {
 	var stringContent string
 	if err := json.Unmarshal(m.Content, &stringContent); err == nil {
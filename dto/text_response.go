@@ -84,8 +84,8 @@ func (c *ChatCompletionsStreamResponseChoiceDelta) GetContentString() string {
 type ToolCall struct {
 	// Index is not nil only in chat completion chunk object
 	Index    *int         `json:"index,omitempty"`
-	ID       string       `json:"id"`
-	Type     any          `json:"type"`
+	ID       string       `json:"id,omitempty"`
+	Type     any          `json:"type,omitempty"`
 	Function FunctionCall `json:"function"`
 }
 
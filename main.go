@@ -16,6 +16,7 @@ import (
 	"one-api/service"
 	"os"
 	"strconv"
+	"strings"
 
 	_ "net/http/pprof"
 )
@@ -27,6 +28,7 @@ var buildFS embed.FS
 var indexPage []byte
 
 func main() {
+	common.ParseFlags()
 	common.SetupLogger()
 	common.SysLog("New API " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
@@ -35,8 +37,12 @@ func main() {
 	if common.DebugEnabled {
 		common.SysLog("running in debug mode")
 	}
+	err := common.InitMasterKeys()
+	if err != nil {
+		common.FatalLog("failed to load the channel key secrets: " + err.Error())
+	}
 	// Initialize SQL Database
-	err := model.InitDB()
+	err = model.InitDB()
 	if err != nil {
 		common.FatalLog("failed to initialize database: " + err.Error())
 	}
@@ -46,6 +52,19 @@ func main() {
 			common.FatalLog("failed to close database: " + err.Error())
 		}
 	}()
+	if *common.ReencryptChannelKeys {
+		count, err := model.ReencryptChannelKeys()
+		if err != nil {
+			common.FatalLog("failed to re-encrypt channel keys: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("re-encrypted the keys of %d channels", count))
+		count, err = model.ReencryptTwoFASecrets()
+		if err != nil {
+			common.FatalLog("failed to re-encrypt 2fa secrets: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("re-encrypted the 2fa secrets of %d users", count))
+		return
+	}
 
 	// Initialize Redis
 	err = common.InitRedisClient()
@@ -107,9 +126,28 @@ func main() {
 	}
 
 	service.InitTokenEncoders()
+	service.InitFileStore()
+	if common.IsMasterNode {
+		common.SafeGoroutine(func() {
+			controller.AutomaticallyProcessBatches()
+		})
+	}
 
 	// Initialize HTTP server
 	server := gin.New()
+	// only these proxies may set the client ip with X-Forwarded-For, the ip allow lists of tokens depend on it,
+	// without TRUSTED_PROXIES no proxy is trusted and the client ip is the remote address
+	var proxies []string
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		proxies = strings.Split(os.Getenv("TRUSTED_PROXIES"), ",")
+		for i := range proxies {
+			proxies[i] = strings.TrimSpace(proxies[i])
+		}
+	}
+	err = server.SetTrustedProxies(proxies)
+	if err != nil {
+		common.FatalLog("failed to set trusted proxies: " + err.Error())
+	}
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
 		common.SysError(fmt.Sprintf("panic detected: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{
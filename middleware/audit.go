@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+)
+
+// auditBodyLimit is the largest request body the audit log reads when the handler reports no change itself
+const auditBodyLimit = 64 << 10
+
+// auditResponseLimit is how much of the response is kept to tell whether the action succeeded
+const auditResponseLimit = 4 << 10
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *auditResponseWriter) capture(data []byte) {
+	if room := auditResponseLimit - len(w.body); room > 0 {
+		if len(data) < room {
+			room = len(data)
+		}
+		w.body = append(w.body, data[:room]...)
+	}
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditResponseWriter) succeeded(status int) bool {
+	var response struct {
+		Success *bool `json:"success"`
+	}
+	if json.Unmarshal(w.body, &response) == nil && response.Success != nil {
+		return *response.Success
+	}
+	return status < http.StatusBadRequest
+}
+
+// getAuditTargetId falls back to the id in the path or the body when the handler did not name its target
+func getAuditTargetId(c *gin.Context, body []byte) string {
+	if id, ok := c.Get("audit_target_id"); ok {
+		return id.(string)
+	}
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	var fields map[string]any
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	for _, key := range []string{"id", "username"} {
+		if value, ok := fields[key]; ok && value != nil && value != "" {
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return ""
+}
+
+// AuditLog records every mutating api call, and the reads a handler marks with an audit target
+func AuditLog() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		mutating := method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+		var body []byte
+		if mutating && c.Request.ContentLength > 0 && c.Request.ContentLength <= auditBodyLimit {
+			body, _ = common.GetRequestBody(c)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+		}
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		_, marked := c.Get("audit_target_id")
+		if !mutating && !marked {
+			return
+		}
+		before, hasBefore := c.Get("audit_before")
+		after, hasAfter := c.Get("audit_after")
+		if !hasBefore && !hasAfter && body != nil {
+			after = body
+		}
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		targetType := strings.SplitN(strings.TrimPrefix(c.Request.URL.Path, "/api/"), "/", 2)[0]
+		model.RecordAuditLog(&model.AuditLog{
+			UserId:     c.GetInt("id"),
+			Action:     method + " " + path,
+			TargetType: targetType,
+			TargetId:   getAuditTargetId(c, body),
+			Changes:    common.AuditChanges(before, after),
+			Ip:         c.ClientIP(),
+			RequestId:  c.GetString(common.RequestIdKey),
+			Success:    writer.succeeded(writer.Status()),
+		})
+	}
+}
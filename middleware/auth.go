@@ -58,6 +58,17 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// the enrollment is looked up rather than remembered at login, access tokens have no login and the
+	// requirement may be set while a session is open
+	if model.IsTwoFARequired(role.(int)) && !isTwoFASetupPath(c) && !model.IsTwoFAEnabled(id.(int)) {
+		c.JSON(http.StatusOK, gin.H{
+			"success":           false,
+			"message":           "管理员要求启用两步验证，请先在个人设置中完成设置",
+			"require_2fa_setup": true,
+		})
+		c.Abort()
+		return
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
@@ -96,6 +107,17 @@ func RootAuth() func(c *gin.Context) {
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		key := c.Request.Header.Get("Authorization")
+		if key == "" && strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+			// Anthropic SDKs authenticate with x-api-key
+			key = c.Request.Header.Get("x-api-key")
+		}
+		if key == "" && strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+			// Google GenAI SDKs authenticate with x-goog-api-key or ?key=
+			key = c.Request.Header.Get("x-goog-api-key")
+			if key == "" {
+				key = c.Query("key")
+			}
+		}
 		parts := make([]string, 0)
 		key = strings.TrimPrefix(key, "Bearer ")
 		if key == "" || key == "midjourney-proxy" {
@@ -114,6 +136,11 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusUnauthorized, err.Error())
 			return
 		}
+		if ip := c.ClientIP(); !token.IsIpAllowed(ip) {
+			recordTokenIpDenial(token, ip)
+			abortWithOpenAiMessage(c, http.StatusForbidden, "该令牌不允许从当前 IP 访问")
+			return
+		}
 		userEnabled, err := model.CacheIsUserEnabled(token.UserId)
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusInternalServerError, err.Error())
@@ -123,19 +150,7 @@ func TokenAuth() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusForbidden, "用户已被封禁")
 			return
 		}
-		c.Set("id", token.UserId)
-		c.Set("token_id", token.Id)
-		c.Set("token_name", token.Name)
-		c.Set("token_unlimited_quota", token.UnlimitedQuota)
-		if !token.UnlimitedQuota {
-			c.Set("token_quota", token.RemainQuota)
-		}
-		if token.ModelLimitsEnabled {
-			c.Set("token_model_limit_enabled", true)
-			c.Set("token_model_limit", token.GetModelLimitsMap())
-		} else {
-			c.Set("token_model_limit_enabled", false)
-		}
+		SetupContextForToken(c, token)
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set("specific_channel_id", parts[1])
@@ -147,3 +162,24 @@ func TokenAuth() func(c *gin.Context) {
 		c.Next()
 	}
 }
+
+// SetupContextForToken stores the authenticated token in the context the way the relay expects it
+func SetupContextForToken(c *gin.Context, token *model.Token) {
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("token_name", token.Name)
+	c.Set("token_unlimited_quota", token.UnlimitedQuota)
+	c.Set("token_rpm_limit", token.RPMLimit)
+	c.Set("token_tpm_limit", token.TPMLimit)
+	c.Set("token_concurrency_limit", token.ConcurrencyLimit)
+	c.Set("token_scopes", token.GetScopes())
+	if !token.UnlimitedQuota {
+		c.Set("token_quota", token.RemainQuota)
+	}
+	if token.ModelLimitsEnabled {
+		c.Set("token_model_limit_enabled", true)
+		c.Set("token_model_limit", token.GetModelLimitsMap())
+	} else {
+		c.Set("token_model_limit_enabled", false)
+	}
+}
@@ -1,12 +1,18 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"strconv"
@@ -21,6 +27,14 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		if !checkTokenScope(c) {
+			return
+		}
+		release, ok := limitTokenRate(c)
+		if !ok {
+			return
+		}
+		defer release()
 		userId := c.GetInt("id")
 		var channel *model.Channel
 		channelId, ok := c.Get("specific_channel_id")
@@ -42,6 +56,8 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, "该渠道已被禁用")
 				return
 			}
+			// a channel named by the request is not held back by its limits, but its requests still count
+			model.AcquireChannel(channel)
 		} else {
 			// Select a channel for the user
 			// check token model mapping
@@ -66,8 +82,35 @@ func Distribute() func(c *gin.Context) {
 				}
 			}
 
+			// an alias is served by the channels of the model it stands for
+			modelRequest.Model = common.ResolveModelAlias(modelRequest.Model)
+
 			if shouldSelectChannel {
-				channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, modelRequest.Model, 0)
+				if common.StickyRoutingEnabled {
+					if stickyKey := getStickyKey(c, userGroup, modelRequest.Model); stickyKey != "" {
+						c.Set("sticky_key", stickyKey)
+						stickyChannel, stickyKeyId := model.GetStickyChannel(stickyKey, userGroup, modelRequest.Model)
+						if stickyChannel != nil {
+							channel, err = stickyChannel, nil
+							c.Set("sticky_channel_id", channel.Id)
+							c.Set("sticky_key_id", stickyKeyId)
+						}
+					}
+				}
+				if channel == nil {
+					channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, modelRequest.Model, 0)
+				}
+				if err != nil {
+					if fallbackChannel, fallbackModel, fallbackIndex := SelectFallbackChannel(c, userGroup, modelRequest.Model, 0); fallbackChannel != nil {
+						channel, err = fallbackChannel, nil
+						c.Set("fallback_model", fallbackModel)
+						c.Set("fallback_index", fallbackIndex)
+					}
+				}
+				if errors.Is(err, model.ErrChannelsSaturated) {
+					abortWithOpenAiMessage(c, http.StatusTooManyRequests, err.Error())
+					return
+				}
 				if err != nil {
 					message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, modelRequest.Model)
 					// 如果错误，但是渠道不为空，说明是数据库一致性问题
@@ -85,9 +128,99 @@ func Distribute() func(c *gin.Context) {
 				}
 			}
 		}
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
-		c.Next()
+		err = SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusServiceUnavailable, "渠道密钥解密失败，该渠道已被禁用，请重试")
+		} else {
+			c.Next()
+		}
+		if c.GetBool("channel_limited") {
+			model.ReleaseChannel(c.GetInt("channel_id"))
+		}
+	}
+}
+
+// SelectFallbackChannel picks a channel for the first model from position `start` on in the fallback chain
+// of modelName that has one, models the token may not use are skipped, it returns the position of the model
+// picked so the next fallback starts after it and a chain is never walked twice
+func SelectFallbackChannel(c *gin.Context, group string, modelName string, start int) (*model.Channel, string, int) {
+	fallbacks := common.GetModelFallbacks(group, modelName)
+	for i := start; i < len(fallbacks); i++ {
+		if !tokenAllowsModel(c, fallbacks[i]) {
+			continue
+		}
+		channel, err := model.CacheGetRandomSatisfiedChannel(group, fallbacks[i], 0)
+		if err == nil && channel != nil {
+			return channel, fallbacks[i], i
+		}
+	}
+	return nil, "", len(fallbacks)
+}
+
+func tokenAllowsModel(c *gin.Context, modelName string) bool {
+	if !c.GetBool("token_model_limit_enabled") {
+		return true
+	}
+	s, ok := c.Get("token_model_limit")
+	if !ok {
+		return false
 	}
+	tokenModelLimit, _ := s.(map[string]bool)
+	_, ok = tokenModelLimit[modelName]
+	return ok
+}
+
+// stickySession holds the parts of a request that identify its conversation
+type stickySession struct {
+	User     string            `json:"user"`
+	System   json.RawMessage   `json:"system"`
+	Messages []json.RawMessage `json:"messages"`
+	Contents []json.RawMessage `json:"contents"`
+}
+
+// getStickyKey identifies the session of a request by the sticky routing header, the user field
+// or the messages up to the first user message, which stay the same over a conversation
+func getStickyKey(c *gin.Context, group string, modelName string) string {
+	session := ""
+	if common.StickyRoutingHeader != "" {
+		session = c.Request.Header.Get(common.StickyRoutingHeader)
+	}
+	if session == "" && strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		request := stickySession{}
+		err := common.UnmarshalBodyReusable(c, &request)
+		if err != nil {
+			return ""
+		}
+		if request.User != "" {
+			session = "user:" + request.User
+		} else {
+			messages := request.Messages
+			if len(messages) == 0 {
+				messages = request.Contents
+			}
+			leading := make([]json.RawMessage, 0)
+			for _, message := range messages {
+				leading = append(leading, message)
+				var role struct {
+					Role string `json:"role"`
+				}
+				_ = json.Unmarshal(message, &role)
+				if role.Role == "user" {
+					break
+				}
+			}
+			if len(leading) == 0 {
+				return ""
+			}
+			leadingJson, _ := json.Marshal(leading)
+			session = "messages:" + string(request.System) + string(leadingJson)
+		}
+	}
+	if session == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s\n%s", c.GetInt("id"), group, modelName, session)))
+	return hex.EncodeToString(hash[:])
 }
 
 func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
@@ -125,6 +258,21 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 			modelRequest.Model = midjourneyModel
 		}
 		c.Set("relay_mode", relayMode)
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+		// native gemini api carries the model in the path
+		modelRequest.Model, _ = relaycommon.GetGeminiModelAction(c.Request.URL.Path)
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		var form *multipart.Form
+		form, err = common.ParseMultipartFormReusable(c)
+		if err == nil {
+			if len(form.Value["model"]) > 0 {
+				modelRequest.Model = form.Value["model"][0]
+			}
+			_ = form.RemoveAll()
+		}
+		if modelRequest.Model == "" {
+			modelRequest.Model = "dall-e-2"
+		}
 	} else if !strings.HasPrefix(c.Request.URL.Path, "/v1/audio/transcriptions") {
 		err = common.UnmarshalBodyReusable(c, &modelRequest)
 	}
@@ -159,11 +307,19 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	return &modelRequest, shouldSelectChannel, nil
 }
 
-func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
+// SetupContextForSelectedChannel sets the channel up for the request, a channel whose key cannot be decrypted
+// is disabled and an error returned, the request must not be sent with it
+func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) error {
 	c.Set("original_model", modelName) // for retry
 	if channel == nil {
-		return
+		return nil
+	}
+	// a retry moves the request to another channel
+	if c.GetBool("channel_limited") {
+		model.ReleaseChannel(c.GetInt("channel_id"))
 	}
+	// the selectors reserve a request slot of the channel they pick, it is released once the request is done
+	c.Set("channel_limited", channel.HasLimits())
 	c.Set("channel", channel.Type)
 	c.Set("channel_id", channel.Id)
 	c.Set("channel_name", channel.Name)
@@ -178,7 +334,22 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	c.Set("auto_ban", ban)
 	c.Set("model_mapping", channel.GetModelMapping())
 	c.Set("status_code_mapping", channel.GetStatusCodeMapping())
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	c.Set("param_override", channel.GetParamOverride())
+	c.Set("proxy", channel.GetProxy())
+	c.Set("header_override", channel.GetHeaderOverride())
+	// a sticky session keeps the key it was served with
+	preferredKeyId := 0
+	if c.GetInt("sticky_channel_id") == channel.Id {
+		preferredKeyId = c.GetInt("sticky_key_id")
+	}
+	key, keyId, err := model.SelectPreferredChannelKey(channel, preferredKeyId)
+	if err != nil {
+		common.SysError(err.Error())
+		service.DisableChannel(channel.Id, channel.Name, err.Error())
+		return err
+	}
+	c.Set("channel_key_id", keyId)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 	c.Set("base_url", channel.GetBaseURL())
 	// TODO: api_version统一
 	switch channel.Type {
@@ -193,4 +364,5 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	case common.ChannelTypeAli:
 		c.Set("plugin", channel.Other)
 	}
+	return nil
 }
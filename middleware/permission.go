@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+)
+
+// RequirePermission guards an admin route with a named permission, the role is read from the database
+// so that a changed admin role applies to sessions that are already logged in
+func RequirePermission(permission string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		user, err := model.GetUserById(c.GetInt("id"), false)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if !common.HasPermission(user.Role, user.AdminRole, permission) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无权进行此操作，缺少权限 " + permission,
+			})
+			c.Abort()
+			return
+		}
+		c.Set("role", user.Role)
+		c.Set("admin_role", user.AdminRole)
+		c.Next()
+	}
+}
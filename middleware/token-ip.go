@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"sync"
+	"time"
+)
+
+// tokenIpDenialLogInterval keeps a leaked key hammered from one address from flooding the logs
+const tokenIpDenialLogInterval = time.Minute
+
+var tokenIpDenialLock sync.Mutex
+var tokenIpDenials = make(map[string]time.Time)
+
+// recordTokenIpDenial logs a request refused by the ip allow list of a token, at most once a minute per token and ip
+func recordTokenIpDenial(token *model.Token, ip string) {
+	key := fmt.Sprintf("%d:%s", token.Id, ip)
+	now := time.Now()
+	tokenIpDenialLock.Lock()
+	if last, ok := tokenIpDenials[key]; ok && now.Sub(last) < tokenIpDenialLogInterval {
+		tokenIpDenialLock.Unlock()
+		return
+	}
+	if len(tokenIpDenials) > 10000 {
+		tokenIpDenials = make(map[string]time.Time)
+	}
+	tokenIpDenials[key] = now
+	tokenIpDenialLock.Unlock()
+	content := fmt.Sprintf("令牌 %s（#%d）拒绝了来自 %s 的请求：该 IP 不在令牌的 IP 白名单内", token.Name, token.Id, ip)
+	common.SysLog(content)
+	model.RecordLog(token.UserId, model.LogTypeSystem, content)
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"strconv"
+	"time"
+)
+
+func formatRateLimitReset(reset time.Duration) string {
+	return reset.Round(time.Millisecond).String()
+}
+
+func abortWithRateLimit(c *gin.Context, reset time.Duration, limitType string, message string) {
+	c.Header("Retry-After", strconv.Itoa(int((reset+time.Second-1)/time.Second)))
+	abortWithRelayError(c, http.StatusTooManyRequests, dto.OpenAIError{
+		Message: message,
+		Type:    limitType,
+		Code:    "rate_limit_exceeded",
+	})
+}
+
+// limitTokenRate enforces the rpm, tpm and concurrency limits of the token with OpenAI style headers,
+// the returned func gives the concurrency slot back once the request is done
+func limitTokenRate(c *gin.Context) (func(), bool) {
+	tokenId := c.GetInt("token_id")
+	if tpm := c.GetInt("token_tpm_limit"); tpm > 0 {
+		ok, remaining, reset := common.CheckTokenTokens(tokenId, tpm)
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(tpm))
+		c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(remaining))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(reset))
+		if !ok {
+			abortWithRateLimit(c, reset, "tokens", fmt.Sprintf("令牌已达到每分钟 token 数限制 %d，请稍后重试", tpm))
+			return nil, false
+		}
+	}
+	if rpm := c.GetInt("token_rpm_limit"); rpm > 0 {
+		ok, remaining, reset := common.TakeTokenRequest(tokenId, rpm)
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(rpm))
+		c.Header("x-ratelimit-remaining-requests", strconv.Itoa(remaining))
+		c.Header("x-ratelimit-reset-requests", formatRateLimitReset(reset))
+		if !ok {
+			abortWithRateLimit(c, reset, "requests", fmt.Sprintf("令牌已达到每分钟请求数限制 %d，请稍后重试", rpm))
+			return nil, false
+		}
+	}
+	if concurrency := c.GetInt("token_concurrency_limit"); concurrency > 0 {
+		lease, ok := common.AcquireTokenConcurrency(tokenId, concurrency)
+		if !ok {
+			abortWithRateLimit(c, time.Second, "requests", fmt.Sprintf("令牌已达到并发请求数限制 %d，请稍后重试", concurrency))
+			return nil, false
+		}
+		return func() {
+			common.ReleaseTokenConcurrency(tokenId, lease)
+		}, true
+	}
+	return func() {}, true
+}
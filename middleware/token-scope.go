@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/constant"
+	relayconstant "one-api/relay/constant"
+	"strings"
+)
+
+// checkTokenScope refuses a request to an api surface outside the scopes of the token, before a channel is selected
+func checkTokenScope(c *gin.Context) bool {
+	scopes := c.GetStringSlice("token_scopes")
+	if len(scopes) == 0 {
+		return true
+	}
+	midjourney := strings.Contains(c.Request.URL.Path, "/mj/")
+	var relayMode int
+	if midjourney {
+		relayMode = relayconstant.Path2RelayModeMidjourney(c.Request.URL.Path)
+	} else {
+		relayMode = relayconstant.Path2RelayMode(c.Request.URL.Path)
+	}
+	scope := relayconstant.RelayMode2Scope(relayMode)
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	message := "该令牌无权访问此接口"
+	if scope != "" {
+		message = fmt.Sprintf("该令牌无权访问 %s 接口，允许的范围：%s", scope, strings.Join(scopes, ","))
+	}
+	if midjourney {
+		abortWithMidjourneyMessage(c, http.StatusForbidden, constant.MjErrorUnknown, message)
+	} else {
+		abortWithOpenAiMessage(c, http.StatusForbidden, message)
+	}
+	return false
+}
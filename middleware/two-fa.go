@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+)
+
+// twoFAVerifyWindow is how many seconds a 2fa verification covers the sensitive operations of a session
+const twoFAVerifyWindow = 600
+
+// isTwoFASetupPath reports whether the request is allowed before a user required to enable 2fa did so
+func isTwoFASetupPath(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return strings.HasPrefix(path, "/api/user/2fa/") || (path == "/api/user/self" && c.Request.Method == http.MethodGet)
+}
+
+// RequireTwoFA guards sensitive operations, users with 2fa enabled must have verified it within the window or send
+// a code in the New-Api-2FA-Code header, users required to enable 2fa cannot perform them before they did
+func RequireTwoFA() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		id := c.GetInt("id")
+		twoFA, err := model.GetTwoFAByUserId(id)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if twoFA == nil || !twoFA.Enabled {
+			if model.IsTwoFARequired(c.GetInt("role")) {
+				c.JSON(http.StatusOK, gin.H{
+					"success":           false,
+					"message":           "该操作需要两步验证，请先在个人设置中启用两步验证",
+					"require_2fa_setup": true,
+				})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		session := sessions.Default(c)
+		verifiedAt, _ := session.Get("two_fa_verified_at").(int64)
+		if session.Get("id") == id && verifiedAt != 0 && common.GetTimestamp()-verifiedAt <= twoFAVerifyWindow {
+			c.Next()
+			return
+		}
+		if code := c.GetHeader("New-Api-2FA-Code"); code != "" && twoFA.Verify(code) {
+			c.Next()
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":     false,
+			"message":     "该操作需要两步验证",
+			"require_2fa": true,
+		})
+		c.Abort()
+	}
+}
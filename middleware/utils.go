@@ -3,16 +3,35 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel/claude"
+	"one-api/relay/channel/gemini"
+	relayconstant "one-api/relay/constant"
 )
 
+// abortWithRelayError answers in the error format of the api the request was made to, as Relay does,
+// the Claude messages and Gemini routes have formats of their own
+func abortWithRelayError(c *gin.Context, statusCode int, openaiError dto.OpenAIError) {
+	openaiError.Message = common.MessageWithRequestId(openaiError.Message, c.GetString(common.RequestIdKey))
+	openaiErr := &dto.OpenAIErrorWithStatusCode{Error: openaiError, StatusCode: statusCode}
+	switch relayconstant.Path2RelayMode(c.Request.URL.Path) {
+	case relayconstant.RelayModeClaudeMessages:
+		c.JSON(statusCode, claude.ErrorOpenAI2Claude(openaiErr))
+	case relayconstant.RelayModeGemini:
+		c.JSON(statusCode, gemini.ErrorOpenAI2Gemini(openaiErr))
+	default:
+		c.JSON(statusCode, gin.H{
+			"error": openaiErr.Error,
+		})
+	}
+	c.Abort()
+}
+
 func abortWithOpenAiMessage(c *gin.Context, statusCode int, message string) {
-	c.JSON(statusCode, gin.H{
-		"error": gin.H{
-			"message": common.MessageWithRequestId(message, c.GetString(common.RequestIdKey)),
-			"type":    "new_api_error",
-		},
+	abortWithRelayError(c, statusCode, dto.OpenAIError{
+		Message: message,
+		Type:    "new_api_error",
 	})
-	c.Abort()
 	common.LogError(c.Request.Context(), message)
 }
 
@@ -4,8 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/samber/lo"
-	"gorm.io/gorm"
+	"math/rand"
 	"one-api/common"
+	"sort"
 	"strings"
 )
 
@@ -18,6 +19,13 @@ type Ability struct {
 	Weight    uint   `json:"weight" gorm:"default:0;index"`
 }
 
+func (ability *Ability) GetPriority() int64 {
+	if ability.Priority == nil {
+		return 0
+	}
+	return *ability.Priority
+}
+
 func GetGroupModels(group string) []string {
 	var models []string
 	// Find distinct models
@@ -36,93 +44,129 @@ func GetEnabledModels() []string {
 	return models
 }
 
-func getPriority(group string, model string, retry int) (int, error) {
-	groupCol := "`group`"
-	trueVal := "1"
-	if common.UsingPostgreSQL {
-		groupCol = `"group"`
-		trueVal = "true"
-	}
-
-	var priorities []int
-	err := DB.Model(&Ability{}).
-		Select("DISTINCT(priority)").
-		Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model).
-		Order("priority DESC").              // 按优先级降序排序
-		Pluck("priority", &priorities).Error // Pluck用于将查询的结果直接扫描到一个切片中
-
-	if err != nil {
-		// 处理错误
-		return 0, err
-	}
-
-	// 确定要使用的优先级
-	var priorityToUse int
-	if retry >= len(priorities) {
-		// 如果重试次数大于优先级数，则使用最小的优先级
-		priorityToUse = priorities[len(priorities)-1]
-	} else {
-		priorityToUse = priorities[retry]
+func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+	// the channels that reached their limits since they were checked are left out and another one is picked
+	excluded := make(map[int]bool)
+	for {
+		// the limits and breakers have to be consulted before the priority is chosen
+		abilities, err := getSelectableAbilities(group, model, retry, excluded)
+		if err != nil {
+			return nil, err
+		}
+		if len(abilities) == 0 {
+			if len(excluded) > 0 {
+				return nil, ErrChannelsSaturated
+			}
+			return nil, errors.New("channel not found")
+		}
+		channelIds := make([]int, 0, len(abilities))
+		for _, ability_ := range abilities {
+			channelIds = append(channelIds, ability_.ChannelId)
+		}
+		factors := getChannelWeightFactors(group, model, channelIds)
+		// Randomly choose one
+		weightSum := 0.0
+		for i, ability_ := range abilities {
+			weightSum += float64(ability_.Weight+10) * factors[i]
+		}
+		// Randomly choose one
+		channel := Channel{Id: abilities[len(abilities)-1].ChannelId}
+		weight := rand.Float64() * weightSum
+		for i, ability_ := range abilities {
+			weight -= float64(ability_.Weight+10) * factors[i]
+			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
+			if weight <= 0 {
+				channel.Id = ability_.ChannelId
+				break
+			}
+		}
+		err = DB.First(&channel, "id = ?", channel.Id).Error
+		if err != nil {
+			return &channel, err
+		}
+		if ReserveChannel(&channel) {
+			return &channel, nil
+		}
+		excluded[channel.Id] = true
 	}
-	return priorityToUse, nil
 }
 
-func getChannelQuery(group string, model string, retry int) *gorm.DB {
+// getSelectableAbilities returns the abilities of the priority for the given retry number,
+// counting only the channels below their limits that their circuit breakers let through and are not excluded
+func getSelectableAbilities(group string, model string, retry int, excluded map[int]bool) ([]Ability, error) {
 	groupCol := "`group`"
 	trueVal := "1"
 	if common.UsingPostgreSQL {
 		groupCol = `"group"`
 		trueVal = "true"
 	}
-	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
-	channelQuery := DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = (?)", group, model, maxPrioritySubQuery)
-	if retry != 0 {
-		priority, err := getPriority(group, model, retry)
-		if err != nil {
-			common.SysError(fmt.Sprintf("Get priority failed: %s", err.Error()))
-		} else {
-			channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = ?", group, model, priority)
+	var candidates []Ability
+	// the wildcard and regex models of the group are matched here
+	err := DB.Where(groupCol+" = ? and (model = ? or model like ? or model like ?) and enabled = "+trueVal, group, model, "%*%", common.ModelRegexPrefix+"%").
+		Order("weight DESC").Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	abilities := make([]Ability, 0, len(candidates))
+	seen := make(map[int]bool)
+	for _, ability_ := range candidates {
+		if !seen[ability_.ChannelId] && !excluded[ability_.ChannelId] && common.ModelNameMatches(ability_.Model, model) {
+			seen[ability_.ChannelId] = true
+			abilities = append(abilities, ability_)
 		}
 	}
-
-	return channelQuery
-}
-
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
-	var abilities []Ability
-
-	var err error = nil
-	channelQuery := getChannelQuery(group, model, retry)
-	if common.UsingSQLite || common.UsingPostgreSQL {
-		err = channelQuery.Order("weight DESC").Find(&abilities).Error
-	} else {
-		err = channelQuery.Order("weight DESC").Find(&abilities).Error
+	if len(abilities) == 0 {
+		return abilities, nil
 	}
+	channelIds := make([]int, 0, len(abilities))
+	for _, ability_ := range abilities {
+		channelIds = append(channelIds, ability_.ChannelId)
+	}
+	var channels []*Channel
+	err = DB.Select("id", "rpm_limit", "tpm_limit", "max_concurrency").Where("id in (?)", channelIds).Find(&channels).Error
 	if err != nil {
 		return nil, err
 	}
-	channel := Channel{}
-	if len(abilities) > 0 {
-		// Randomly choose one
-		weightSum := uint(0)
-		for _, ability_ := range abilities {
-			weightSum += ability_.Weight + 10
+	id2limit := make(map[int]channelLimit, len(channels))
+	for _, channel := range channels {
+		id2limit[channel.Id] = channel.getLimit()
+	}
+	limits := make([]channelLimit, len(channelIds))
+	for i, channelId := range channelIds {
+		limits[i] = id2limit[channelId]
+	}
+	allowed, err := filterSelectableChannels(model, channelIds, limits)
+	if err != nil {
+		return nil, err
+	}
+	uniquePriorities := make(map[int64]bool)
+	allowedAbilities := make([]Ability, 0, len(abilities))
+	for i, ability_ := range abilities {
+		if allowed[i] {
+			allowedAbilities = append(allowedAbilities, ability_)
+			uniquePriorities[ability_.GetPriority()] = true
 		}
-		// Randomly choose one
-		weight := common.GetRandomInt(int(weightSum))
-		for _, ability_ := range abilities {
-			weight -= int(ability_.Weight) + 10
-			//log.Printf("weight: %d, ability weight: %d", weight, *ability_.Weight)
-			if weight <= 0 {
-				channel.Id = ability_.ChannelId
-				break
-			}
+	}
+	if len(allowedAbilities) == 0 {
+		return allowedAbilities, nil
+	}
+	sortedPriorities := make([]int64, 0, len(uniquePriorities))
+	for priority := range uniquePriorities {
+		sortedPriorities = append(sortedPriorities, priority)
+	}
+	sort.Slice(sortedPriorities, func(i, j int) bool {
+		return sortedPriorities[i] > sortedPriorities[j]
+	})
+	if retry >= len(sortedPriorities) {
+		retry = len(sortedPriorities) - 1
+	}
+	targetAbilities := make([]Ability, 0, len(allowedAbilities))
+	for _, ability_ := range allowedAbilities {
+		if ability_.GetPriority() == sortedPriorities[retry] {
+			targetAbilities = append(targetAbilities, ability_)
 		}
-	} else {
-		return nil, errors.New("channel not found")
 	}
-	err = DB.First(&channel, "id = ?", channel.Id).Error
-	return &channel, err
+	return targetAbilities, nil
 }
 
 func (channel *Channel) AddAbilities() error {
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"one-api/common"
+)
+
+// AuditLog records a management action, changes holds the redacted before/after diff
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Username   string `json:"username" gorm:"index;default:''"`
+	Action     string `json:"action" gorm:"type:varchar(128);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(64);index"`
+	Changes    string `json:"changes" gorm:"type:text"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);default:''"`
+	Success    bool   `json:"success"`
+}
+
+type AuditLogFilter struct {
+	Username       string
+	Action         string
+	TargetType     string
+	TargetId       string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func RecordAuditLog(log *AuditLog) {
+	if log.Username == "" && log.UserId != 0 {
+		log.Username, _ = CacheGetUsername(log.UserId)
+	}
+	log.CreatedAt = common.GetTimestamp()
+	err := DB.Create(log).Error
+	if err != nil {
+		common.SysError("failed to record audit log: " + err.Error())
+	}
+}
+
+func (filter *AuditLogFilter) apply(tx *gorm.DB) *gorm.DB {
+	if filter.Username != "" {
+		tx = tx.Where("username = ?", filter.Username)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action LIKE ?", "%"+filter.Action+"%")
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetId != "" {
+		tx = tx.Where("target_id = ?", filter.TargetId)
+	}
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	return tx
+}
+
+func GetAuditLogs(filter *AuditLogFilter, startIdx int, num int) (logs []*AuditLog, total int64, err error) {
+	tx := filter.apply(DB.Model(&AuditLog{}))
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, total, err
+}
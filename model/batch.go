@@ -0,0 +1,98 @@
+package model
+
+import (
+	"one-api/common"
+)
+
+// Batch is a job submitted through the Batch API, the requests of the input
+// file are executed in order and RequestCompleted + RequestFailed lines of
+// it have already been written to the output and error files
+type Batch struct {
+	Id               int    `json:"id"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	TokenId          int    `json:"token_id"`
+	Endpoint         string `json:"endpoint"`
+	InputFileId      string `json:"input_file_id"`
+	OutputFileId     string `json:"output_file_id"`
+	ErrorFileId      string `json:"error_file_id"`
+	CompletionWindow string `json:"completion_window"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	Errors           string `json:"errors"`
+	Metadata         string `json:"metadata"`
+	RequestTotal     int    `json:"request_total"`
+	RequestCompleted int    `json:"request_completed"`
+	RequestFailed    int    `json:"request_failed"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint"`
+}
+
+func GetUserBatches(userId int, startIdx int, num int) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("user_id = ?", userId).Order("id desc").Limit(num).Offset(startIdx).Find(&batches).Error
+	return batches, err
+}
+
+func GetBatchByBatchId(userId int, batchId string) (*Batch, error) {
+	var batch *Batch
+	err := DB.Where("user_id = ? and batch_id = ?", userId, batchId).First(&batch).Error
+	return batch, err
+}
+
+func GetBatchById(id int) (*Batch, error) {
+	var batch *Batch
+	err := DB.Where("id = ?", id).First(&batch).Error
+	return batch, err
+}
+
+func GetAllUnfinishedBatches() []*Batch {
+	var batches []*Batch
+	err := DB.Where("status in (?)", []string{common.BatchStatusValidating, common.BatchStatusInProgress,
+		common.BatchStatusFinalizing, common.BatchStatusCancelling}).Order("id").Find(&batches).Error
+	if err != nil {
+		return nil
+	}
+	return batches
+}
+
+func (batch *Batch) Insert() error {
+	return DB.Create(batch).Error
+}
+
+// UpdateStatus saves the status of the batch along with the given columns if the status is still `from`,
+// otherwise nothing is saved, the batch is reloaded and false is returned, so a concurrent cancel is kept
+func (batch *Batch) UpdateStatus(from string, columns ...string) (bool, error) {
+	result := DB.Model(batch).Where("status = ?", from).Select(append([]string{"status"}, columns...)).Updates(batch)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+	current, err := GetBatchById(batch.Id)
+	if err != nil {
+		return false, err
+	}
+	*batch = *current
+	return false, nil
+}
+
+// UpdateProgress saves the counters only, so that a concurrent cancel is not overwritten
+func (batch *Batch) UpdateProgress() error {
+	return DB.Model(batch).Select("request_completed", "request_failed").Updates(batch).Error
+}
+
+// Cancel marks the batch as cancelling if it has not finished yet
+func (batch *Batch) Cancel() (bool, error) {
+	result := DB.Model(&Batch{}).Where("id = ? and status in (?)", batch.Id,
+		[]string{common.BatchStatusValidating, common.BatchStatusInProgress}).
+		Updates(map[string]any{"status": common.BatchStatusCancelling, "cancelling_at": common.GetTimestamp()})
+	return result.RowsAffected > 0, result.Error
+}
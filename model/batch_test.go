@@ -0,0 +1,52 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestBatchUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancel     bool
+		want       bool
+		wantStatus string
+	}{
+		{"status unchanged", false, true, common.BatchStatusFinalizing},
+		{"cancelled in the meantime", true, false, common.BatchStatusCancelling},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Batch{})
+			batch := &Batch{BatchId: "batch_test", Status: common.BatchStatusInProgress}
+			if err := batch.Insert(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.cancel {
+				if ok, err := (&Batch{Id: batch.Id}).Cancel(); !ok || err != nil {
+					t.Fatalf("Cancel() = %v, %v", ok, err)
+				}
+			}
+			batch.RequestCompleted = 3
+			batch.Status = common.BatchStatusFinalizing
+			batch.FinalizingAt = 100
+			ok, err := batch.UpdateStatus(common.BatchStatusInProgress, "finalizing_at")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want || batch.Status != tt.wantStatus {
+				t.Errorf("UpdateStatus() = %v with status %s, want %v with status %s", ok, batch.Status, tt.want, tt.wantStatus)
+			}
+			stored, err := GetBatchById(batch.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("stored status %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if stored.RequestCompleted != 0 {
+				t.Errorf("stored request_completed %d, only the given columns may be saved", stored.RequestCompleted)
+			}
+		})
+	}
+}
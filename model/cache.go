@@ -20,7 +20,7 @@ var (
 	UserId2StatusCacheSeconds = common.SyncFrequency
 )
 
-// 仅用于定时同步缓存
+// 仅用于定时同步缓存，以令牌的哈希为键
 var token2UserId = make(map[string]int)
 var token2UserIdLock sync.RWMutex
 
@@ -29,27 +29,27 @@ func cacheSetToken(token *Token) error {
 	if err != nil {
 		return err
 	}
-	err = common.RedisSet(fmt.Sprintf("token:%s", token.Key), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
+	err = common.RedisSet(fmt.Sprintf("token:%s", token.KeyHash), string(jsonBytes), time.Duration(TokenCacheSeconds)*time.Second)
 	if err != nil {
-		common.SysError(fmt.Sprintf("failed to set token %s to redis: %s", token.Key, err.Error()))
+		common.SysError(fmt.Sprintf("failed to set token %d to redis: %s", token.Id, err.Error()))
 		return err
 	}
 	token2UserIdLock.Lock()
 	defer token2UserIdLock.Unlock()
-	token2UserId[token.Key] = token.UserId
+	token2UserId[token.KeyHash] = token.UserId
 	return nil
 }
 
-// CacheGetTokenByKey 从缓存中获取 token 并续期时间，如果缓存中不存在，则从数据库中获取
+// CacheGetTokenByKey 从缓存中获取 token 并续期时间，如果缓存中不存在，则从数据库中获取，缓存以令牌的哈希为键
 func CacheGetTokenByKey(key string) (*Token, error) {
 	if !common.RedisEnabled {
 		return GetTokenByKey(key)
 	}
-	var token *Token
-	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", key))
+	keyHash := common.HashTokenKey(key)
+	tokenObjectString, err := common.RedisGet(fmt.Sprintf("token:%s", keyHash))
 	if err != nil {
 		// 如果缓存中不存在，则从数据库中获取
-		token, err = GetTokenByKey(key)
+		token, err := getTokenByKeyHash(keyHash)
 		if err != nil {
 			return nil, err
 		}
@@ -57,8 +57,12 @@ func CacheGetTokenByKey(key string) (*Token, error) {
 		return token, nil
 	}
 	// 如果缓存中存在，则续期时间
-	err = common.RedisExpire(fmt.Sprintf("token:%s", key), time.Duration(TokenCacheSeconds)*time.Second)
+	err = common.RedisExpire(fmt.Sprintf("token:%s", keyHash), time.Duration(TokenCacheSeconds)*time.Second)
+	var token *Token
 	err = json.Unmarshal([]byte(tokenObjectString), &token)
+	if token != nil {
+		token.KeyHash = keyHash
+	}
 	return token, err
 }
 
@@ -76,7 +80,7 @@ func SyncTokenCache(frequency int) {
 		token2UserIdLock.Unlock()
 
 		for key := range copyToken2UserId {
-			token, err := GetTokenByKey(key)
+			token, err := getTokenByKeyHash(key)
 			if err != nil {
 				// 如果数据库中不存在，则删除缓存
 				common.SysError(fmt.Sprintf("failed to get token %s from database: %s", key, err.Error()))
@@ -206,9 +210,32 @@ func CacheIsUserEnabled(userId int) (bool, error) {
 }
 
 var group2model2channels map[string]map[string][]*Channel
+var group2patterns map[string][]string // the wildcard and regex model names of the channels of each group
 var channelsIDM map[int]*Channel
 var channelSyncLock sync.RWMutex
 
+// the parsed model mappings are kept by their json, they are rebuilt for the enabled channels on every sync
+var modelMappingLock sync.RWMutex
+var modelMappings = make(map[string]*common.ModelMapping)
+
+// GetModelMapping returns the parsed model mapping of a channel
+func GetModelMapping(mappingStr string) (*common.ModelMapping, error) {
+	modelMappingLock.RLock()
+	mapping, ok := modelMappings[mappingStr]
+	modelMappingLock.RUnlock()
+	if ok {
+		return mapping, nil
+	}
+	mapping, err := common.ParseModelMapping(mappingStr)
+	if err != nil {
+		return nil, err
+	}
+	modelMappingLock.Lock()
+	modelMappings[mappingStr] = mapping
+	modelMappingLock.Unlock()
+	return mapping, nil
+}
+
 func InitChannelCache() {
 	newChannelId2channel := make(map[int]*Channel)
 	var channels []*Channel
@@ -223,18 +250,35 @@ func InitChannelCache() {
 		groups[ability.Group] = true
 	}
 	newGroup2model2channels := make(map[string]map[string][]*Channel)
+	newGroup2patterns := make(map[string][]string)
 	newChannelsIDM := make(map[int]*Channel)
+	newModelMappings := make(map[string]*common.ModelMapping)
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]*Channel)
 	}
 	for _, channel := range channels {
 		newChannelsIDM[channel.Id] = channel
+		if mappingStr := channel.GetModelMapping(); mappingStr != "" {
+			mapping, err := common.ParseModelMapping(mappingStr)
+			if err != nil {
+				common.SysError(fmt.Sprintf("invalid model mapping of channel #%d: %s", channel.Id, err.Error()))
+			} else {
+				newModelMappings[mappingStr] = mapping
+			}
+		}
 		groups := strings.Split(channel.Group, ",")
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
 			for _, model := range models {
 				if _, ok := newGroup2model2channels[group][model]; !ok {
 					newGroup2model2channels[group][model] = make([]*Channel, 0)
+					if common.IsModelPattern(model) {
+						if _, err := common.CompileModelPattern(model); err != nil {
+							common.SysError(fmt.Sprintf("invalid model of channel #%d: %s", channel.Id, err.Error()))
+						} else {
+							newGroup2patterns[group] = append(newGroup2patterns[group], model)
+						}
+					}
 				}
 				newGroup2model2channels[group][model] = append(newGroup2model2channels[group][model], channel)
 			}
@@ -253,8 +297,13 @@ func InitChannelCache() {
 
 	channelSyncLock.Lock()
 	group2model2channels = newGroup2model2channels
+	group2patterns = newGroup2patterns
 	channelsIDM = newChannelsIDM
 	channelSyncLock.Unlock()
+	modelMappingLock.Lock()
+	modelMappings = newModelMappings
+	modelMappingLock.Unlock()
+	invalidateChannelKeyCache(0)
 	common.SysLog("channels synced from database")
 }
 
@@ -266,22 +315,87 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
-	if strings.HasPrefix(model, "gpt-4-gizmo") {
-		model = "gpt-4-gizmo-*"
+// getModelChannels returns the channels of the group serving the model by its exact name or a pattern, by priority
+func getModelChannels(group string, model string) []*Channel {
+	channels := group2model2channels[group][model]
+	var matched [][]*Channel
+	for _, pattern := range group2patterns[group] {
+		if pattern != model && common.ModelNameMatches(pattern, model) {
+			matched = append(matched, group2model2channels[group][pattern])
+		}
+	}
+	if len(matched) == 0 {
+		return channels
 	}
+	seen := make(map[int]bool)
+	merged := make([]*Channel, 0, len(channels))
+	for _, list := range append([][]*Channel{channels}, matched...) {
+		for _, channel := range list {
+			if !seen[channel.Id] {
+				seen[channel.Id] = true
+				merged = append(merged, channel)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].GetPriority() > merged[j].GetPriority()
+	})
+	return merged
+}
 
+func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
 		return GetRandomSatisfiedChannel(group, model, retry)
 	}
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	channels := getModelChannels(group, model)
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
+	channelIds := make([]int, 0, len(channels))
+	limits := make([]channelLimit, 0, len(channels))
+	for _, channel := range channels {
+		channelIds = append(channelIds, channel.Id)
+		limits = append(limits, channel.getLimit())
+	}
+	allowed, err := filterSelectableChannels(model, channelIds, limits)
+	if err != nil {
+		return nil, err
+	}
+	allowedChannels := make([]*Channel, 0, len(channels))
+	for i, channel := range channels {
+		if allowed[i] {
+			allowedChannels = append(allowedChannels, channel)
+		}
+	}
+	channels = allowedChannels
+	if len(channels) == 0 {
+		return nil, errors.New("channel not found")
+	}
+	// a channel that reached its limits since they were checked is left out and another one is picked
+	for len(channels) > 0 {
+		channel := pickWeightedChannel(group, model, channels, retry)
+		if channel == nil {
+			return nil, errors.New("channel not found")
+		}
+		if ReserveChannel(channel) {
+			return channel, nil
+		}
+		remaining := make([]*Channel, 0, len(channels)-1)
+		for _, c := range channels {
+			if c.Id != channel.Id {
+				remaining = append(remaining, c)
+			}
+		}
+		channels = remaining
+	}
+	return nil, ErrChannelsSaturated
+}
 
+// pickWeightedChannel picks one of the channels of the priority for the given retry number by weight
+func pickWeightedChannel(group string, model string, channels []*Channel, retry int) *Channel {
 	uniquePriorities := make(map[int]bool)
 	for _, channel := range channels {
 		uniquePriorities[int(channel.GetPriority())] = true
@@ -307,23 +421,28 @@ func CacheGetRandomSatisfiedChannel(group string, model string, retry int) (*Cha
 
 	// 平滑系数
 	smoothingFactor := 10
-	// Calculate the total weight of all channels up to endIdx
-	totalWeight := 0
+	channelIds := make([]int, 0, len(targetChannels))
 	for _, channel := range targetChannels {
-		totalWeight += channel.GetWeight() + smoothingFactor
+		channelIds = append(channelIds, channel.Id)
+	}
+	factors := getChannelWeightFactors(group, model, channelIds)
+	// Calculate the total weight of all channels up to endIdx
+	totalWeight := 0.0
+	for i, channel := range targetChannels {
+		totalWeight += float64(channel.GetWeight()+smoothingFactor) * factors[i]
 	}
 	// Generate a random value in the range [0, totalWeight)
-	randomWeight := rand.Intn(totalWeight)
+	randomWeight := rand.Float64() * totalWeight
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight() + smoothingFactor
+	for i, channel := range targetChannels {
+		randomWeight -= float64(channel.GetWeight()+smoothingFactor) * factors[i]
 		if randomWeight < 0 {
-			return channel, nil
+			return channel
 		}
 	}
 	// return null if no channel is not found
-	return nil, errors.New("channel not found")
+	return nil
 }
 
 func CacheGetChannel(id int) (*Channel, error) {
@@ -1,8 +1,10 @@
 package model
 
 import (
+	"fmt"
 	"gorm.io/gorm"
 	"one-api/common"
+	"strings"
 )
 
 type Channel struct {
@@ -27,8 +29,15 @@ type Channel struct {
 	ModelMapping       *string `json:"model_mapping" gorm:"type:varchar(1024);default:''"`
 	//MaxInputTokens     *int    `json:"max_input_tokens" gorm:"default:0"`
 	StatusCodeMapping *string `json:"status_code_mapping" gorm:"type:varchar(1024);default:''"`
+	ParamOverride     *string `json:"param_override" gorm:"type:text"`
+	Proxy             *string `json:"proxy" gorm:"type:varchar(255);default:''"` // http, https or socks5 proxy url
+	HeaderOverride    *string `json:"header_override" gorm:"type:text"`
 	Priority          *int64  `json:"priority" gorm:"bigint;default:0"`
 	AutoBan           *int    `json:"auto_ban" gorm:"default:1"`
+	KeyMode           *string `json:"key_mode" gorm:"type:varchar(32);default:''"` // empty for single key channels
+	RPMLimit          *int    `json:"rpm_limit" gorm:"column:rpm_limit;default:0"`
+	TPMLimit          *int    `json:"tpm_limit" gorm:"column:tpm_limit;default:0"`
+	MaxConcurrency    *int    `json:"max_concurrency" gorm:"default:0"`
 }
 
 func GetAllChannels(startIdx int, num int, selectAll bool, idSort bool) ([]*Channel, error) {
@@ -94,6 +103,12 @@ func GetChannelById(id int, selectAll bool) (*Channel, error) {
 
 func BatchInsertChannels(channels []Channel) error {
 	var err error
+	for i := range channels {
+		channels[i].Key, err = common.SealSecret(channels[i].Key)
+		if err != nil {
+			return err
+		}
+	}
 	err = DB.Create(&channels).Error
 	if err != nil {
 		return err
@@ -103,6 +118,10 @@ func BatchInsertChannels(channels []Channel) error {
 		if err != nil {
 			return err
 		}
+		_, err = syncChannelKeys(&channel_)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -122,6 +141,12 @@ func BatchDeleteChannels(ids []int) error {
 		tx.Rollback()
 		return err
 	}
+	err = tx.Where("channel_id in (?)", ids).Delete(&ChannelKey{}).Error
+	if err != nil {
+		// 回滚事务
+		tx.Rollback()
+		return err
+	}
 	// 提交事务
 	tx.Commit()
 	return err
@@ -155,6 +180,16 @@ func (channel *Channel) GetModelMapping() string {
 	return *channel.ModelMapping
 }
 
+// ServesModel reports whether one of the models of the channel, exact or a pattern, matches the model
+func (channel *Channel) ServesModel(model string) bool {
+	for _, name := range strings.Split(channel.Models, ",") {
+		if common.ModelNameMatches(name, model) {
+			return true
+		}
+	}
+	return false
+}
+
 func (channel *Channel) GetStatusCodeMapping() string {
 	if channel.StatusCodeMapping == nil {
 		return ""
@@ -162,24 +197,105 @@ func (channel *Channel) GetStatusCodeMapping() string {
 	return *channel.StatusCodeMapping
 }
 
+func (channel *Channel) GetParamOverride() string {
+	if channel.ParamOverride == nil {
+		return ""
+	}
+	return *channel.ParamOverride
+}
+
+func (channel *Channel) GetProxy() string {
+	if channel.Proxy == nil {
+		return ""
+	}
+	return *channel.Proxy
+}
+
+func (channel *Channel) GetHeaderOverride() string {
+	if channel.HeaderOverride == nil {
+		return ""
+	}
+	return *channel.HeaderOverride
+}
+
+func (channel *Channel) GetKeyMode() string {
+	if channel.KeyMode == nil {
+		return ""
+	}
+	return *channel.KeyMode
+}
+
+func (channel *Channel) IsMultiKey() bool {
+	return channel.GetKeyMode() != ""
+}
+
+// GetPlainKey returns the key of the channel, decrypted if it is stored encrypted, a key that cannot be
+// decrypted is an error and never returned as is, it would be sent upstream
+func (channel *Channel) GetPlainKey() (string, error) {
+	key, err := common.OpenSecret(channel.Key)
+	if err != nil {
+		return "", fmt.Errorf("渠道 #%d 的密钥解密失败：%s", channel.Id, err.Error())
+	}
+	return key, nil
+}
+
+// GetKeys returns the keys of a multi-key channel, they are separated by new lines
+func (channel *Channel) GetKeys() ([]string, error) {
+	plainKey, err := channel.GetPlainKey()
+	if err != nil {
+		return nil, err
+	}
+	if !channel.IsMultiKey() {
+		return []string{plainKey}, nil
+	}
+	keys := make([]string, 0)
+	for _, key := range strings.Split(plainKey, "\n") {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return []string{plainKey}, nil
+	}
+	return keys, nil
+}
+
 func (channel *Channel) Insert() error {
 	var err error
+	channel.Key, err = common.SealSecret(channel.Key)
+	if err != nil {
+		return err
+	}
 	err = DB.Create(channel).Error
 	if err != nil {
 		return err
 	}
 	err = channel.AddAbilities()
+	if err != nil {
+		return err
+	}
+	_, err = syncChannelKeys(channel)
 	return err
 }
 
 func (channel *Channel) Update() error {
 	var err error
+	channel.Key, err = common.SealSecret(channel.Key)
+	if err != nil {
+		return err
+	}
 	err = DB.Model(channel).Updates(channel).Error
 	if err != nil {
 		return err
 	}
 	DB.Model(channel).First(channel, "id = ?", channel.Id)
 	err = channel.UpdateAbilities()
+	if err != nil {
+		return err
+	}
+	_, err = syncChannelKeys(channel)
+	invalidateChannelKeyCache(channel.Id)
 	return err
 }
 
@@ -210,6 +326,10 @@ func (channel *Channel) Delete() error {
 		return err
 	}
 	err = channel.DeleteAbilities()
+	if err != nil {
+		return err
+	}
+	err = deleteChannelKeys([]int{channel.Id})
 	return err
 }
 
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"one-api/common"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+// breakerHalfOpenSuccesses is the number of consecutive successes that close a half-open breaker
+const breakerHalfOpenSuccesses = 3
+
+// circuitBreaker opens after CircuitBreakerThreshold consecutive failures, after the
+// cooldown it becomes half-open and lets a share of the traffic through as probes
+type circuitBreaker struct {
+	state     string
+	failures  int
+	successes int
+	openedAt  int64
+}
+
+type channelModelKey struct {
+	channelId int
+	model     string
+}
+
+// the breakers are kept in memory of each node, like the channel scores
+var breakerLock sync.Mutex
+var channelBreakers = make(map[int]*circuitBreaker)
+var channelModelBreakers = make(map[channelModelKey]*circuitBreaker)
+
+func (b *circuitBreaker) allow(now int64) bool {
+	switch b.state {
+	case BreakerStateOpen:
+		if now-b.openedAt < int64(common.CircuitBreakerCooldown) {
+			return false
+		}
+		b.state = BreakerStateHalfOpen
+		b.successes = 0
+		fallthrough
+	case BreakerStateHalfOpen:
+		return rand.Float64() < common.CircuitBreakerHalfOpenRate
+	}
+	return true
+}
+
+func (b *circuitBreaker) open(now int64) {
+	b.state = BreakerStateOpen
+	b.openedAt = now
+	b.failures = 0
+	b.successes = 0
+}
+
+// record returns true if the outcome opened the breaker
+func (b *circuitBreaker) record(success bool, now int64) bool {
+	if success {
+		b.failures = 0
+		if b.state == BreakerStateClosed {
+			return false
+		}
+		// a success while open comes from a request that was already on its way
+		b.state = BreakerStateHalfOpen
+		b.successes++
+		if b.successes >= breakerHalfOpenSuccesses {
+			b.state = BreakerStateClosed
+			b.successes = 0
+		}
+		return false
+	}
+	switch b.state {
+	case BreakerStateHalfOpen:
+		b.open(now)
+		return true
+	case BreakerStateOpen:
+		return false
+	}
+	b.failures++
+	if b.failures >= common.CircuitBreakerThreshold {
+		b.open(now)
+		return true
+	}
+	return false
+}
+
+func getBreaker(channelId int, model string) (*circuitBreaker, *circuitBreaker) {
+	channelBreaker, ok := channelBreakers[channelId]
+	if !ok {
+		channelBreaker = &circuitBreaker{state: BreakerStateClosed}
+		channelBreakers[channelId] = channelBreaker
+	}
+	key := channelModelKey{channelId: channelId, model: model}
+	modelBreaker, ok := channelModelBreakers[key]
+	if !ok {
+		modelBreaker = &circuitBreaker{state: BreakerStateClosed}
+		channelModelBreakers[key] = modelBreaker
+	}
+	return channelBreaker, modelBreaker
+}
+
+// recordBreakerOutcome feeds a relay outcome to the breakers of the channel and of the channel and model
+func recordBreakerOutcome(channelId int, model string, success bool) {
+	if !common.CircuitBreakerEnabled {
+		return
+	}
+	now := time.Now().Unix()
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+	channelBreaker, modelBreaker := getBreaker(channelId, model)
+	if channelBreaker.record(success, now) {
+		common.SysLog(fmt.Sprintf("circuit breaker of channel #%d opened", channelId))
+	}
+	if modelBreaker.record(success, now) {
+		common.SysLog(fmt.Sprintf("circuit breaker of channel #%d for model %s opened", channelId, model))
+	}
+}
+
+// TripChannelBreaker opens the breaker of a channel right away, it is used instead of
+// disabling the channel when the circuit breaker is enabled
+func TripChannelBreaker(channelId int) {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+	channelBreaker, ok := channelBreakers[channelId]
+	if !ok {
+		channelBreaker = &circuitBreaker{}
+		channelBreakers[channelId] = channelBreaker
+	}
+	channelBreaker.open(time.Now().Unix())
+	common.SysLog(fmt.Sprintf("circuit breaker of channel #%d opened", channelId))
+}
+
+// ResetChannelBreakers closes all breakers of a channel, e.g. after it was edited
+func ResetChannelBreakers(channelId int) {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+	delete(channelBreakers, channelId)
+	for key := range channelModelBreakers {
+		if key.channelId == channelId {
+			delete(channelModelBreakers, key)
+		}
+	}
+}
+
+// getBreakerStates returns the state of the channel breaker and of the channel and model breaker
+func getBreakerStates(channelId int, model string) (string, string) {
+	breakerLock.Lock()
+	defer breakerLock.Unlock()
+	channelState, modelState := BreakerStateClosed, BreakerStateClosed
+	if channelBreaker, ok := channelBreakers[channelId]; ok {
+		channelState = channelBreaker.state
+	}
+	if modelBreaker, ok := channelModelBreakers[channelModelKey{channelId: channelId, model: model}]; ok {
+		modelState = modelBreaker.state
+	}
+	return channelState, modelState
+}
+
+// filterChannelIdsByBreaker returns whether each channel may be selected for the model, if
+// every channel is held back by its breaker they are all allowed rather than failing the request
+func filterChannelIdsByBreaker(model string, channelIds []int) []bool {
+	allowed := make([]bool, len(channelIds))
+	if !common.CircuitBreakerEnabled {
+		for i := range allowed {
+			allowed[i] = true
+		}
+		return allowed
+	}
+	now := time.Now().Unix()
+	anyAllowed := false
+	breakerLock.Lock()
+	for i, channelId := range channelIds {
+		allowed[i] = true
+		if channelBreaker, ok := channelBreakers[channelId]; ok && !channelBreaker.allow(now) {
+			allowed[i] = false
+		}
+		if modelBreaker, ok := channelModelBreakers[channelModelKey{channelId: channelId, model: model}]; ok && allowed[i] && !modelBreaker.allow(now) {
+			allowed[i] = false
+		}
+		anyAllowed = anyAllowed || allowed[i]
+	}
+	breakerLock.Unlock()
+	if !anyAllowed {
+		for i := range allowed {
+			allowed[i] = true
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+// setBreakerOptions sets the circuit breaker options and closes all breakers
+func setBreakerOptions(t *testing.T, threshold int, cooldown int, halfOpenRate float64) {
+	t.Helper()
+	previousEnabled, previousThreshold := common.CircuitBreakerEnabled, common.CircuitBreakerThreshold
+	previousCooldown, previousRate := common.CircuitBreakerCooldown, common.CircuitBreakerHalfOpenRate
+	common.CircuitBreakerEnabled, common.CircuitBreakerThreshold = true, threshold
+	common.CircuitBreakerCooldown, common.CircuitBreakerHalfOpenRate = cooldown, halfOpenRate
+	channelBreakers = make(map[int]*circuitBreaker)
+	channelModelBreakers = make(map[channelModelKey]*circuitBreaker)
+	t.Cleanup(func() {
+		common.CircuitBreakerEnabled, common.CircuitBreakerThreshold = previousEnabled, previousThreshold
+		common.CircuitBreakerCooldown, common.CircuitBreakerHalfOpenRate = previousCooldown, previousRate
+		channelBreakers = make(map[int]*circuitBreaker)
+		channelModelBreakers = make(map[channelModelKey]*circuitBreaker)
+	})
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	setBreakerOptions(t, 3, 60, 1)
+	b := &circuitBreaker{state: BreakerStateClosed}
+	now := int64(1000)
+	steps := []struct {
+		name  string
+		step  func() bool
+		want  bool
+		state string
+	}{
+		{"failure", func() bool { return b.record(false, now) }, false, BreakerStateClosed},
+		{"success resets the failures", func() bool { return b.record(true, now) }, false, BreakerStateClosed},
+		{"failure", func() bool { return b.record(false, now) }, false, BreakerStateClosed},
+		{"failure", func() bool { return b.record(false, now) }, false, BreakerStateClosed},
+		{"third consecutive failure opens", func() bool { return b.record(false, now) }, true, BreakerStateOpen},
+		{"held back during the cooldown", func() bool { return b.allow(now + 59) }, false, BreakerStateOpen},
+		{"failure while open", func() bool { return b.record(false, now+59) }, false, BreakerStateOpen},
+		{"probe after the cooldown", func() bool { return b.allow(now + 60) }, true, BreakerStateHalfOpen},
+		{"failed probe opens again", func() bool { return b.record(false, now+60) }, true, BreakerStateOpen},
+		{"held back during the new cooldown", func() bool { return b.allow(now + 119) }, false, BreakerStateOpen},
+		{"probe after the new cooldown", func() bool { return b.allow(now + 120) }, true, BreakerStateHalfOpen},
+		{"successful probe", func() bool { return b.record(true, now+120) }, false, BreakerStateHalfOpen},
+		{"successful probe", func() bool { return b.record(true, now+120) }, false, BreakerStateHalfOpen},
+		{"third successful probe closes", func() bool { return b.record(true, now+120) }, false, BreakerStateClosed},
+		{"closed breaker lets everything through", func() bool { return b.allow(now + 120) }, true, BreakerStateClosed},
+	}
+	for i, step := range steps {
+		if got := step.step(); got != step.want || b.state != step.state {
+			t.Fatalf("step %d %s = %v with state %s, want %v with state %s", i, step.name, got, b.state, step.want, step.state)
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpenRate(t *testing.T) {
+	setBreakerOptions(t, 1, 60, 0)
+	b := &circuitBreaker{state: BreakerStateClosed}
+	b.record(false, 1000)
+	// no probe is let through, but the breaker still becomes half-open after the cooldown
+	if b.allow(1060) || b.state != BreakerStateHalfOpen {
+		t.Errorf("allow with a half-open rate of 0 = true or state %s", b.state)
+	}
+	// a success of a request that was on its way counts as a probe
+	b.open(1000)
+	b.record(true, 1001)
+	if b.state != BreakerStateHalfOpen || b.successes != 1 {
+		t.Errorf("state after a late success = %s with %d successes, want half-open with 1", b.state, b.successes)
+	}
+}
+
+func TestFilterChannelIdsByBreaker(t *testing.T) {
+	setBreakerOptions(t, 1, 60, 0)
+	recordBreakerOutcome(1, "gpt-4o", false)
+	recordBreakerOutcome(3, "gpt-4o", true)
+	// only the breaker of channel #3 for gpt-4o is open
+	channelModelBreakers[channelModelKey{channelId: 3, model: "gpt-4o"}].open(time.Now().Unix())
+
+	tests := []struct {
+		name       string
+		model      string
+		channelIds []int
+		want       []bool
+	}{
+		{"open channel breaker", "gpt-4o-mini", []int{1, 2}, []bool{false, true}},
+		{"open model breaker", "gpt-4o", []int{2, 3}, []bool{true, false}},
+		{"all held back", "gpt-4o", []int{1, 3}, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterChannelIdsByBreaker(tt.model, tt.channelIds)
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("filterChannelIdsByBreaker(%s, %v) = %v, want %v", tt.model, tt.channelIds, got, tt.want)
+				}
+			}
+		})
+	}
+	if channelState, modelState := getBreakerStates(1, "gpt-4o"); channelState != BreakerStateOpen || modelState != BreakerStateOpen {
+		t.Errorf("states of channel #1 = %s, %s, want open", channelState, modelState)
+	}
+	ResetChannelBreakers(1)
+	if channelState, modelState := getBreakerStates(1, "gpt-4o"); channelState != BreakerStateClosed || modelState != BreakerStateClosed {
+		t.Errorf("states of channel #1 after the reset = %s, %s, want closed", channelState, modelState)
+	}
+}
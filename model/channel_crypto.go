@@ -0,0 +1,63 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+)
+
+// checkChannelKeys makes sure every encrypted channel key can be decrypted with the configured master keys
+func checkChannelKeys() error {
+	var channels []*Channel
+	err := DB.Select("id", "key").Find(&channels).Error
+	if err != nil {
+		return err
+	}
+	plainCount := 0
+	for _, channel := range channels {
+		if !common.IsSealed(channel.Key) {
+			plainCount++
+			continue
+		}
+		_, err = common.OpenSecret(channel.Key)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt the key of channel #%d, check CHANNEL_KEY_SECRET and CHANNEL_KEY_OLD_SECRET: %w", channel.Id, err)
+		}
+	}
+	if plainCount > 0 && common.MasterKeyEnabled() {
+		common.SysLog(fmt.Sprintf("the keys of %d channels are stored in plain text, re-encrypt the channel keys to encrypt them", plainCount))
+	}
+	return nil
+}
+
+// ReencryptChannelKeys encrypts every channel key that is in plain text and wraps the data keys of the
+// ones under the old master key with the current one, it returns how many keys were changed
+func ReencryptChannelKeys() (int, error) {
+	if !common.MasterKeyEnabled() {
+		return 0, errors.New("未配置渠道密钥的主密钥 CHANNEL_KEY_SECRET")
+	}
+	var channels []*Channel
+	err := DB.Select("id", "key").Find(&channels).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, channel := range channels {
+		if !common.NeedsResealing(channel.Key) {
+			continue
+		}
+		key, err := common.ResealSecret(channel.Key)
+		if err != nil {
+			return count, fmt.Errorf("渠道 #%d 的密钥解密失败：%s", channel.Id, err.Error())
+		}
+		err = DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("key", key).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	if count > 0 && common.MemoryCacheEnabled {
+		InitChannelCache()
+	}
+	return count, nil
+}
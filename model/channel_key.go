@@ -0,0 +1,278 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"one-api/common"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ChannelKey holds the health and usage of one key of a multi-key channel, the key
+// itself stays in the channel and the row is matched with it by the hash of the key
+type ChannelKey struct {
+	Id             int    `json:"id"`
+	ChannelId      int    `json:"channel_id" gorm:"uniqueIndex:idx_channel_key_hash"`
+	KeyHash        string `json:"-" gorm:"type:varchar(64);uniqueIndex:idx_channel_key_hash"`
+	Key            string `json:"key" gorm:"-"` // masked, only filled for the key status api
+	Status         int    `json:"status" gorm:"default:1"`
+	DisabledReason string `json:"disabled_reason"`
+	DisabledTime   int64  `json:"disabled_time" gorm:"bigint"`
+	RequestCount   int    `json:"request_count" gorm:"default:0"`
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;default:0"`
+}
+
+func hashChannelKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func maskChannelKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", 8) + key[len(key)-4:]
+}
+
+// the key rows are cached per channel when the memory cache is enabled, they are
+// dropped whenever a key changes and on every channel cache sync
+var channelKeyLock sync.RWMutex
+var channelKeyCache = make(map[int]map[string]*ChannelKey)
+var channelKeyCursorLock sync.Mutex
+var channelKeyCursors = make(map[int]int)
+
+func invalidateChannelKeyCache(channelId int) {
+	channelKeyLock.Lock()
+	defer channelKeyLock.Unlock()
+	if channelId == 0 {
+		channelKeyCache = make(map[int]map[string]*ChannelKey)
+		return
+	}
+	delete(channelKeyCache, channelId)
+}
+
+// syncChannelKeys creates the missing rows of the keys of a multi-key channel and
+// removes the rows of keys that are no longer part of it
+func syncChannelKeys(channel *Channel) (map[string]*ChannelKey, error) {
+	var rows []*ChannelKey
+	err := DB.Where("channel_id = ?", channel.Id).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	hash2key := make(map[string]*ChannelKey, len(rows))
+	for _, row := range rows {
+		hash2key[row.KeyHash] = row
+	}
+	if !channel.IsMultiKey() {
+		if len(rows) > 0 {
+			err = DB.Where("channel_id = ?", channel.Id).Delete(&ChannelKey{}).Error
+		}
+		return nil, err
+	}
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*ChannelKey)
+	for _, key := range keys {
+		hash := hashChannelKey(key)
+		row, ok := hash2key[hash]
+		if !ok {
+			row = &ChannelKey{ChannelId: channel.Id, KeyHash: hash, Status: common.ChannelStatusEnabled}
+			err = DB.Create(row).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+		result[hash] = row
+	}
+	staleIds := make([]int, 0)
+	for hash, row := range hash2key {
+		if _, ok := result[hash]; !ok {
+			staleIds = append(staleIds, row.Id)
+		}
+	}
+	if len(staleIds) > 0 {
+		err = DB.Where("id in (?)", staleIds).Delete(&ChannelKey{}).Error
+	}
+	return result, err
+}
+
+func getChannelKeys(channel *Channel) (map[string]*ChannelKey, error) {
+	if !common.MemoryCacheEnabled {
+		return syncChannelKeys(channel)
+	}
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	channelKeyLock.RLock()
+	hash2key, ok := channelKeyCache[channel.Id]
+	channelKeyLock.RUnlock()
+	if ok && len(hash2key) == len(keys) {
+		return hash2key, nil
+	}
+	hash2key, err = syncChannelKeys(channel)
+	if err != nil {
+		return nil, err
+	}
+	channelKeyLock.Lock()
+	channelKeyCache[channel.Id] = hash2key
+	channelKeyLock.Unlock()
+	return hash2key, nil
+}
+
+// SelectChannelKey picks the key a request is sent with and returns it with the id of its
+// row, the id is 0 for single key channels. Disabled keys are skipped unless all of them are.
+func SelectChannelKey(channel *Channel) (string, int, error) {
+	if !channel.IsMultiKey() {
+		key, err := channel.GetPlainKey()
+		return key, 0, err
+	}
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return "", 0, err
+	}
+	hash2key, err := getChannelKeys(channel)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to get keys of channel #%d: %s", channel.Id, err.Error()))
+	}
+	candidates := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if row, ok := hash2key[hashChannelKey(key)]; ok && row.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+	if len(candidates) == 0 {
+		candidates = keys
+	}
+	var key string
+	if channel.GetKeyMode() == common.ChannelKeyModeRoundRobin {
+		channelKeyCursorLock.Lock()
+		cursor := channelKeyCursors[channel.Id]
+		channelKeyCursors[channel.Id] = cursor + 1
+		channelKeyCursorLock.Unlock()
+		key = candidates[cursor%len(candidates)]
+	} else {
+		key = candidates[rand.Intn(len(candidates))]
+	}
+	if row, ok := hash2key[hashChannelKey(key)]; ok {
+		return key, row.Id, nil
+	}
+	return key, 0, nil
+}
+
+// SelectPreferredChannelKey returns the key with the given id if it is still an enabled key of
+// the channel, e.g. the key a sticky session was served by, otherwise it selects one as usual
+func SelectPreferredChannelKey(channel *Channel, keyId int) (string, int, error) {
+	if keyId != 0 && channel.IsMultiKey() {
+		keys, err := channel.GetKeys()
+		if err != nil {
+			return "", 0, err
+		}
+		hash2key, err := getChannelKeys(channel)
+		if err == nil {
+			for _, key := range keys {
+				if row, ok := hash2key[hashChannelKey(key)]; ok && row.Id == keyId && row.Status == common.ChannelStatusEnabled {
+					return key, keyId, nil
+				}
+			}
+		}
+	}
+	return SelectChannelKey(channel)
+}
+
+// GetChannelKeyStatuses returns the rows of the keys of a channel in the order of the keys, with the keys masked
+func GetChannelKeyStatuses(channel *Channel) ([]*ChannelKey, error) {
+	if !channel.IsMultiKey() {
+		return nil, errors.New("该渠道不是多密钥渠道")
+	}
+	keys, err := channel.GetKeys()
+	if err != nil {
+		return nil, err
+	}
+	hash2key, err := syncChannelKeys(channel)
+	if err != nil {
+		return nil, err
+	}
+	invalidateChannelKeyCache(channel.Id)
+	result := make([]*ChannelKey, 0, len(hash2key))
+	for _, key := range keys {
+		row := *hash2key[hashChannelKey(key)]
+		row.Key = maskChannelKey(key)
+		result = append(result, &row)
+	}
+	return result, nil
+}
+
+func GetChannelKeyById(id int) (*ChannelKey, error) {
+	key := ChannelKey{}
+	err := DB.First(&key, "id = ?", id).Error
+	return &key, err
+}
+
+func UpdateChannelKeyStatusById(id int, status int, reason string) error {
+	key, err := GetChannelKeyById(id)
+	if err != nil {
+		return err
+	}
+	disabledTime := int64(0)
+	if status != common.ChannelStatusEnabled {
+		disabledTime = common.GetTimestamp()
+	} else {
+		reason = ""
+	}
+	err = DB.Model(key).Select("status", "disabled_reason", "disabled_time").Updates(ChannelKey{
+		Status:         status,
+		DisabledReason: reason,
+		DisabledTime:   disabledTime,
+	}).Error
+	invalidateChannelKeyCache(key.ChannelId)
+	return err
+}
+
+func CountEnabledChannelKeys(channelId int) (int64, error) {
+	var count int64
+	err := DB.Model(&ChannelKey{}).Where("channel_id = ? and status = ?", channelId, common.ChannelStatusEnabled).Count(&count).Error
+	return count, err
+}
+
+func deleteChannelKeys(channelIds []int) error {
+	for _, id := range channelIds {
+		invalidateChannelKeyCache(id)
+	}
+	return DB.Where("channel_id in (?)", channelIds).Delete(&ChannelKey{}).Error
+}
+
+func UpdateChannelKeyUsage(id int, quota int) {
+	if id == 0 {
+		return
+	}
+	if common.BatchUpdateEnabled {
+		addNewRecord(BatchUpdateTypeChannelKeyUsedQuota, id, quota)
+		addNewRecord(BatchUpdateTypeChannelKeyRequestCount, id, 1)
+		return
+	}
+	updateChannelKeyUsedQuota(id, quota)
+	updateChannelKeyRequestCount(id, 1)
+}
+
+func updateChannelKeyUsedQuota(id int, quota int) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+	if err != nil {
+		common.SysError("failed to update channel key used quota: " + err.Error())
+	}
+}
+
+func updateChannelKeyRequestCount(id int, count int) {
+	err := DB.Model(&ChannelKey{}).Where("id = ?", id).Update("request_count", gorm.Expr("request_count + ?", count)).Error
+	if err != nil {
+		common.SysError("failed to update channel key request count: " + err.Error())
+	}
+}
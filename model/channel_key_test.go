@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strings"
+	"testing"
+)
+
+func TestSelectChannelKeyUndecryptable(t *testing.T) {
+	// sealed with a master key that is not configured
+	key := "enc:v2:00000000:AAAA:AAAA"
+	random := "random"
+	tests := []struct {
+		name    string
+		channel *Channel
+	}{
+		{"single key", &Channel{Id: 1, Key: key}},
+		{"multi key", &Channel{Id: 2, Key: key, KeyMode: &random}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, err := SelectChannelKey(tt.channel); err == nil || got != "" {
+				t.Errorf("SelectChannelKey = %q, %v, want an error", got, err)
+			}
+			if got, _, err := SelectPreferredChannelKey(tt.channel, 1); err == nil || got != "" {
+				t.Errorf("SelectPreferredChannelKey = %q, %v, want an error", got, err)
+			}
+		})
+	}
+}
+
+// selectKeys selects n keys of the channel
+func selectKeys(t *testing.T, channel *Channel, n int) []string {
+	t.Helper()
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		key, _, err := SelectChannelKey(channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestSelectChannelKeyRotation(t *testing.T) {
+	for _, memoryCache := range []bool{false, true} {
+		t.Run(fmt.Sprintf("memory cache %v", memoryCache), func(t *testing.T) {
+			setupTestDB(t, &ChannelKey{})
+			previous := common.MemoryCacheEnabled
+			common.MemoryCacheEnabled = memoryCache
+			t.Cleanup(func() {
+				common.MemoryCacheEnabled = previous
+				invalidateChannelKeyCache(0)
+			})
+			roundRobin := common.ChannelKeyModeRoundRobin
+			channel := &Channel{Id: 3, Key: "sk-a\nsk-b\n\nsk-c\n", KeyMode: &roundRobin}
+			channelKeyCursors[channel.Id] = 0
+
+			if got := strings.Join(selectKeys(t, channel, 4), ","); got != "sk-a,sk-b,sk-c,sk-a" {
+				t.Errorf("keys = %s, want sk-a,sk-b,sk-c,sk-a", got)
+			}
+			statuses, err := GetChannelKeyStatuses(channel)
+			if err != nil || len(statuses) != 3 {
+				t.Fatalf("GetChannelKeyStatuses = %v, %v", statuses, err)
+			}
+			keyIds := make(map[string]int)
+			for i, key := range []string{"sk-a", "sk-b", "sk-c"} {
+				keyIds[key] = statuses[i].Id
+				if statuses[i].Key != maskChannelKey(key) || statuses[i].Status != common.ChannelStatusEnabled {
+					t.Errorf("status of %s = %+v", key, statuses[i])
+				}
+			}
+
+			// a disabled key is skipped until it is enabled again
+			if err := UpdateChannelKeyStatusById(keyIds["sk-b"], common.ChannelStatusAutoDisabled, "invalid key"); err != nil {
+				t.Fatal(err)
+			}
+			channelKeyCursors[channel.Id] = 0
+			if got := strings.Join(selectKeys(t, channel, 4), ","); got != "sk-a,sk-c,sk-a,sk-c" {
+				t.Errorf("keys with sk-b disabled = %s, want sk-a,sk-c,sk-a,sk-c", got)
+			}
+			if key, keyId, _ := SelectPreferredChannelKey(channel, keyIds["sk-b"]); key == "sk-b" || keyId == keyIds["sk-b"] {
+				t.Errorf("the disabled preferred key was selected")
+			}
+			if key, keyId, _ := SelectPreferredChannelKey(channel, keyIds["sk-c"]); key != "sk-c" || keyId != keyIds["sk-c"] {
+				t.Errorf("SelectPreferredChannelKey = %s, %d, want sk-c, %d", key, keyId, keyIds["sk-c"])
+			}
+			if count, err := CountEnabledChannelKeys(channel.Id); count != 2 || err != nil {
+				t.Errorf("CountEnabledChannelKeys = %d, %v, want 2", count, err)
+			}
+
+			// with every key disabled the request is still served rather than failed
+			_ = UpdateChannelKeyStatusById(keyIds["sk-a"], common.ChannelStatusManuallyDisabled, "")
+			_ = UpdateChannelKeyStatusById(keyIds["sk-c"], common.ChannelStatusAutoDisabled, "quota exceeded")
+			channelKeyCursors[channel.Id] = 0
+			if got := strings.Join(selectKeys(t, channel, 3), ","); got != "sk-a,sk-b,sk-c" {
+				t.Errorf("keys with all disabled = %s, want sk-a,sk-b,sk-c", got)
+			}
+			if count, _ := CountEnabledChannelKeys(channel.Id); count != 0 {
+				t.Errorf("CountEnabledChannelKeys = %d, want 0", count)
+			}
+			_ = UpdateChannelKeyStatusById(keyIds["sk-a"], common.ChannelStatusEnabled, "")
+			key, err := GetChannelKeyById(keyIds["sk-a"])
+			if err != nil || key.DisabledReason != "" || key.DisabledTime != 0 {
+				t.Errorf("enabled key = %+v, %v", key, err)
+			}
+			if got, keyId, _ := SelectChannelKey(channel); got != "sk-a" || keyId != keyIds["sk-a"] {
+				t.Errorf("SelectChannelKey = %s, %d, want sk-a, %d", got, keyId, keyIds["sk-a"])
+			}
+		})
+	}
+}
+
+func TestSyncChannelKeys(t *testing.T) {
+	setupTestDB(t, &ChannelKey{})
+	random := common.ChannelKeyModeRandom
+	channel := &Channel{Id: 4, Key: "sk-a\nsk-b", KeyMode: &random}
+	rows, err := syncChannelKeys(channel)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("syncChannelKeys = %v, %v", rows, err)
+	}
+	idOfA := rows[hashChannelKey("sk-a")].Id
+
+	// the row of a kept key stays along with its status, the row of a removed key goes
+	channel.Key = "sk-a\nsk-c"
+	rows, err = syncChannelKeys(channel)
+	if err != nil || len(rows) != 2 || rows[hashChannelKey("sk-a")].Id != idOfA || rows[hashChannelKey("sk-c")] == nil {
+		t.Fatalf("syncChannelKeys after the edit = %v, %v", rows, err)
+	}
+	var count int64
+	DB.Model(&ChannelKey{}).Where("channel_id = ?", channel.Id).Count(&count)
+	if count != 2 {
+		t.Errorf("%d key rows, want 2", count)
+	}
+
+	// a channel that is no longer a multi-key channel drops all of them
+	channel.KeyMode = nil
+	if _, err := syncChannelKeys(channel); err != nil {
+		t.Fatal(err)
+	}
+	DB.Model(&ChannelKey{}).Where("channel_id = ?", channel.Id).Count(&count)
+	if count != 0 {
+		t.Errorf("%d key rows of a single key channel, want 0", count)
+	}
+}
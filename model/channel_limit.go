@@ -0,0 +1,326 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrChannelsSaturated is returned by the selector when every candidate channel is at its limits
+var ErrChannelsSaturated = errors.New("当前分组上游负载已饱和，请稍后再试")
+
+// channelInFlightExpiration bounds how long the in-flight counter of a channel survives a node that died mid-request
+const channelInFlightExpiration = 10 * time.Minute
+
+// channelLimit holds the limits of a channel, 0 means unlimited
+type channelLimit struct {
+	rpm         int
+	tpm         int
+	concurrency int
+}
+
+func (l channelLimit) enabled() bool {
+	return l.rpm > 0 || l.tpm > 0 || l.concurrency > 0
+}
+
+func (channel *Channel) getLimit() channelLimit {
+	limit := channelLimit{}
+	if channel.RPMLimit != nil {
+		limit.rpm = *channel.RPMLimit
+	}
+	if channel.TPMLimit != nil {
+		limit.tpm = *channel.TPMLimit
+	}
+	if channel.MaxConcurrency != nil {
+		limit.concurrency = *channel.MaxConcurrency
+	}
+	return limit
+}
+
+func (channel *Channel) HasLimits() bool {
+	return channel.getLimit().enabled()
+}
+
+// channelUsage counts the requests and tokens of the current minute and the requests in flight
+type channelUsage struct {
+	minute         int64
+	requests       int
+	tokens         int
+	inFlight       int
+	saturatedUntil int64
+}
+
+// the usage is kept in memory of each node unless redis is enabled
+var channelUsageLock sync.Mutex
+var channelUsages = make(map[int]*channelUsage)
+
+func currentMinute() int64 {
+	return time.Now().Unix() / 60
+}
+
+func getChannelUsage(channelId int) *channelUsage {
+	usage, ok := channelUsages[channelId]
+	if !ok {
+		usage = &channelUsage{}
+		channelUsages[channelId] = usage
+	}
+	if minute := currentMinute(); usage.minute != minute {
+		usage.minute = minute
+		usage.requests = 0
+		usage.tokens = 0
+	}
+	return usage
+}
+
+func channelLimitRedisKey(kind string, channelId int) string {
+	switch kind {
+	case "rpm", "tpm":
+		return fmt.Sprintf("channelLimit:%s:%d:%d", kind, channelId, currentMinute())
+	}
+	return fmt.Sprintf("channelLimit:%s:%d", kind, channelId)
+}
+
+// reserveChannelScript takes a request and an in-flight slot of a channel in one step, every counter it
+// raised is lowered again when a limit is exceeded, so concurrent requests cannot pass the limits together
+var reserveChannelScript = `
+if redis.call('EXISTS', KEYS[4]) == 1 then
+	return 0
+end
+local rpm = tonumber(ARGV[1])
+local tpm = tonumber(ARGV[2])
+local concurrency = tonumber(ARGV[3])
+if tpm > 0 and tonumber(redis.call('GET', KEYS[2]) or '0') >= tpm then
+	return 0
+end
+local requests = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+if rpm > 0 and requests > rpm then
+	redis.call('DECR', KEYS[1])
+	return 0
+end
+local inFlight = redis.call('INCR', KEYS[3])
+redis.call('PEXPIRE', KEYS[3], ARGV[5])
+if concurrency > 0 and inFlight > concurrency then
+	redis.call('DECR', KEYS[3])
+	redis.call('DECR', KEYS[1])
+	return 0
+end
+return 1
+`
+
+// ReserveChannel takes a request slot of the channel unless that exceeds one of its limits, the check and
+// the count happen atomically. It is true for channels without limits, which need no slot, and ReleaseChannel
+// has to be called once the request is done if the channel HasLimits
+func ReserveChannel(channel *Channel) bool {
+	limit := channel.getLimit()
+	if !limit.enabled() {
+		return true
+	}
+	if common.RedisEnabled {
+		keys := []string{channelLimitRedisKey("rpm", channel.Id), channelLimitRedisKey("tpm", channel.Id),
+			channelLimitRedisKey("inflight", channel.Id), channelLimitRedisKey("saturated", channel.Id)}
+		result, err := common.RDB.Eval(context.Background(), reserveChannelScript, keys, limit.rpm, limit.tpm,
+			limit.concurrency, (2 * time.Minute).Milliseconds(), channelInFlightExpiration.Milliseconds()).Int()
+		if err != nil {
+			// the limits are best effort, an unavailable redis does not hold the channel back
+			common.SysError("failed to reserve channel: " + err.Error())
+			return true
+		}
+		return result == 1
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	usage := getChannelUsage(channel.Id)
+	if usage.saturatedUntil > time.Now().Unix() ||
+		(limit.rpm > 0 && usage.requests >= limit.rpm) ||
+		(limit.tpm > 0 && usage.tokens >= limit.tpm) ||
+		(limit.concurrency > 0 && usage.inFlight >= limit.concurrency) {
+		return false
+	}
+	usage.requests++
+	usage.inFlight++
+	return true
+}
+
+// AcquireChannel counts a request against the limits of the channel without checking them, for channels
+// chosen by the request itself, it returns whether ReleaseChannel has to be called once the request is done
+func AcquireChannel(channel *Channel) bool {
+	if !channel.HasLimits() {
+		return false
+	}
+	if common.RedisEnabled {
+		ctx := context.Background()
+		pipe := common.RDB.Pipeline()
+		rpmKey := channelLimitRedisKey("rpm", channel.Id)
+		pipe.Incr(ctx, rpmKey)
+		pipe.Expire(ctx, rpmKey, 2*time.Minute)
+		inFlightKey := channelLimitRedisKey("inflight", channel.Id)
+		pipe.Incr(ctx, inFlightKey)
+		pipe.Expire(ctx, inFlightKey, channelInFlightExpiration)
+		_, err := pipe.Exec(ctx)
+		if err != nil {
+			common.SysError("failed to acquire channel limit: " + err.Error())
+		}
+		return true
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	usage := getChannelUsage(channel.Id)
+	usage.requests++
+	usage.inFlight++
+	return true
+}
+
+func ReleaseChannel(channelId int) {
+	if common.RedisEnabled {
+		err := common.RDB.Decr(context.Background(), channelLimitRedisKey("inflight", channelId)).Err()
+		if err != nil {
+			common.SysError("failed to release channel limit: " + err.Error())
+		}
+		return
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	usage := getChannelUsage(channelId)
+	if usage.inFlight > 0 {
+		usage.inFlight--
+	}
+}
+
+// RecordChannelTokens counts the tokens of a finished request against the tpm limit of the channel
+func RecordChannelTokens(channelId int, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	if common.RedisEnabled {
+		ctx := context.Background()
+		key := channelLimitRedisKey("tpm", channelId)
+		pipe := common.RDB.Pipeline()
+		pipe.IncrBy(ctx, key, int64(tokens))
+		pipe.Expire(ctx, key, 2*time.Minute)
+		_, err := pipe.Exec(ctx)
+		if err != nil {
+			common.SysError("failed to record channel tokens: " + err.Error())
+		}
+		return
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	getChannelUsage(channelId).tokens += tokens
+}
+
+// MarkChannelSaturated holds a channel back for the rest of the minute after the upstream answered 429
+func MarkChannelSaturated(channelId int) {
+	remaining := 60 - time.Now().Unix()%60
+	if common.RedisEnabled {
+		err := common.RedisSet(channelLimitRedisKey("saturated", channelId), "1", time.Duration(remaining)*time.Second)
+		if err != nil {
+			common.SysError("failed to mark channel saturated: " + err.Error())
+		}
+		return
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	getChannelUsage(channelId).saturatedUntil = time.Now().Unix() + remaining
+}
+
+// getChannelUsages returns the current usage of the channels, looked up in one round trip when redis is enabled
+func getChannelUsages(channelIds []int) []channelUsage {
+	usages := make([]channelUsage, len(channelIds))
+	if common.RedisEnabled {
+		keys := make([]string, 0, len(channelIds)*4)
+		for _, channelId := range channelIds {
+			keys = append(keys, channelLimitRedisKey("rpm", channelId), channelLimitRedisKey("tpm", channelId),
+				channelLimitRedisKey("inflight", channelId), channelLimitRedisKey("saturated", channelId))
+		}
+		values, err := common.RDB.MGet(context.Background(), keys...).Result()
+		if err != nil {
+			common.SysError("failed to get channel usages: " + err.Error())
+			return usages
+		}
+		value := func(i int) int {
+			if s, ok := values[i].(string); ok {
+				n, _ := strconv.Atoi(s)
+				return n
+			}
+			return 0
+		}
+		now := time.Now().Unix()
+		for i := range channelIds {
+			usages[i].requests = value(i * 4)
+			usages[i].tokens = value(i*4 + 1)
+			usages[i].inFlight = value(i*4 + 2)
+			if value(i*4+3) != 0 {
+				usages[i].saturatedUntil = now + 1
+			}
+		}
+		return usages
+	}
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	for i, channelId := range channelIds {
+		usages[i] = *getChannelUsage(channelId)
+	}
+	return usages
+}
+
+// filterChannelIdsByLimit returns whether each channel is below its limits
+func filterChannelIdsByLimit(channelIds []int, limits []channelLimit) []bool {
+	allowed := make([]bool, len(channelIds))
+	limitedIds := make([]int, 0)
+	for i, channelId := range channelIds {
+		allowed[i] = true
+		if limits[i].enabled() {
+			limitedIds = append(limitedIds, channelId)
+		}
+	}
+	if len(limitedIds) == 0 {
+		return allowed
+	}
+	usages := getChannelUsages(limitedIds)
+	now := time.Now().Unix()
+	j := 0
+	for i := range channelIds {
+		limit := limits[i]
+		if !limit.enabled() {
+			continue
+		}
+		usage := usages[j]
+		j++
+		if usage.saturatedUntil > now ||
+			(limit.rpm > 0 && usage.requests >= limit.rpm) ||
+			(limit.tpm > 0 && usage.tokens >= limit.tpm) ||
+			(limit.concurrency > 0 && usage.inFlight >= limit.concurrency) {
+			allowed[i] = false
+		}
+	}
+	return allowed
+}
+
+// filterSelectableChannels skips the channels at their limits and then consults the circuit breakers,
+// it fails with ErrChannelsSaturated if no channel is below its limits
+func filterSelectableChannels(model string, channelIds []int, limits []channelLimit) ([]bool, error) {
+	allowed := filterChannelIdsByLimit(channelIds, limits)
+	unsaturatedIds := make([]int, 0, len(channelIds))
+	for i, channelId := range channelIds {
+		if allowed[i] {
+			unsaturatedIds = append(unsaturatedIds, channelId)
+		}
+	}
+	if len(unsaturatedIds) == 0 {
+		return allowed, ErrChannelsSaturated
+	}
+	breakerAllowed := filterChannelIdsByBreaker(model, unsaturatedIds)
+	j := 0
+	for i := range channelIds {
+		if allowed[i] {
+			allowed[i] = breakerAllowed[j]
+			j++
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func resetChannelUsages() {
+	channelUsageLock.Lock()
+	defer channelUsageLock.Unlock()
+	channelUsages = make(map[int]*channelUsage)
+}
+
+func TestReserveChannelConcurrent(t *testing.T) {
+	tests := []struct {
+		name        string
+		rpm         int
+		concurrency int
+		want        int32
+	}{
+		{"concurrency", 0, 3, 3},
+		{"rpm", 5, 0, 5},
+		{"both", 4, 2, 2},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetChannelUsages()
+			rpm, concurrency := tt.rpm, tt.concurrency
+			channel := &Channel{Id: 1000 + i, RPMLimit: &rpm, MaxConcurrency: &concurrency}
+			var reserved int32
+			var wg sync.WaitGroup
+			for j := 0; j < 50; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if ReserveChannel(channel) {
+						atomic.AddInt32(&reserved, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			if reserved != tt.want {
+				t.Fatalf("reserved %d slots, want %d", reserved, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserveChannelRelease(t *testing.T) {
+	resetChannelUsages()
+	concurrency := 1
+	channel := &Channel{Id: 2000, MaxConcurrency: &concurrency}
+	if !ReserveChannel(channel) {
+		t.Fatal("first reservation failed")
+	}
+	if ReserveChannel(channel) {
+		t.Fatal("second reservation passed the concurrency limit")
+	}
+	ReleaseChannel(channel.Id)
+	if !ReserveChannel(channel) {
+		t.Fatal("reservation failed after the slot was released")
+	}
+	if !ReserveChannel(&Channel{Id: 2001}) {
+		t.Fatal("a channel without limits has to be reservable")
+	}
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	"one-api/common"
+	"sort"
+	"sync"
+	"time"
+)
+
+// channelScoreMaxOutcomes caps the outcomes kept per channel and model within the window
+const channelScoreMaxOutcomes = 200
+
+// channelScoreMinFactor keeps a little traffic on unhealthy channels so that they can recover
+const channelScoreMinFactor = 0.05
+
+type channelOutcome struct {
+	time    int64
+	latency int64 // milliseconds
+	ttft    int64 // milliseconds, 0 if nothing was written
+	success bool
+}
+
+// channelOutcomes is a ring buffer of the latest relay outcomes of a channel for a model
+type channelOutcomes struct {
+	outcomes []channelOutcome
+	next     int
+}
+
+// ChannelScore summarizes the rolling window of a channel for a model, the
+// score is the factor its weight is multiplied with in the adaptive select mode
+type ChannelScore struct {
+	ChannelId  int     `json:"channel_id"`
+	Model      string  `json:"model"`
+	Requests   int     `json:"requests"`
+	ErrorRate  float64 `json:"error_rate"`
+	AvgLatency int64   `json:"avg_latency"`
+	AvgTTFT    int64   `json:"avg_ttft"`
+	Score      float64 `json:"score"`
+	// states of the circuit breakers of the channel and of the channel for the model
+	ChannelBreaker string `json:"channel_breaker"`
+	Breaker        string `json:"breaker"`
+}
+
+// the scores are kept in memory of each node, they are fed by the requests it relays
+var channelScoreLock sync.Mutex
+var model2channelOutcomes = make(map[string]map[int]*channelOutcomes)
+
+// RecordChannelOutcome adds the outcome of a relay attempt to the window of the channel,
+// ttft is the time until the first byte was written to the client
+func RecordChannelOutcome(channelId int, model string, latency time.Duration, ttft time.Duration, success bool) {
+	recordBreakerOutcome(channelId, model, success)
+	outcome := channelOutcome{
+		time:    time.Now().Unix(),
+		latency: latency.Milliseconds(),
+		ttft:    ttft.Milliseconds(),
+		success: success,
+	}
+	channelScoreLock.Lock()
+	defer channelScoreLock.Unlock()
+	channel2outcomes, ok := model2channelOutcomes[model]
+	if !ok {
+		channel2outcomes = make(map[int]*channelOutcomes)
+		model2channelOutcomes[model] = channel2outcomes
+	}
+	outcomes, ok := channel2outcomes[channelId]
+	if !ok {
+		outcomes = &channelOutcomes{}
+		channel2outcomes[channelId] = outcomes
+	}
+	if len(outcomes.outcomes) < channelScoreMaxOutcomes {
+		outcomes.outcomes = append(outcomes.outcomes, outcome)
+	} else {
+		outcomes.outcomes[outcomes.next] = outcome
+		outcomes.next = (outcomes.next + 1) % channelScoreMaxOutcomes
+	}
+}
+
+func (o *channelOutcomes) summarize(channelId int, model string, since int64) ChannelScore {
+	score := ChannelScore{
+		ChannelId: channelId,
+		Model:     model,
+		Score:     1,
+	}
+	failures, successes, ttfts := 0, 0, 0
+	var latencySum, ttftSum int64
+	for _, outcome := range o.outcomes {
+		if outcome.time < since {
+			continue
+		}
+		score.Requests++
+		if !outcome.success {
+			failures++
+			continue
+		}
+		successes++
+		latencySum += outcome.latency
+		if outcome.ttft > 0 {
+			ttfts++
+			ttftSum += outcome.ttft
+		}
+	}
+	if score.Requests > 0 {
+		score.ErrorRate = float64(failures) / float64(score.Requests)
+	}
+	if successes > 0 {
+		score.AvgLatency = latencySum / int64(successes)
+	}
+	if ttfts > 0 {
+		score.AvgTTFT = ttftSum / int64(ttfts)
+	}
+	return score
+}
+
+// responseTime is what the latency part of the score compares, the time to first token if known
+func (s *ChannelScore) responseTime() int64 {
+	if s.AvgTTFT > 0 {
+		return s.AvgTTFT
+	}
+	return s.AvgLatency
+}
+
+// getModelChannelScores summarizes the windows of all channels serving the model and scores
+// them, the response time of a channel is compared with the average of the channels
+func getModelChannelScores(model string) map[int]*ChannelScore {
+	since := time.Now().Unix() - int64(common.ChannelScoreWindow)
+	channelScoreLock.Lock()
+	channel2outcomes := model2channelOutcomes[model]
+	scores := make(map[int]*ChannelScore, len(channel2outcomes))
+	for channelId, outcomes := range channel2outcomes {
+		score := outcomes.summarize(channelId, model, since)
+		scores[channelId] = &score
+	}
+	channelScoreLock.Unlock()
+
+	var responseTimeSum int64
+	responseTimes := 0
+	for _, score := range scores {
+		if score.Requests >= common.ChannelScoreMinRequests && score.responseTime() > 0 {
+			responseTimeSum += score.responseTime()
+			responseTimes++
+		}
+	}
+	for _, score := range scores {
+		if score.Requests < common.ChannelScoreMinRequests {
+			continue
+		}
+		health := (1 - score.ErrorRate) * (1 - score.ErrorRate)
+		latencyFactor := 1.0
+		if responseTimes > 0 && score.responseTime() > 0 {
+			averageResponseTime := float64(responseTimeSum) / float64(responseTimes)
+			latencyFactor = averageResponseTime / float64(score.responseTime())
+			if latencyFactor > 4 {
+				latencyFactor = 4
+			} else if latencyFactor < 0.25 {
+				latencyFactor = 0.25
+			}
+		}
+		score.Score = health * latencyFactor
+		if score.Score < channelScoreMinFactor {
+			score.Score = channelScoreMinFactor
+		}
+	}
+	return scores
+}
+
+// GetChannelScores returns the live scores of every channel and model, or of one channel if channelId is not 0
+func GetChannelScores(channelId int) []*ChannelScore {
+	channelScoreLock.Lock()
+	models := make([]string, 0, len(model2channelOutcomes))
+	for model := range model2channelOutcomes {
+		models = append(models, model)
+	}
+	channelScoreLock.Unlock()
+
+	result := make([]*ChannelScore, 0)
+	for _, model := range models {
+		for _, score := range getModelChannelScores(model) {
+			if channelId == 0 || score.ChannelId == channelId {
+				score.ChannelBreaker, score.Breaker = getBreakerStates(score.ChannelId, score.Model)
+				result = append(result, score)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// getChannelWeightFactors returns the factors the weights of the channels are scaled with,
+// they are all 1 unless the group uses the adaptive select mode
+func getChannelWeightFactors(group string, model string, channelIds []int) []float64 {
+	factors := make([]float64, len(channelIds))
+	for i := range factors {
+		factors[i] = 1
+	}
+	if common.GetGroupChannelSelectMode(group) != common.ChannelSelectModeAdaptive {
+		return factors
+	}
+	scores := getModelChannelScores(model)
+	for i, channelId := range channelIds {
+		if score, ok := scores[channelId]; ok {
+			factors[i] = score.Score
+		}
+	}
+	return factors
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"math"
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+// setChannelScoreOptions sets the window options and drops the recorded outcomes
+func setChannelScoreOptions(t *testing.T, window int, minRequests int) {
+	t.Helper()
+	previousWindow, previousMinRequests := common.ChannelScoreWindow, common.ChannelScoreMinRequests
+	previousBreaker := common.CircuitBreakerEnabled
+	common.ChannelScoreWindow, common.ChannelScoreMinRequests = window, minRequests
+	common.CircuitBreakerEnabled = false
+	model2channelOutcomes = make(map[string]map[int]*channelOutcomes)
+	t.Cleanup(func() {
+		common.ChannelScoreWindow, common.ChannelScoreMinRequests = previousWindow, previousMinRequests
+		common.CircuitBreakerEnabled = previousBreaker
+		model2channelOutcomes = make(map[string]map[int]*channelOutcomes)
+	})
+}
+
+func recordOutcomes(channelId int, model string, count int, latency time.Duration, ttft time.Duration, success bool) {
+	for i := 0; i < count; i++ {
+		RecordChannelOutcome(channelId, model, latency, ttft, success)
+	}
+}
+
+func TestGetModelChannelScores(t *testing.T) {
+	setChannelScoreOptions(t, 300, 5)
+	// the time to first token is compared when known, the latency otherwise
+	recordOutcomes(1, "gpt-4o", 10, time.Second, 100*time.Millisecond, true)
+	recordOutcomes(2, "gpt-4o", 8, 300*time.Millisecond, 0, true)
+	recordOutcomes(2, "gpt-4o", 2, 0, 0, false)
+	// too few requests to be scored, and not part of the average response time
+	recordOutcomes(3, "gpt-4o", 3, 50*time.Millisecond, 0, true)
+	recordOutcomes(4, "gpt-4o", 5, 0, 0, false)
+	// the five channels of another model, one of them four times slower than the average
+	for channelId := 1; channelId <= 4; channelId++ {
+		recordOutcomes(channelId, "gpt-4o-mini", 5, 10*time.Millisecond, 0, true)
+	}
+	recordOutcomes(5, "gpt-4o-mini", 5, time.Second, 0, true)
+
+	tests := []struct {
+		model      string
+		channelId  int
+		requests   int
+		errorRate  float64
+		avgLatency int64
+		avgTTFT    int64
+		want       float64
+	}{
+		// the average response time of the scored channels is 200ms
+		{"gpt-4o", 1, 10, 0, 1000, 100, 2},
+		{"gpt-4o", 2, 10, 0.2, 300, 0, 0.64 * 200 / 300},
+		{"gpt-4o", 3, 3, 0, 50, 0, 1},
+		{"gpt-4o", 4, 5, 1, 0, 0, channelScoreMinFactor},
+		// the latency factor is kept between 0.25 and 4
+		{"gpt-4o-mini", 1, 5, 0, 10, 0, 4},
+		{"gpt-4o-mini", 5, 5, 0, 1000, 0, 0.25},
+	}
+	for _, tt := range tests {
+		score := getModelChannelScores(tt.model)[tt.channelId]
+		if score == nil {
+			t.Fatalf("channel #%d has no score for %s", tt.channelId, tt.model)
+		}
+		if score.Requests != tt.requests || math.Abs(score.ErrorRate-tt.errorRate) > 1e-9 ||
+			score.AvgLatency != tt.avgLatency || score.AvgTTFT != tt.avgTTFT {
+			t.Errorf("channel #%d for %s = %+v, want %d requests, error rate %v, latency %d and ttft %d",
+				tt.channelId, tt.model, *score, tt.requests, tt.errorRate, tt.avgLatency, tt.avgTTFT)
+		}
+		if math.Abs(score.Score-tt.want) > 1e-9 {
+			t.Errorf("score of channel #%d for %s = %v, want %v", tt.channelId, tt.model, score.Score, tt.want)
+		}
+	}
+}
+
+func TestChannelScoreWindow(t *testing.T) {
+	setChannelScoreOptions(t, 300, 1)
+	recordOutcomes(1, "gpt-4o", channelScoreMaxOutcomes+50, 100*time.Millisecond, 0, true)
+	outcomes := model2channelOutcomes["gpt-4o"][1]
+	if len(outcomes.outcomes) != channelScoreMaxOutcomes || outcomes.next != 50 {
+		t.Fatalf("the window holds %d outcomes and continues at %d, want %d and 50", len(outcomes.outcomes), outcomes.next, channelScoreMaxOutcomes)
+	}
+	// failures older than the window do not count
+	old := time.Now().Unix() - 301
+	for i := 0; i < 100; i++ {
+		outcomes.outcomes[i] = channelOutcome{time: old, success: false}
+	}
+	score := getModelChannelScores("gpt-4o")[1]
+	if score.Requests != channelScoreMaxOutcomes-100 || score.ErrorRate != 0 || score.Score != 1 {
+		t.Errorf("score = %+v, want %d requests without errors", *score, channelScoreMaxOutcomes-100)
+	}
+}
+
+func TestGetChannelWeightFactors(t *testing.T) {
+	setChannelScoreOptions(t, 300, 1)
+	recordOutcomes(1, "gpt-4o", 5, 100*time.Millisecond, 0, true)
+	recordOutcomes(2, "gpt-4o", 5, 300*time.Millisecond, 0, true)
+	previousModes := common.GroupChannelSelectMode
+	t.Cleanup(func() {
+		common.GroupChannelSelectMode = previousModes
+	})
+	common.GroupChannelSelectMode = map[string]string{"fast": common.ChannelSelectModeAdaptive}
+	tests := []struct {
+		group string
+		want  []float64
+	}{
+		{"default", []float64{1, 1, 1}},
+		{"fast", []float64{2, 200.0 / 300, 1}},
+	}
+	for _, tt := range tests {
+		got := getChannelWeightFactors(tt.group, "gpt-4o", []int{1, 2, 3})
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("factors of group %s = %v, want %v", tt.group, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stickyBinding is the channel and key a session was last served by
+type stickyBinding struct {
+	channelId int
+	keyId     int
+	expiresAt int64
+}
+
+// the bindings are kept in memory of each node unless redis is enabled
+var stickyLock sync.Mutex
+var stickyBindings = make(map[string]stickyBinding)
+var stickySets = 0
+
+func stickyRedisKey(key string) string {
+	return "sticky:" + key
+}
+
+// SetStickyChannel binds the session to the channel and key that just served it, a later call refreshes the ttl
+func SetStickyChannel(key string, channelId int, keyId int) {
+	ttl := time.Duration(common.StickyRoutingTTL) * time.Second
+	if common.RedisEnabled {
+		err := common.RedisSet(stickyRedisKey(key), fmt.Sprintf("%d:%d", channelId, keyId), ttl)
+		if err != nil {
+			common.SysError("failed to set sticky channel: " + err.Error())
+		}
+		return
+	}
+	now := time.Now().Unix()
+	stickyLock.Lock()
+	defer stickyLock.Unlock()
+	stickyBindings[key] = stickyBinding{channelId: channelId, keyId: keyId, expiresAt: now + int64(common.StickyRoutingTTL)}
+	// sweep the expired bindings once in a while
+	stickySets++
+	if stickySets%1024 == 0 {
+		for k, binding := range stickyBindings {
+			if binding.expiresAt <= now {
+				delete(stickyBindings, k)
+			}
+		}
+	}
+}
+
+func getStickyBinding(key string) (stickyBinding, bool) {
+	if common.RedisEnabled {
+		value, err := common.RedisGet(stickyRedisKey(key))
+		if err != nil {
+			return stickyBinding{}, false
+		}
+		parts := strings.Split(value, ":")
+		if len(parts) != 2 {
+			return stickyBinding{}, false
+		}
+		channelId, _ := strconv.Atoi(parts[0])
+		keyId, _ := strconv.Atoi(parts[1])
+		return stickyBinding{channelId: channelId, keyId: keyId}, true
+	}
+	stickyLock.Lock()
+	defer stickyLock.Unlock()
+	binding, ok := stickyBindings[key]
+	if !ok || binding.expiresAt <= time.Now().Unix() {
+		return stickyBinding{}, false
+	}
+	return binding, true
+}
+
+// GetStickyChannel returns the channel and key id the session is bound to, as long as the channel
+// is enabled, still serves the group and model and is neither held back by its breakers nor its limits,
+// a request slot of the channel is reserved like for any selected channel
+func GetStickyChannel(key string, group string, model string) (*Channel, int) {
+	binding, ok := getStickyBinding(key)
+	if !ok {
+		return nil, 0
+	}
+	channel, err := CacheGetChannel(binding.channelId)
+	if err != nil || channel.Status != common.ChannelStatusEnabled {
+		return nil, 0
+	}
+	if !common.StringsContains(strings.Split(channel.Group, ","), group) || !channel.ServesModel(model) {
+		return nil, 0
+	}
+	if common.CircuitBreakerEnabled {
+		channelState, modelState := getBreakerStates(channel.Id, model)
+		if channelState != BreakerStateClosed || modelState != BreakerStateClosed {
+			return nil, 0
+		}
+	}
+	if !ReserveChannel(channel) {
+		return nil, 0
+	}
+	return channel, binding.keyId
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+	"time"
+)
+
+func TestStickyChannelTTL(t *testing.T) {
+	previous := common.StickyRoutingTTL
+	t.Cleanup(func() {
+		common.StickyRoutingTTL = previous
+		stickyBindings = make(map[string]stickyBinding)
+	})
+	stickyBindings = make(map[string]stickyBinding)
+	common.StickyRoutingTTL = 60
+
+	SetStickyChannel("session-a", 1, 10)
+	binding, ok := getStickyBinding("session-a")
+	if !ok || binding.channelId != 1 || binding.keyId != 10 {
+		t.Fatalf("binding = %+v, %v, want channel #1 with key #10", binding, ok)
+	}
+	if ttl := binding.expiresAt - time.Now().Unix(); ttl < 59 || ttl > 60 {
+		t.Errorf("the binding expires in %ds, want 60s", ttl)
+	}
+	if _, ok := getStickyBinding("session-b"); ok {
+		t.Error("an unknown session is bound")
+	}
+
+	// the binding expires once its ttl is over
+	stickyBindings["session-a"] = stickyBinding{channelId: 1, keyId: 10, expiresAt: time.Now().Unix()}
+	if _, ok := getStickyBinding("session-a"); ok {
+		t.Error("an expired binding was returned")
+	}
+
+	// serving the session again rebinds it and refreshes the ttl
+	SetStickyChannel("session-a", 2, 0)
+	binding, ok = getStickyBinding("session-a")
+	if !ok || binding.channelId != 2 || binding.keyId != 0 || binding.expiresAt <= time.Now().Unix() {
+		t.Errorf("binding after serving the session again = %+v, %v", binding, ok)
+	}
+}
+
+func TestStickyChannelSweep(t *testing.T) {
+	previous := common.StickyRoutingTTL
+	t.Cleanup(func() {
+		common.StickyRoutingTTL = previous
+		stickyBindings = make(map[string]stickyBinding)
+	})
+	stickyBindings = make(map[string]stickyBinding)
+	common.StickyRoutingTTL = 60
+	expired := time.Now().Unix() - 1
+	for _, key := range []string{"old-a", "old-b"} {
+		stickyBindings[key] = stickyBinding{channelId: 1, expiresAt: expired}
+	}
+	// the expired bindings are swept every 1024 calls
+	stickySets = 0
+	for i := 0; i < 1023; i++ {
+		SetStickyChannel("session", 1, 0)
+	}
+	if len(stickyBindings) != 3 {
+		t.Fatalf("%d bindings before the sweep, want 3", len(stickyBindings))
+	}
+	SetStickyChannel("session", 1, 0)
+	if _, ok := stickyBindings["old-a"]; ok || len(stickyBindings) != 1 {
+		t.Errorf("%d bindings after the sweep, want 1", len(stickyBindings))
+	}
+}
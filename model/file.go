@@ -0,0 +1,49 @@
+package model
+
+// File is a file uploaded through the Files API or produced by a batch,
+// the content lives in the file store under StoreName
+type File struct {
+	Id        int    `json:"id"`
+	FileId    string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose" gorm:"type:varchar(32)"`
+	Bytes     int64  `json:"bytes"`
+	StoreName string `json:"store_name"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// GetUserFiles returns the files of the user newest first, after the file with the id afterId if it is not 0
+func GetUserFiles(userId int, purpose string, afterId int, num int) ([]*File, error) {
+	var files []*File
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	if afterId != 0 {
+		query = query.Where("id < ?", afterId)
+	}
+	err := query.Order("id desc").Limit(num).Find(&files).Error
+	return files, err
+}
+
+// GetUserFilesBytes returns the total size of the files of the user
+func GetUserFilesBytes(userId int) (int64, error) {
+	var bytes int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).Select("COALESCE(SUM(bytes), 0)").Scan(&bytes).Error
+	return bytes, err
+}
+
+func GetFileByFileId(userId int, fileId string) (*File, error) {
+	var file *File
+	err := DB.Where("user_id = ? and file_id = ?", userId, fileId).First(&file).Error
+	return file, err
+}
+
+func (file *File) Insert() error {
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
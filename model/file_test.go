@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetUserFiles(t *testing.T) {
+	setupTestDB(t, &File{})
+	for i := 1; i <= 5; i++ {
+		userId := 1
+		if i == 3 {
+			userId = 2
+		}
+		file := &File{FileId: fmt.Sprintf("file-%d", i), UserId: userId, Bytes: int64(i * 100)}
+		if err := file.Insert(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		afterId int
+		num     int
+		want    []string
+	}{
+		{"newest first", 0, 10, []string{"file-5", "file-4", "file-2", "file-1"}},
+		{"limited", 0, 2, []string{"file-5", "file-4"}},
+		{"after a file", 4, 2, []string{"file-2", "file-1"}},
+		{"after the last file", 1, 2, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := GetUserFiles(1, "", tt.afterId, tt.num)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(files))
+			for _, file := range files {
+				got = append(got, file.FileId)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetUserFiles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for userId, want := range map[int]int64{1: 1200, 2: 300, 3: 0} {
+		if bytes, err := GetUserFilesBytes(userId); bytes != want || err != nil {
+			t.Errorf("GetUserFilesBytes(%d) = %d, %v, want %d", userId, bytes, err, want)
+		}
+	}
+}
@@ -91,7 +91,15 @@ func InitDB() (err error) {
 		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetOrDefault("SQL_MAX_LIFETIME", 60)))
 
 		if !common.IsMasterNode {
-			return nil
+			err = initTokenHashSecret()
+			if err != nil {
+				return err
+			}
+			err = checkChannelKeys()
+			if err != nil {
+				return err
+			}
+			return checkTwoFASecrets()
 		}
 		//if common.UsingMySQL {
 		//	_, _ = sqlDB.Exec("DROP INDEX idx_channels_key ON channels;")             // TODO: delete this line when most users have upgraded
@@ -140,6 +148,42 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&File{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&Batch{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelKey{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&TwoFA{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AuditLog{})
+		if err != nil {
+			return err
+		}
+		err = initTokenHashSecret()
+		if err != nil {
+			return err
+		}
+		err = migrateTokenKeys()
+		if err != nil {
+			return err
+		}
+		err = checkChannelKeys()
+		if err != nil {
+			return err
+		}
+		err = checkTwoFASecrets()
+		if err != nil {
+			return err
+		}
 		common.SysLog("database migrated")
 		err = createRootAccountIfNeed()
 		return err
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"one-api/common"
+	"os"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	// the tests run against the in-memory fallbacks, InitRedisClient is not called
+	common.RedisEnabled = false
+	os.Exit(m.Run())
+}
+
+// setupTestDB points DB at a fresh in-memory sqlite database with the tables of the given models
+func setupTestDB(t *testing.T, models ...any) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: opens a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	err = db.AutoMigrate(models...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		sqlDB.Close()
+	})
+}
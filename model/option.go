@@ -1,6 +1,7 @@
 package model
 
 import (
+	"errors"
 	"one-api/common"
 	"one-api/constant"
 	"strconv"
@@ -31,12 +32,20 @@ func InitOptionMap() {
 	common.OptionMap["PasswordRegisterEnabled"] = strconv.FormatBool(common.PasswordRegisterEnabled)
 	common.OptionMap["EmailVerificationEnabled"] = strconv.FormatBool(common.EmailVerificationEnabled)
 	common.OptionMap["GitHubOAuthEnabled"] = strconv.FormatBool(common.GitHubOAuthEnabled)
+	common.OptionMap["OIDCEnabled"] = strconv.FormatBool(common.OIDCEnabled)
 	common.OptionMap["TelegramOAuthEnabled"] = strconv.FormatBool(common.TelegramOAuthEnabled)
 	common.OptionMap["WeChatAuthEnabled"] = strconv.FormatBool(common.WeChatAuthEnabled)
 	common.OptionMap["TurnstileCheckEnabled"] = strconv.FormatBool(common.TurnstileCheckEnabled)
 	common.OptionMap["RegisterEnabled"] = strconv.FormatBool(common.RegisterEnabled)
 	common.OptionMap["AutomaticDisableChannelEnabled"] = strconv.FormatBool(common.AutomaticDisableChannelEnabled)
 	common.OptionMap["AutomaticEnableChannelEnabled"] = strconv.FormatBool(common.AutomaticEnableChannelEnabled)
+	common.OptionMap["CircuitBreakerEnabled"] = strconv.FormatBool(common.CircuitBreakerEnabled)
+	common.OptionMap["CircuitBreakerThreshold"] = strconv.Itoa(common.CircuitBreakerThreshold)
+	common.OptionMap["CircuitBreakerCooldown"] = strconv.Itoa(common.CircuitBreakerCooldown)
+	common.OptionMap["CircuitBreakerHalfOpenRate"] = strconv.FormatFloat(common.CircuitBreakerHalfOpenRate, 'f', -1, 64)
+	common.OptionMap["StickyRoutingEnabled"] = strconv.FormatBool(common.StickyRoutingEnabled)
+	common.OptionMap["StickyRoutingHeader"] = common.StickyRoutingHeader
+	common.OptionMap["StickyRoutingTTL"] = strconv.Itoa(common.StickyRoutingTTL)
 	common.OptionMap["LogConsumeEnabled"] = strconv.FormatBool(common.LogConsumeEnabled)
 	common.OptionMap["DisplayInCurrencyEnabled"] = strconv.FormatBool(common.DisplayInCurrencyEnabled)
 	common.OptionMap["DisplayTokenStatEnabled"] = strconv.FormatBool(common.DisplayTokenStatEnabled)
@@ -70,6 +79,15 @@ func InitOptionMap() {
 	common.OptionMap["TopupGroupRatio"] = common.TopupGroupRatio2JSONString()
 	common.OptionMap["GitHubClientId"] = ""
 	common.OptionMap["GitHubClientSecret"] = ""
+	common.OptionMap["OIDCName"] = common.OIDCName
+	common.OptionMap["OIDCIssuer"] = ""
+	common.OptionMap["OIDCClientId"] = ""
+	common.OptionMap["OIDCClientSecret"] = ""
+	common.OptionMap["OIDCScopes"] = common.OIDCScopes
+	common.OptionMap["OIDCUsernameClaim"] = common.OIDCUsernameClaim
+	common.OptionMap["OIDCEmailClaim"] = common.OIDCEmailClaim
+	common.OptionMap["OIDCGroupClaim"] = ""
+	common.OptionMap["OIDCGroupMapping"] = ""
 	common.OptionMap["TelegramBotToken"] = ""
 	common.OptionMap["TelegramBotName"] = ""
 	common.OptionMap["WeChatServerAddress"] = ""
@@ -78,13 +96,20 @@ func InitOptionMap() {
 	common.OptionMap["TurnstileSiteKey"] = ""
 	common.OptionMap["TurnstileSecretKey"] = ""
 	common.OptionMap["QuotaForNewUser"] = strconv.Itoa(common.QuotaForNewUser)
+	common.OptionMap["TwoFARequiredRole"] = strconv.Itoa(common.TwoFARequiredRole)
 	common.OptionMap["QuotaForInviter"] = strconv.Itoa(common.QuotaForInviter)
 	common.OptionMap["QuotaForInvitee"] = strconv.Itoa(common.QuotaForInvitee)
 	common.OptionMap["QuotaRemindThreshold"] = strconv.Itoa(common.QuotaRemindThreshold)
 	common.OptionMap["PreConsumedQuota"] = strconv.Itoa(common.PreConsumedQuota)
+	common.OptionMap["BatchDiscount"] = strconv.FormatFloat(common.BatchDiscount, 'f', -1, 64)
 	common.OptionMap["ModelRatio"] = common.ModelRatio2JSONString()
 	common.OptionMap["ModelPrice"] = common.ModelPrice2JSONString()
 	common.OptionMap["GroupRatio"] = common.GroupRatio2JSONString()
+	common.OptionMap["GroupChannelSelectMode"] = common.GroupChannelSelectMode2JSONString()
+	common.OptionMap["GroupModelFallback"] = common.GroupModelFallback2JSONString()
+	common.OptionMap["ModelAliases"] = common.ModelAliases2JSONString()
+	common.OptionMap["GroupHedgeDelay"] = common.GroupHedgeDelay2JSONString()
+	common.OptionMap["AdminRoles"] = common.AdminRoles2JSONString()
 	common.OptionMap["CompletionRatio"] = common.CompletionRatio2JSONString()
 	common.OptionMap["TopUpLink"] = common.TopUpLink
 	common.OptionMap["ChatLink"] = common.ChatLink
@@ -127,7 +152,22 @@ func SyncOptions(frequency int) {
 	}
 }
 
+// parseBatchDiscount parses the discount of batch requests, a fraction of the regular quota
+func parseBatchDiscount(value string) (float64, error) {
+	discount, err := strconv.ParseFloat(value, 64)
+	// NaN fails both comparisons
+	if err != nil || !(discount >= 0 && discount <= 1) {
+		return 0, errors.New("批处理折扣必须是 0 到 1 之间的数字")
+	}
+	return discount, nil
+}
+
 func UpdateOption(key string, value string) error {
+	if key == "BatchDiscount" {
+		if _, err := parseBatchDiscount(value); err != nil {
+			return err
+		}
+	}
 	// Save to database first
 	option := Option{
 		Key: key,
@@ -171,6 +211,8 @@ func updateOptionMap(key string, value string) (err error) {
 			common.EmailVerificationEnabled = boolValue
 		case "GitHubOAuthEnabled":
 			common.GitHubOAuthEnabled = boolValue
+		case "OIDCEnabled":
+			common.OIDCEnabled = boolValue
 		case "WeChatAuthEnabled":
 			common.WeChatAuthEnabled = boolValue
 		case "TelegramOAuthEnabled":
@@ -187,6 +229,10 @@ func updateOptionMap(key string, value string) (err error) {
 			common.AutomaticDisableChannelEnabled = boolValue
 		case "AutomaticEnableChannelEnabled":
 			common.AutomaticEnableChannelEnabled = boolValue
+		case "CircuitBreakerEnabled":
+			common.CircuitBreakerEnabled = boolValue
+		case "StickyRoutingEnabled":
+			common.StickyRoutingEnabled = boolValue
 		case "LogConsumeEnabled":
 			common.LogConsumeEnabled = boolValue
 		case "DisplayInCurrencyEnabled":
@@ -257,6 +303,24 @@ func updateOptionMap(key string, value string) (err error) {
 		common.GitHubClientId = value
 	case "GitHubClientSecret":
 		common.GitHubClientSecret = value
+	case "OIDCName":
+		common.OIDCName = value
+	case "OIDCIssuer":
+		common.OIDCIssuer = strings.TrimSuffix(value, "/")
+	case "OIDCClientId":
+		common.OIDCClientId = value
+	case "OIDCClientSecret":
+		common.OIDCClientSecret = value
+	case "OIDCScopes":
+		common.OIDCScopes = value
+	case "OIDCUsernameClaim":
+		common.OIDCUsernameClaim = value
+	case "OIDCEmailClaim":
+		common.OIDCEmailClaim = value
+	case "OIDCGroupClaim":
+		common.OIDCGroupClaim = value
+	case "OIDCGroupMapping":
+		common.OIDCGroupMapping = value
 	case "Footer":
 		common.Footer = value
 	case "SystemName":
@@ -279,6 +343,8 @@ func updateOptionMap(key string, value string) (err error) {
 		common.TurnstileSecretKey = value
 	case "QuotaForNewUser":
 		common.QuotaForNewUser, _ = strconv.Atoi(value)
+	case "TwoFARequiredRole":
+		common.TwoFARequiredRole, _ = strconv.Atoi(value)
 	case "QuotaForInviter":
 		common.QuotaForInviter, _ = strconv.Atoi(value)
 	case "QuotaForInvitee":
@@ -287,8 +353,24 @@ func updateOptionMap(key string, value string) (err error) {
 		common.QuotaRemindThreshold, _ = strconv.Atoi(value)
 	case "PreConsumedQuota":
 		common.PreConsumedQuota, _ = strconv.Atoi(value)
+	case "BatchDiscount":
+		var discount float64
+		discount, err = parseBatchDiscount(value)
+		if err == nil {
+			common.BatchDiscount = discount
+		}
 	case "RetryTimes":
 		common.RetryTimes, _ = strconv.Atoi(value)
+	case "CircuitBreakerThreshold":
+		common.CircuitBreakerThreshold, _ = strconv.Atoi(value)
+	case "CircuitBreakerCooldown":
+		common.CircuitBreakerCooldown, _ = strconv.Atoi(value)
+	case "CircuitBreakerHalfOpenRate":
+		common.CircuitBreakerHalfOpenRate, _ = strconv.ParseFloat(value, 64)
+	case "StickyRoutingHeader":
+		common.StickyRoutingHeader = value
+	case "StickyRoutingTTL":
+		common.StickyRoutingTTL, _ = strconv.Atoi(value)
 	case "DataExportInterval":
 		common.DataExportInterval, _ = strconv.Atoi(value)
 	case "DataExportDefaultTime":
@@ -297,6 +379,16 @@ func updateOptionMap(key string, value string) (err error) {
 		err = common.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
 		err = common.UpdateGroupRatioByJSONString(value)
+	case "GroupChannelSelectMode":
+		err = common.UpdateGroupChannelSelectModeByJSONString(value)
+	case "GroupModelFallback":
+		err = common.UpdateGroupModelFallbackByJSONString(value)
+	case "ModelAliases":
+		err = common.UpdateModelAliasesByJSONString(value)
+	case "GroupHedgeDelay":
+		err = common.UpdateGroupHedgeDelayByJSONString(value)
+	case "AdminRoles":
+		err = common.UpdateAdminRolesByJSONString(value)
 	case "CompletionRatio":
 		err = common.UpdateCompletionRatioByJSONString(value)
 	case "ModelPrice":
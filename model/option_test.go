@@ -0,0 +1,44 @@
+package model
+
+import (
+	"one-api/common"
+	"testing"
+)
+
+func TestUpdateOptionBatchDiscount(t *testing.T) {
+	setupTestDB(t, &Option{})
+	previous, previousMap := common.BatchDiscount, common.OptionMap
+	common.OptionMap = make(map[string]string)
+	t.Cleanup(func() {
+		common.BatchDiscount, common.OptionMap = previous, previousMap
+	})
+	tests := []struct {
+		value   string
+		wantErr bool
+		want    float64
+	}{
+		{"0.3", false, 0.3},
+		{"0", false, 0},
+		{"1", false, 1},
+		{"1.5", true, 1},
+		{"-0.1", true, 1},
+		{"NaN", true, 1},
+		{"half", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := UpdateOption("BatchDiscount", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateOption(BatchDiscount, %s) = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if common.BatchDiscount != tt.want {
+				t.Errorf("BatchDiscount = %v, want %v", common.BatchDiscount, tt.want)
+			}
+			var option Option
+			DB.Where(&Option{Key: "BatchDiscount"}).Find(&option)
+			if tt.wantErr && option.Value == tt.value {
+				t.Errorf("the rejected value %s was saved", tt.value)
+			}
+		})
+	}
+}
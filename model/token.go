@@ -13,7 +13,9 @@ import (
 type Token struct {
 	Id                 int            `json:"id"`
 	UserId             int            `json:"user_id" gorm:"index"`
-	Key                string         `json:"key" gorm:"type:char(48);uniqueIndex"`
+	Key                string         `json:"key,omitempty" gorm:"-:all"` // the plain key, only known right after creation
+	KeyHash            string         `json:"-" gorm:"type:char(64);uniqueIndex:idx_tokens_key_hash_unique"`
+	KeyPrefix          string         `json:"key_prefix" gorm:"type:varchar(16);default:''"`
 	Status             int            `json:"status" gorm:"default:1"`
 	Name               string         `json:"name" gorm:"index" `
 	CreatedTime        int64          `json:"created_time" gorm:"bigint"`
@@ -23,7 +25,12 @@ type Token struct {
 	UnlimitedQuota     bool           `json:"unlimited_quota" gorm:"default:false"`
 	ModelLimitsEnabled bool           `json:"model_limits_enabled" gorm:"default:false"`
 	ModelLimits        string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
-	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
+	UsedQuota          int            `json:"used_quota" gorm:"default:0"`                    // used quota
+	RPMLimit           int            `json:"rpm_limit" gorm:"default:0"`                     // requests per minute, 0 means unlimited
+	TPMLimit           int            `json:"tpm_limit" gorm:"default:0"`                     // tokens per minute, 0 means unlimited
+	ConcurrencyLimit   int            `json:"concurrency_limit" gorm:"default:0"`             // concurrent requests, 0 means unlimited
+	AllowIps           string         `json:"allow_ips" gorm:"type:varchar(1024);default:''"` // allowed CIDRs, empty means any ip
+	Scopes             string         `json:"scopes" gorm:"type:varchar(255);default:''"`     // allowed api surfaces, empty means all
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -35,55 +42,68 @@ func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
 }
 
 func SearchUserTokens(userId int, keyword string, token string) (tokens []*Token, err error) {
-	if token != "" {
-		token = strings.Trim(token, "sk-")
+	tx := DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%")
+	if token = strings.TrimPrefix(token, "sk-"); token != "" {
+		// only the prefix of a key is stored, a full key is found by its hash
+		tx = tx.Where("key_hash = ? OR key_prefix LIKE ? ESCAPE '!'", common.HashTokenKey(token), escapeLike(token)+"%")
 	}
-	err = DB.Where("user_id = ?", userId).Where("name LIKE ?", "%"+keyword+"%").Where("`key` LIKE ?", "%"+token+"%").Find(&tokens).Error
+	err = tx.Find(&tokens).Error
 	return tokens, err
 }
 
+// escapeLike escapes the wildcards of a LIKE pattern with !, the escape character is given explicitly
+// since sqlite has no default one
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
 func ValidateUserToken(key string) (token *Token, err error) {
 	if key == "" {
 		return nil, errors.New("未提供令牌")
 	}
 	token, err = CacheGetTokenByKey(key)
-	if err == nil {
-		if token.Status == common.TokenStatusExhausted {
-			keyPrefix := key[:3]
-			keySuffix := key[len(key)-3:]
-			return nil, errors.New("该令牌额度已用尽 TokenStatusExhausted[sk-" + keyPrefix + "***" + keySuffix + "]")
-		} else if token.Status == common.TokenStatusExpired {
-			return nil, errors.New("该令牌已过期")
-		}
-		if token.Status != common.TokenStatusEnabled {
-			return nil, errors.New("该令牌状态不可用")
-		}
-		if token.ExpiredTime != -1 && token.ExpiredTime < common.GetTimestamp() {
-			if !common.RedisEnabled {
-				token.Status = common.TokenStatusExpired
-				err := token.SelectUpdate()
-				if err != nil {
-					common.SysError("failed to update token status" + err.Error())
-				}
+	if err != nil {
+		return nil, errors.New("无效的令牌")
+	}
+	err = token.CheckStatus()
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// CheckStatus returns why the token cannot be used, if it cannot
+func (token *Token) CheckStatus() error {
+	if token.Status == common.TokenStatusExhausted {
+		return errors.New("该令牌额度已用尽 TokenStatusExhausted[sk-" + token.KeyPrefix + "***]")
+	} else if token.Status == common.TokenStatusExpired {
+		return errors.New("该令牌已过期")
+	}
+	if token.Status != common.TokenStatusEnabled {
+		return errors.New("该令牌状态不可用")
+	}
+	if token.ExpiredTime != -1 && token.ExpiredTime < common.GetTimestamp() {
+		if !common.RedisEnabled {
+			token.Status = common.TokenStatusExpired
+			err := token.SelectUpdate()
+			if err != nil {
+				common.SysError("failed to update token status" + err.Error())
 			}
-			return nil, errors.New("该令牌已过期")
 		}
-		if !token.UnlimitedQuota && token.RemainQuota <= 0 {
-			if !common.RedisEnabled {
-				// in this case, we can make sure the token is exhausted
-				token.Status = common.TokenStatusExhausted
-				err := token.SelectUpdate()
-				if err != nil {
-					common.SysError("failed to update token status" + err.Error())
-				}
+		return errors.New("该令牌已过期")
+	}
+	if !token.UnlimitedQuota && token.RemainQuota <= 0 {
+		if !common.RedisEnabled {
+			// in this case, we can make sure the token is exhausted
+			token.Status = common.TokenStatusExhausted
+			err := token.SelectUpdate()
+			if err != nil {
+				common.SysError("failed to update token status" + err.Error())
 			}
-			keyPrefix := key[:3]
-			keySuffix := key[len(key)-3:]
-			return nil, errors.New(fmt.Sprintf("[sk-%s***%s] 该令牌额度已用尽 !token.UnlimitedQuota && token.RemainQuota = %d", keyPrefix, keySuffix, token.RemainQuota))
 		}
-		return token, nil
+		return errors.New(fmt.Sprintf("[sk-%s***] 该令牌额度已用尽 !token.UnlimitedQuota && token.RemainQuota = %d", token.KeyPrefix, token.RemainQuota))
 	}
-	return nil, errors.New("无效的令牌")
+	return nil
 }
 
 func GetTokenByIds(id int, userId int) (*Token, error) {
@@ -112,17 +132,20 @@ func GetTokenById(id int) (*Token, error) {
 }
 
 func GetTokenByKey(key string) (*Token, error) {
-	keyCol := "`key`"
-	if common.UsingPostgreSQL {
-		keyCol = `"key"`
-	}
+	return getTokenByKeyHash(common.HashTokenKey(key))
+}
+
+func getTokenByKeyHash(keyHash string) (*Token, error) {
 	var token Token
-	err := DB.Where(keyCol+" = ?", key).First(&token).Error
+	err := DB.Where("key_hash = ?", keyHash).First(&token).Error
 	return &token, err
 }
 
+// Insert stores the token by the hash of its key, the plain key stays on the struct for the one-time reveal
 func (token *Token) Insert() error {
 	var err error
+	token.KeyHash = common.HashTokenKey(token.Key)
+	token.KeyPrefix = common.TokenKeyPrefix(token.Key)
 	err = DB.Create(token).Error
 	return err
 }
@@ -130,7 +153,7 @@ func (token *Token) Insert() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (token *Token) Update() error {
 	var err error
-	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "model_limits_enabled", "model_limits").Updates(token).Error
+	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "model_limits_enabled", "model_limits", "rpm_limit", "tpm_limit", "concurrency_limit", "allow_ips", "scopes").Updates(token).Error
 	return err
 }
 
@@ -156,6 +179,26 @@ func (token *Token) GetModelLimits() []string {
 	return strings.Split(token.ModelLimits, ",")
 }
 
+// IsIpAllowed checks the client ip against the allow list of the token, an unparsable list denies every ip
+func (token *Token) IsIpAllowed(ip string) bool {
+	if token.AllowIps == "" {
+		return true
+	}
+	nets, err := common.ParseIpAllowList(token.AllowIps)
+	if err != nil {
+		return false
+	}
+	return len(nets) == 0 || common.IsIpInList(ip, nets)
+}
+
+// GetScopes returns the api surfaces the token may call, an empty list allows all of them
+func (token *Token) GetScopes() []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
 func (token *Token) GetModelLimitsMap() map[string]bool {
 	limits := token.GetModelLimits()
 	limitsMap := make(map[string]bool)
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"one-api/common"
+	"strings"
+)
+
+const tokenHashSecretOption = "TokenHashSecret"
+
+// initTokenHashSecret loads the salt of the api key hashes, the first node to start generates it
+func initTokenHashSecret() error {
+	var option Option
+	err := DB.Where(&Option{Key: tokenHashSecretOption}).First(&option).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		option.Key = tokenHashSecretOption
+		option.Value, err = common.GenerateTokenHashSecret()
+		if err != nil {
+			return err
+		}
+		err = DB.Create(&option).Error
+		if err != nil {
+			// another node created it first
+			err = DB.Where(&Option{Key: tokenHashSecretOption}).First(&option).Error
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if option.Value == "" {
+		return errors.New("empty token hash secret")
+	}
+	common.TokenHashSecret = option.Value
+	return nil
+}
+
+// migrateTokenKeys hashes the keys of the tokens created before keys were hashed, and drops their plain keys
+func migrateTokenKeys() error {
+	// HasColumn is fooled by "PRIMARY KEY" on sqlite, the column types are exact
+	columnTypes, err := DB.Migrator().ColumnTypes(&Token{})
+	if err != nil {
+		return err
+	}
+	hasKeyColumn := false
+	for _, columnType := range columnTypes {
+		if columnType.Name() == "key" {
+			hasKeyColumn = true
+		}
+	}
+	if !hasKeyColumn {
+		return nil
+	}
+	keyCol := "`key`"
+	if common.UsingPostgreSQL {
+		keyCol = `"key"`
+	}
+	var rows []struct {
+		Id  int
+		Key string
+	}
+	err = DB.Table("tokens").Select("id, " + keyCol).Where(keyCol + " IS NOT NULL AND " + keyCol + " <> ''").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		key := strings.TrimSpace(row.Key)
+		keyHash := common.HashTokenKey(key)
+		// the key hashes are unique, keys that only differ by spaces would break the index
+		var duplicate struct{ Id int }
+		err = DB.Table("tokens").Select("id").Where("key_hash = ? AND id <> ?", keyHash, row.Id).Limit(1).Scan(&duplicate).Error
+		if err != nil {
+			return err
+		}
+		if duplicate.Id != 0 {
+			return fmt.Errorf("token %d has the same key as token %d, delete one of them to migrate the keys", row.Id, duplicate.Id)
+		}
+		err = DB.Table("tokens").Where("id = ?", row.Id).Updates(map[string]any{
+			"key_hash":   keyHash,
+			"key_prefix": common.TokenKeyPrefix(key),
+			"key":        nil,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to migrate the key of token %d: %w", row.Id, err)
+		}
+	}
+	if len(rows) > 0 {
+		common.SysLog(fmt.Sprintf("hashed the keys of %d tokens", len(rows)))
+	}
+	return nil
+}
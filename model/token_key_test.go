@@ -0,0 +1,105 @@
+package model
+
+import (
+	"database/sql"
+	"one-api/common"
+	"testing"
+)
+
+func TestInitTokenHashSecret(t *testing.T) {
+	setupTestDB(t, &Option{})
+	previous := common.TokenHashSecret
+	defer func() { common.TokenHashSecret = previous }()
+	if err := initTokenHashSecret(); err != nil {
+		t.Fatal(err)
+	}
+	secret := common.TokenHashSecret
+	if len(secret) != 64 {
+		t.Fatalf("generated secret %q, want 32 hex bytes", secret)
+	}
+	common.TokenHashSecret = ""
+	if err := initTokenHashSecret(); err != nil {
+		t.Fatal(err)
+	}
+	if common.TokenHashSecret != secret {
+		t.Error("the stored secret was not reused")
+	}
+}
+
+func TestMigrateTokenKeys(t *testing.T) {
+	setupTestDB(t, &Token{})
+	previous := common.TokenHashSecret
+	defer func() { common.TokenHashSecret = previous }()
+	common.TokenHashSecret = "test-secret"
+	if err := migrateTokenKeys(); err != nil {
+		t.Fatalf("migration without a key column: %v", err)
+	}
+	// the key column of the tokens created before keys were hashed
+	if err := DB.Exec("ALTER TABLE tokens ADD COLUMN `key` char(48)").Error; err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		key        sql.NullString
+		hash       sql.NullString
+		prefix     string
+		wantHash   string
+		wantPrefix string
+	}{
+		{"legacy key", sql.NullString{String: "legacykey0123456789", Valid: true}, sql.NullString{}, "", common.HashTokenKey("legacykey0123456789"), "legacyke"},
+		{"legacy key with spaces", sql.NullString{String: " spacedkey0123456789 ", Valid: true}, sql.NullString{}, "", common.HashTokenKey("spacedkey0123456789"), "spacedke"},
+		{"already hashed", sql.NullString{}, sql.NullString{String: "existing-hash", Valid: true}, "existing", "existing-hash", "existing"},
+		{"empty key", sql.NullString{String: "", Valid: true}, sql.NullString{}, "", "", ""},
+	}
+	for i, tt := range tests {
+		err := DB.Exec("INSERT INTO tokens (id, user_id, name, `key`, key_hash, key_prefix) VALUES (?, 1, ?, ?, ?, ?)",
+			i+1, tt.name, tt.key, tt.hash, tt.prefix).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrateTokenKeys(); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var row struct {
+				Key       sql.NullString
+				KeyHash   sql.NullString
+				KeyPrefix string
+			}
+			err := DB.Table("tokens").Select("`key`, key_hash, key_prefix").Where("id = ?", i+1).Scan(&row).Error
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row.KeyHash.String != tt.wantHash || row.KeyPrefix != tt.wantPrefix {
+				t.Errorf("hash %q prefix %q, want %q %q", row.KeyHash.String, row.KeyPrefix, tt.wantHash, tt.wantPrefix)
+			}
+			if tt.key.String != "" && row.Key.Valid {
+				t.Errorf("the plain key %q was kept", row.Key.String)
+			}
+		})
+	}
+	token, err := GetTokenByKey("legacykey0123456789")
+	if err != nil || token.Id != 1 {
+		t.Errorf("GetTokenByKey found token %d, %v, want token 1", token.Id, err)
+	}
+}
+
+func TestMigrateTokenKeysDuplicate(t *testing.T) {
+	setupTestDB(t, &Token{})
+	previous := common.TokenHashSecret
+	defer func() { common.TokenHashSecret = previous }()
+	common.TokenHashSecret = "test-secret"
+	if err := DB.Exec("ALTER TABLE tokens ADD COLUMN `key` char(48)").Error; err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"samekey0123456789", "samekey0123456789 "} {
+		if err := DB.Exec("INSERT INTO tokens (id, user_id, name, `key`) VALUES (?, 1, 't', ?)", i+1, key).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrateTokenKeys(); err == nil {
+		t.Error("keys with the same hash were migrated")
+	}
+}
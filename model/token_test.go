@@ -0,0 +1,61 @@
+package model
+
+import "testing"
+
+func TestTokenIsIpAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowIps string
+		ip       string
+		want     bool
+	}{
+		{"empty list allows any ip", "", "203.0.113.7", true},
+		{"listed ip", "203.0.113.7", "203.0.113.7", true},
+		{"unlisted ip", "203.0.113.7", "203.0.113.8", false},
+		{"cidr", "203.0.113.0/24", "203.0.113.200", true},
+		{"ipv6 cidr", "2001:db8::/32", "2001:db8::42", true},
+		{"unparsable list denies", "203.0.113.7,bogus", "203.0.113.7", false},
+		{"blank list allows any ip", " , ", "198.51.100.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &Token{AllowIps: tt.allowIps}
+			if got := token.IsIpAllowed(tt.ip); got != tt.want {
+				t.Errorf("IsIpAllowed(%q) with %q = %v, want %v", tt.ip, tt.allowIps, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchUserTokens(t *testing.T) {
+	setupTestDB(t, &Token{})
+	keys := []string{"skskabcdefghijklmnopqrstuvwxyz0123456789ABCDEks", "abcdefgh_ijklmnopqrstuvwxyz0123456789ABCDEFGHI", "abcdefghXijklmnopqrstuvwxyz0123456789ABCDEFGHI"}
+	for _, key := range keys {
+		if err := (&Token{UserId: 1, Name: "t", Key: key}).Insert(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"full key starting and ending with s and k", "sk-" + keys[0], 1},
+		{"full key without sk-", keys[0], 1},
+		{"prefix", "sk-abcdefgh", 2},
+		{"underscore is not a wildcard", "abcdefg_", 0},
+		{"percent is not a wildcard", "%", 0},
+		{"no key", "", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := SearchUserTokens(1, "", tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tokens) != tt.want {
+				t.Errorf("SearchUserTokens(%q) found %d tokens, want %d", tt.token, len(tokens), tt.want)
+			}
+		})
+	}
+}
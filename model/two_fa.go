@@ -0,0 +1,223 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TwoFA holds the totp secret of a user, it only protects the account once enabled,
+// the recovery codes are stored hashed and each of them works once
+type TwoFA struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"uniqueIndex"`
+	Secret        string `json:"-" gorm:"type:varchar(255)"` // sealed with the master key if one is configured
+	Enabled       bool   `json:"enabled"`
+	RecoveryCodes string `json:"-" gorm:"type:text"`
+	LastUsedStep  int64  `json:"-" gorm:"bigint"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint"`
+}
+
+const twoFARecoveryCodeCount = 10
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetTwoFAByUserId returns the 2fa of the user, nil if the user never set it up
+func GetTwoFAByUserId(userId int) (*TwoFA, error) {
+	twoFA := TwoFA{}
+	err := DB.Where("user_id = ?", userId).First(&twoFA).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &twoFA, nil
+}
+
+func IsTwoFAEnabled(userId int) bool {
+	twoFA, err := GetTwoFAByUserId(userId)
+	return err == nil && twoFA != nil && twoFA.Enabled
+}
+
+// IsTwoFARequired reports whether users of the role have to enable 2fa
+func IsTwoFARequired(role int) bool {
+	return common.TwoFARequiredRole > 0 && role >= common.TwoFARequiredRole
+}
+
+// SetupTwoFA stores a new secret for the user, 2fa stays disabled until a code of it is verified
+func SetupTwoFA(userId int) (*TwoFA, error) {
+	twoFA, err := GetTwoFAByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFA != nil && twoFA.Enabled {
+		return nil, errors.New("两步验证已启用")
+	}
+	secret, err := common.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	secret, err = common.SealSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if twoFA == nil {
+		twoFA = &TwoFA{UserId: userId, Secret: secret, CreatedTime: common.GetTimestamp()}
+		err = DB.Create(twoFA).Error
+	} else {
+		twoFA.Secret = secret
+		twoFA.CreatedTime = common.GetTimestamp()
+		err = DB.Model(twoFA).Select("secret", "created_time").Updates(twoFA).Error
+	}
+	return twoFA, err
+}
+
+// Enable turns 2fa on once the code proves the secret was enrolled, and returns new recovery codes
+func (twoFA *TwoFA) Enable(code string) ([]string, error) {
+	if twoFA.Enabled {
+		return nil, errors.New("两步验证已启用")
+	}
+	if !twoFA.verifyTOTP(code) {
+		return nil, errors.New("验证码错误或已过期")
+	}
+	twoFA.Enabled = true
+	err := DB.Model(twoFA).Select("enabled").Updates(twoFA).Error
+	if err != nil {
+		return nil, err
+	}
+	return twoFA.RegenerateRecoveryCodes()
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, the plain codes are only returned here
+func (twoFA *TwoFA) RegenerateRecoveryCodes() ([]string, error) {
+	codes, err := common.GenerateRecoveryCodes(twoFARecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	twoFA.RecoveryCodes = strings.Join(hashes, ",")
+	err = DB.Model(twoFA).Select("recovery_codes").Updates(twoFA).Error
+	return codes, err
+}
+
+func (twoFA *TwoFA) RemainingRecoveryCodes() int {
+	if twoFA.RecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(twoFA.RecoveryCodes, ","))
+}
+
+// GetSecret returns the totp secret, decrypted if it is stored encrypted
+func (twoFA *TwoFA) GetSecret() (string, error) {
+	return common.OpenSecret(twoFA.Secret)
+}
+
+func (twoFA *TwoFA) verifyTOTP(code string) bool {
+	secret, err := twoFA.GetSecret()
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to decrypt the 2fa secret of user #%d: %s", twoFA.UserId, err.Error()))
+		return false
+	}
+	step, ok := common.ValidateTOTP(secret, code)
+	if !ok {
+		return false
+	}
+	// the step is compared in the database so concurrent nodes cannot accept the same code either
+	result := DB.Model(&TwoFA{}).Where("id = ? and last_used_step < ?", twoFA.Id, step).Update("last_used_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	twoFA.LastUsedStep = step
+	return true
+}
+
+func (twoFA *TwoFA) useRecoveryCode(code string) bool {
+	hash := hashRecoveryCode(code)
+	hashes := strings.Split(twoFA.RecoveryCodes, ",")
+	for i, h := range hashes {
+		if h == "" || h != hash {
+			continue
+		}
+		remaining := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		// compared in the database as well, so the same code cannot be used by two concurrent requests
+		result := DB.Model(&TwoFA{}).Where("id = ? and recovery_codes = ?", twoFA.Id, twoFA.RecoveryCodes).Update("recovery_codes", remaining)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false
+		}
+		twoFA.RecoveryCodes = remaining
+		return true
+	}
+	return false
+}
+
+// Verify checks a totp code or, failing that, consumes a recovery code
+func (twoFA *TwoFA) Verify(code string) bool {
+	if !twoFA.Enabled || code == "" {
+		return false
+	}
+	if twoFA.verifyTOTP(code) {
+		return true
+	}
+	return twoFA.useRecoveryCode(code)
+}
+
+func DisableTwoFA(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&TwoFA{}).Error
+}
+
+// checkTwoFASecrets makes sure every encrypted 2fa secret can be decrypted with the configured master keys
+func checkTwoFASecrets() error {
+	var twoFAs []*TwoFA
+	err := DB.Select("id", "user_id", "secret").Find(&twoFAs).Error
+	if err != nil {
+		return err
+	}
+	for _, twoFA := range twoFAs {
+		_, err = twoFA.GetSecret()
+		if err != nil {
+			return fmt.Errorf("failed to decrypt the 2fa secret of user #%d, check CHANNEL_KEY_SECRET and CHANNEL_KEY_OLD_SECRET: %w", twoFA.UserId, err)
+		}
+	}
+	return nil
+}
+
+// ReencryptTwoFASecrets brings the 2fa secrets up to the current master key as ReencryptChannelKeys does
+// with the channel keys, it returns how many secrets were changed
+func ReencryptTwoFASecrets() (int, error) {
+	if !common.MasterKeyEnabled() {
+		return 0, errors.New("未配置渠道密钥的主密钥 CHANNEL_KEY_SECRET")
+	}
+	var twoFAs []*TwoFA
+	err := DB.Select("id", "user_id", "secret").Find(&twoFAs).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, twoFA := range twoFAs {
+		if !common.NeedsResealing(twoFA.Secret) {
+			continue
+		}
+		secret, err := common.ResealSecret(twoFA.Secret)
+		if err != nil {
+			return count, fmt.Errorf("用户 #%d 的两步验证密钥解密失败：%s", twoFA.UserId, err.Error())
+		}
+		err = DB.Model(&TwoFA{}).Where("id = ?", twoFA.Id).Update("secret", secret).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"one-api/common"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// currentTOTPCode computes the code of the secret for the current step moved by offset, the way authenticator apps do
+func currentTOTPCode(t *testing.T, twoFA *TwoFA, offset int64) string {
+	t.Helper()
+	secret, err := twoFA.GetSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(time.Now().Unix()/30+offset))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	index := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[index:index+4])&0x7fffffff)%1000000)
+}
+
+func setupEnabledTwoFA(t *testing.T) (*TwoFA, []string) {
+	t.Helper()
+	setupTestDB(t, &TwoFA{})
+	twoFA, err := SetupTwoFA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := twoFA.Enable(currentTOTPCode(t, twoFA, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return twoFA, codes
+}
+
+func TestTwoFAVerifyTOTP(t *testing.T) {
+	twoFA, _ := setupEnabledTwoFA(t)
+	if time.Now().Unix()/30 != twoFA.LastUsedStep {
+		t.Skip("the totp step changed since 2fa was enabled")
+	}
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"code used to enable cannot be replayed", currentTOTPCode(t, twoFA, 0), false},
+		{"next step", currentTOTPCode(t, twoFA, 1), true},
+		{"next step replayed", currentTOTPCode(t, twoFA, 1), false},
+		{"earlier step than the last used one", currentTOTPCode(t, twoFA, -1), false},
+		{"empty code", "", false},
+		{"wrong code", "000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "wrong code" {
+				for offset := int64(-1); offset <= 1; offset++ {
+					if currentTOTPCode(t, twoFA, offset) == tt.code {
+						t.Skip("code collides with a valid step")
+					}
+				}
+			}
+			if got := twoFA.Verify(tt.code); got != tt.want {
+				t.Errorf("Verify(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwoFARecoveryCodes(t *testing.T) {
+	twoFA, codes := setupEnabledTwoFA(t)
+	if len(codes) != twoFARecoveryCodeCount || twoFA.RemainingRecoveryCodes() != twoFARecoveryCodeCount {
+		t.Fatalf("got %d recovery codes, %d stored, want %d", len(codes), twoFA.RemainingRecoveryCodes(), twoFARecoveryCodeCount)
+	}
+	// a second copy loaded before the code is used, as a concurrent request would have it
+	stale, err := GetTwoFAByUserId(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		twoFA *TwoFA
+		code  string
+		want  bool
+	}{
+		{"upper case and spaces", twoFA, "  " + strings.ToUpper(codes[0]) + " ", true},
+		{"used code", twoFA, codes[0], false},
+		{"used code on a stale copy", stale, codes[0], false},
+		{"unknown code", twoFA, "aaaaa-aaaaa", false},
+		{"another code", twoFA, codes[1], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.twoFA.Verify(tt.code); got != tt.want {
+				t.Errorf("Verify(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+	stored, err := GetTwoFAByUserId(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RemainingRecoveryCodes() != twoFARecoveryCodeCount-2 {
+		t.Errorf("%d recovery codes remain, want %d", stored.RemainingRecoveryCodes(), twoFARecoveryCodeCount-2)
+	}
+}
+
+func TestTwoFASealedSecret(t *testing.T) {
+	t.Setenv("CHANNEL_KEY_SECRET", "secret-a")
+	if err := common.InitMasterKeys(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Unsetenv("CHANNEL_KEY_SECRET")
+		common.InitMasterKeys()
+	})
+	twoFA, _ := setupEnabledTwoFA(t)
+	stored, err := GetTwoFAByUserId(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := stored.GetSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !common.IsSealed(stored.Secret) || strings.Contains(stored.Secret, secret) {
+		t.Errorf("the secret is stored as %q", stored.Secret)
+	}
+	if !twoFA.Enabled || checkTwoFASecrets() != nil {
+		t.Error("2fa with a sealed secret did not enable or check")
+	}
+	// a secret stored before the master key was configured is sealed by the re-encryption
+	DB.Model(&TwoFA{}).Where("id = ?", twoFA.Id).Update("secret", secret)
+	if count, err := ReencryptTwoFASecrets(); count != 1 || err != nil {
+		t.Errorf("ReencryptTwoFASecrets = %d, %v", count, err)
+	}
+	stored, _ = GetTwoFAByUserId(1)
+	if plain, err := stored.GetSecret(); !common.IsSealed(stored.Secret) || plain != secret || err != nil {
+		t.Errorf("re-encrypted secret %q opens to %q, %v", stored.Secret, plain, err)
+	}
+}
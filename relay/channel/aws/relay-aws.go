@@ -13,6 +13,7 @@ import (
 	relaymodel "one-api/dto"
 	"one-api/relay/channel/claude"
 	relaycommon "one-api/relay/common"
+	"one-api/service"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -29,10 +30,18 @@ func newAwsClient(c *gin.Context, info *relaycommon.RelayInfo) (*bedrockruntime.
 	ak := awsSecret[0]
 	sk := awsSecret[1]
 	region := awsSecret[2]
-	client := bedrockruntime.New(bedrockruntime.Options{
+	options := bedrockruntime.Options{
 		Region:      region,
 		Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(ak, sk, "")),
-	})
+	}
+	if proxy := c.GetString("proxy"); proxy != "" {
+		httpClient, err := service.GetHttpClientWithProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options.HTTPClient = httpClient
+	}
+	client := bedrockruntime.New(options)
 
 	return client, nil
 }
@@ -157,6 +166,7 @@ func awsStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, requestMode i
 	var id string
 	var model string
 	createdTime := common.GetTimestamp()
+	toolCallIndexes := make(map[int]int)
 	c.Stream(func(w io.Writer) bool {
 		event, ok := <-stream.Events()
 		if !ok {
@@ -173,7 +183,7 @@ func awsStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, requestMode i
 				return false
 			}
 
-			response, claudeUsage := claude.StreamResponseClaude2OpenAI(requestMode, claudeResp)
+			response, claudeUsage := claude.StreamResponseClaude2OpenAI(requestMode, claudeResp, toolCallIndexes)
 			if claudeUsage != nil {
 				usage.PromptTokens += claudeUsage.InputTokens
 				usage.CompletionTokens += claudeUsage.OutputTokens
@@ -55,7 +55,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	}
 	var accessToken string
 	var err error
-	if accessToken, err = getBaiduAccessToken(info.ApiKey); err != nil {
+	if accessToken, err = getBaiduAccessToken(info.ApiKey, info.Proxy); err != nil {
 		return "", err
 	}
 	fullRequestURL += "?access_token=" + accessToken
@@ -45,6 +45,9 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Request, info *re
 		anthropicVersion = "2023-06-01"
 	}
 	req.Header.Set("anthropic-version", anthropicVersion)
+	if anthropicBeta := c.Request.Header.Get("anthropic-beta"); anthropicBeta != "" {
+		req.Header.Set("anthropic-beta", anthropicBeta)
+	}
 	return nil
 }
 
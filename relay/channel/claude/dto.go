@@ -5,11 +5,20 @@ type ClaudeMetadata struct {
 }
 
 type ClaudeMediaMessage struct {
-	Type       string               `json:"type"`
-	Text       string               `json:"text,omitempty"`
-	Source     *ClaudeMessageSource `json:"source,omitempty"`
-	Usage      *ClaudeUsage         `json:"usage,omitempty"`
-	StopReason *string              `json:"stop_reason,omitempty"`
+	Type        string               `json:"type"`
+	Text        string               `json:"text,omitempty"`
+	Source      *ClaudeMessageSource `json:"source,omitempty"`
+	Usage       *ClaudeUsage         `json:"usage,omitempty"`
+	StopReason  *string              `json:"stop_reason,omitempty"`
+	PartialJson string               `json:"partial_json,omitempty"`
+	// tool_use
+	Id    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Input any    `json:"input,omitempty"`
+	// tool_result
+	ToolUseId string `json:"tool_use_id,omitempty"`
+	Content   any    `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
 }
 
 type ClaudeMessageSource struct {
@@ -18,6 +27,17 @@ type ClaudeMessageSource struct {
 	Data      string `json:"data"`
 }
 
+type ClaudeTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type ClaudeToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
 type ClaudeMessage struct {
 	Role    string `json:"role"`
 	Content any    `json:"content"`
@@ -26,7 +46,7 @@ type ClaudeMessage struct {
 type ClaudeRequest struct {
 	Model             string          `json:"model"`
 	Prompt            string          `json:"prompt,omitempty"`
-	System            string          `json:"system,omitempty"`
+	System            any             `json:"system,omitempty"`
 	Messages          []ClaudeMessage `json:"messages,omitempty"`
 	MaxTokens         uint            `json:"max_tokens,omitempty"`
 	MaxTokensToSample uint            `json:"max_tokens_to_sample,omitempty"`
@@ -35,7 +55,9 @@ type ClaudeRequest struct {
 	TopP              float64         `json:"top_p,omitempty"`
 	TopK              int             `json:"top_k,omitempty"`
 	//ClaudeMetadata    `json:"metadata,omitempty"`
-	Stream bool `json:"stream,omitempty"`
+	Stream     bool         `json:"stream,omitempty"`
+	Tools      []ClaudeTool `json:"tools,omitempty"`
+	ToolChoice any          `json:"tool_choice,omitempty"`
 }
 
 type ClaudeError struct {
@@ -44,17 +66,18 @@ type ClaudeError struct {
 }
 
 type ClaudeResponse struct {
-	Id         string               `json:"id"`
-	Type       string               `json:"type"`
-	Content    []ClaudeMediaMessage `json:"content"`
-	Completion string               `json:"completion"`
-	StopReason string               `json:"stop_reason"`
-	Model      string               `json:"model"`
-	Error      ClaudeError          `json:"error"`
-	Usage      ClaudeUsage          `json:"usage"`
-	Index      int                  `json:"index"`   // stream only
-	Delta      *ClaudeMediaMessage  `json:"delta"`   // stream only
-	Message    *ClaudeResponse      `json:"message"` // stream only: message_start
+	Id           string               `json:"id"`
+	Type         string               `json:"type"`
+	Content      []ClaudeMediaMessage `json:"content"`
+	Completion   string               `json:"completion"`
+	StopReason   string               `json:"stop_reason"`
+	Model        string               `json:"model"`
+	Error        ClaudeError          `json:"error"`
+	Usage        ClaudeUsage          `json:"usage"`
+	Index        int                  `json:"index"`         // stream only
+	ContentBlock *ClaudeMediaMessage  `json:"content_block"` // stream only: content_block_start
+	Delta        *ClaudeMediaMessage  `json:"delta"`         // stream only
+	Message      *ClaudeResponse      `json:"message"`       // stream only: message_start
 }
 
 //type ClaudeResponseChoice struct {
@@ -66,3 +89,45 @@ type ClaudeUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
 }
+
+// ClaudeMessageResponse is the Messages API body returned to inbound Claude clients
+type ClaudeMessageResponse struct {
+	Id           string               `json:"id"`
+	Type         string               `json:"type"`
+	Role         string               `json:"role"`
+	Content      []ClaudeContentBlock `json:"content"`
+	Model        string               `json:"model"`
+	StopReason   *string              `json:"stop_reason"`
+	StopSequence *string              `json:"stop_sequence"`
+	Usage        ClaudeUsage          `json:"usage"`
+}
+
+type ClaudeContentBlock struct {
+	Type  string  `json:"type"`
+	Text  *string `json:"text,omitempty"`
+	Id    string  `json:"id,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	Input any     `json:"input,omitempty"`
+}
+
+type ClaudeStreamEvent struct {
+	Type         string                 `json:"type"`
+	Message      *ClaudeMessageResponse `json:"message,omitempty"`
+	Index        *int                   `json:"index,omitempty"`
+	ContentBlock *ClaudeContentBlock    `json:"content_block,omitempty"`
+	Delta        *ClaudeStreamDelta     `json:"delta,omitempty"`
+	Usage        *ClaudeUsage           `json:"usage,omitempty"`
+}
+
+type ClaudeStreamDelta struct {
+	Type         string  `json:"type,omitempty"`
+	Text         string  `json:"text,omitempty"`
+	PartialJson  *string `json:"partial_json,omitempty"`
+	StopReason   *string `json:"stop_reason,omitempty"`
+	StopSequence *string `json:"stop_sequence,omitempty"`
+}
+
+type ClaudeErrorResponse struct {
+	Type  string      `json:"type"`
+	Error ClaudeError `json:"error"`
+}
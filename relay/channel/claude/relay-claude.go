@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/dto"
+	relaycommon "one-api/relay/common"
 	"one-api/service"
 	"strings"
 )
@@ -21,6 +22,8 @@ func stopReasonClaude2OpenAI(reason string) string {
 		return "stop"
 	case "max_tokens":
 		return "max_tokens"
+	case "tool_use":
+		return "tool_calls"
 	default:
 		return reason
 	}
@@ -56,6 +59,27 @@ func RequestOpenAI2ClaudeComplete(textRequest dto.GeneralOpenAIRequest) *ClaudeR
 	return &claudeRequest
 }
 
+// toolChoiceOpenAI2Claude maps the OpenAI tool_choice, "none" has no Claude
+// equivalent and is handled by not sending any tools
+func toolChoiceOpenAI2Claude(toolChoice any) *ClaudeToolChoice {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "auto":
+			return &ClaudeToolChoice{Type: "auto"}
+		case "required":
+			return &ClaudeToolChoice{Type: "any"}
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				return &ClaudeToolChoice{Type: "tool", Name: name}
+			}
+		}
+	}
+	return nil
+}
+
 func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeRequest, error) {
 	claudeRequest := ClaudeRequest{
 		Model:         textRequest.Model,
@@ -69,6 +93,22 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 	if claudeRequest.MaxTokens == 0 {
 		claudeRequest.MaxTokens = 4096
 	}
+	if textRequest.ToolChoice != "none" {
+		for _, tool := range textRequest.ParseTools() {
+			inputSchema := tool.Function.Parameters
+			if inputSchema == nil {
+				inputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			claudeRequest.Tools = append(claudeRequest.Tools, ClaudeTool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: inputSchema,
+			})
+		}
+		if toolChoice := toolChoiceOpenAI2Claude(textRequest.ToolChoice); toolChoice != nil && len(claudeRequest.Tools) > 0 {
+			claudeRequest.ToolChoice = toolChoice
+		}
+	}
 	formatMessages := make([]dto.Message, 0)
 	var lastMessage *dto.Message
 	for i, message := range textRequest.Messages {
@@ -81,10 +121,14 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 			textRequest.Messages[i].Role = "user"
 		}
 		fmtMessage := dto.Message{
-			Role:    message.Role,
-			Content: message.Content,
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCalls:  message.ToolCalls,
+			ToolCallId: message.ToolCallId,
 		}
-		if lastMessage != nil && lastMessage.Role == message.Role {
+		// tool calls and tool results are merged as content blocks below
+		if lastMessage != nil && lastMessage.Role == message.Role && message.Role != "tool" &&
+			lastMessage.ToolCalls == nil && message.ToolCalls == nil {
 			if lastMessage.IsStringContent() && message.IsStringContent() {
 				content, _ := json.Marshal(strings.Trim(fmt.Sprintf("%s %s", lastMessage.StringContent(), message.StringContent()), "\""))
 				fmtMessage.Content = content
@@ -92,7 +136,7 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 				formatMessages = formatMessages[:len(formatMessages)-1]
 			}
 		}
-		if fmtMessage.Content == nil {
+		if fmtMessage.Content == nil && fmtMessage.ToolCalls == nil {
 			content, _ := json.Marshal("...")
 			fmtMessage.Content = content
 		}
@@ -119,7 +163,24 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 			claudeMessage := ClaudeMessage{
 				Role: message.Role,
 			}
-			if message.IsStringContent() {
+			if message.Role == "tool" {
+				// tool results are sent back to Claude as user content
+				toolResult := message.StringContent()
+				if !message.IsStringContent() {
+					toolResult = ""
+					for _, mediaMessage := range message.ParseContent() {
+						toolResult += mediaMessage.Text
+					}
+				}
+				claudeMessage.Role = "user"
+				claudeMessage.Content = []ClaudeMediaMessage{
+					{
+						Type:      "tool_result",
+						ToolUseId: message.ToolCallId,
+						Content:   toolResult,
+					},
+				}
+			} else if message.IsStringContent() && message.ToolCalls == nil {
 				claudeMessage.Content = message.StringContent()
 			} else {
 				claudeMediaMessages := make([]ClaudeMediaMessage, 0)
@@ -128,6 +189,9 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 						Type: mediaMessage.Type,
 					}
 					if mediaMessage.Type == "text" {
+						if mediaMessage.Text == "" {
+							continue
+						}
 						claudeMediaMessage.Text = mediaMessage.Text
 					} else {
 						imageUrl := mediaMessage.ImageUrl.(dto.MessageImageUrl)
@@ -152,8 +216,34 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 					}
 					claudeMediaMessages = append(claudeMediaMessages, claudeMediaMessage)
 				}
+				for _, toolCall := range message.ParseToolCalls() {
+					input := make(map[string]any)
+					if toolCall.Function.Arguments != "" {
+						_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &input)
+					}
+					claudeMediaMessages = append(claudeMediaMessages, ClaudeMediaMessage{
+						Type:  "tool_use",
+						Id:    toolCall.ID,
+						Name:  toolCall.Function.Name,
+						Input: input,
+					})
+				}
 				claudeMessage.Content = claudeMediaMessages
 			}
+			// Claude requires alternating roles, e.g. several tool results form one user message
+			if len(claudeMessages) > 0 && claudeMessages[len(claudeMessages)-1].Role == claudeMessage.Role {
+				lastClaudeMessage := &claudeMessages[len(claudeMessages)-1]
+				lastContents, err := ParseClaudeContent(lastClaudeMessage.Content)
+				if err != nil {
+					return nil, err
+				}
+				contents, err := ParseClaudeContent(claudeMessage.Content)
+				if err != nil {
+					return nil, err
+				}
+				lastClaudeMessage.Content = append(lastContents, contents...)
+				continue
+			}
 			claudeMessages = append(claudeMessages, claudeMessage)
 		}
 	}
@@ -162,7 +252,9 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*ClaudeR
 	return &claudeRequest, nil
 }
 
-func StreamResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) (*dto.ChatCompletionsStreamResponse, *ClaudeUsage) {
+// StreamResponseClaude2OpenAI converts a single Claude stream event, toolCallIndexes
+// maps the content block index of each tool_use block to its index in tool_calls
+func StreamResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse, toolCallIndexes map[int]int) (*dto.ChatCompletionsStreamResponse, *ClaudeUsage) {
 	var response dto.ChatCompletionsStreamResponse
 	var claudeUsage *ClaudeUsage
 	response.Object = "chat.completion.chunk"
@@ -183,10 +275,36 @@ func StreamResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) (*
 			choice.Delta.SetContentString("")
 			choice.Delta.Role = "assistant"
 		} else if claudeResponse.Type == "content_block_start" {
-			return nil, nil
+			if claudeResponse.ContentBlock == nil || claudeResponse.ContentBlock.Type != "tool_use" {
+				return nil, nil
+			}
+			toolIndex := len(toolCallIndexes)
+			toolCallIndexes[claudeResponse.Index] = toolIndex
+			choice.Delta.ToolCalls = []dto.ToolCall{
+				{
+					Index: &toolIndex,
+					ID:    claudeResponse.ContentBlock.Id,
+					Type:  "function",
+					Function: dto.FunctionCall{
+						Name: claudeResponse.ContentBlock.Name,
+					},
+				},
+			}
 		} else if claudeResponse.Type == "content_block_delta" {
-			choice.Index = claudeResponse.Index
-			choice.Delta.SetContentString(claudeResponse.Delta.Text)
+			if claudeResponse.Delta.Type == "input_json_delta" {
+				toolIndex := toolCallIndexes[claudeResponse.Index]
+				choice.Delta.ToolCalls = []dto.ToolCall{
+					{
+						Index: &toolIndex,
+						Function: dto.FunctionCall{
+							Arguments: claudeResponse.Delta.PartialJson,
+						},
+					},
+				}
+			} else {
+				choice.Index = claudeResponse.Index
+				choice.Delta.SetContentString(claudeResponse.Delta.Text)
+			}
 		} else if claudeResponse.Type == "message_delta" {
 			finishReason := stopReasonClaude2OpenAI(*claudeResponse.Delta.StopReason)
 			if finishReason != "null" {
@@ -228,18 +346,39 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *ClaudeResponse) *dto.Ope
 		choices = append(choices, choice)
 	} else {
 		fullTextResponse.Id = claudeResponse.Id
-		for i, message := range claudeResponse.Content {
-			content, _ := json.Marshal(message.Text)
-			choice := dto.OpenAITextResponseChoice{
-				Index: i,
-				Message: dto.Message{
-					Role:    "assistant",
-					Content: content,
-				},
-				FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
+		responseText := ""
+		var toolCalls []dto.ToolCall
+		for _, message := range claudeResponse.Content {
+			if message.Type == "tool_use" {
+				arguments, _ := json.Marshal(message.Input)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   message.Id,
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      message.Name,
+						Arguments: string(arguments),
+					},
+				})
+			} else {
+				responseText += message.Text
 			}
-			choices = append(choices, choice)
 		}
+		content, _ := json.Marshal(responseText)
+		choice := dto.OpenAITextResponseChoice{
+			Index: 0,
+			Message: dto.Message{
+				Role:    "assistant",
+				Content: content,
+			},
+			FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
+		}
+		if len(toolCalls) > 0 {
+			if responseText == "" {
+				choice.Message.Content = json.RawMessage("null")
+			}
+			choice.Message.ToolCalls = toolCalls
+		}
+		choices = append(choices, choice)
 	}
 
 	fullTextResponse.Choices = choices
@@ -265,6 +404,7 @@ func claudeStreamHandler(requestMode int, modelName string, promptTokens int, c
 		}
 		return 0, nil, nil
 	})
+	toolCallIndexes := make(map[int]int)
 	dataChan := make(chan string)
 	stopChan := make(chan bool)
 	go func() {
@@ -291,7 +431,7 @@ func claudeStreamHandler(requestMode int, modelName string, promptTokens int, c
 				return true
 			}
 
-			response, claudeUsage := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
+			response, claudeUsage := StreamResponseClaude2OpenAI(requestMode, &claudeResponse, toolCallIndexes)
 			if response == nil {
 				return true
 			}
@@ -304,8 +444,10 @@ func claudeStreamHandler(requestMode int, modelName string, promptTokens int, c
 					responseId = claudeResponse.Message.Id
 					modelName = claudeResponse.Message.Model
 					usage.PromptTokens = claudeUsage.InputTokens
+				} else if claudeResponse.Type == "content_block_start" {
+					// tool_use block start
 				} else if claudeResponse.Type == "content_block_delta" {
-					responseText += claudeResponse.Delta.Text
+					responseText += claudeResponse.Delta.Text + claudeResponse.Delta.PartialJson
 				} else if claudeResponse.Type == "message_delta" {
 					usage.CompletionTokens = claudeUsage.OutputTokens
 					usage.TotalTokens = claudeUsage.InputTokens + claudeUsage.OutputTokens
@@ -394,3 +536,512 @@ func claudeHandler(requestMode int, c *gin.Context, resp *http.Response, promptT
 	_, err = c.Writer.Write(jsonResponse)
 	return nil, &usage
 }
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case "stop":
+		return "end_turn"
+	case "length", "max_tokens":
+		return "max_tokens"
+	case "content_filter":
+		return "end_turn"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return reason
+	}
+}
+
+// ParseClaudeContent normalizes a Claude message content, which may be either
+// a plain string or an array of content blocks
+func ParseClaudeContent(content any) ([]ClaudeMediaMessage, error) {
+	if content == nil {
+		return nil, nil
+	}
+	if text, ok := content.(string); ok {
+		return []ClaudeMediaMessage{{Type: "text", Text: text}}, nil
+	}
+	jsonData, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	var mediaMessages []ClaudeMediaMessage
+	err = json.Unmarshal(jsonData, &mediaMessages)
+	if err != nil {
+		return nil, err
+	}
+	return mediaMessages, nil
+}
+
+// toolChoiceClaude2OpenAI maps the Claude tool_choice object to OpenAI
+func toolChoiceClaude2OpenAI(toolChoice any) any {
+	choice, ok := toolChoice.(map[string]any)
+	if !ok {
+		return nil
+	}
+	switch choice["type"] {
+	case "auto":
+		return "auto"
+	case "any":
+		return "required"
+	case "none":
+		return "none"
+	case "tool":
+		return map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name": choice["name"],
+			},
+		}
+	}
+	return nil
+}
+
+// toolResultText flattens the content of a tool_result block to text
+func toolResultText(content any) string {
+	contents, err := ParseClaudeContent(content)
+	if err != nil {
+		return ""
+	}
+	text := ""
+	for _, mediaMessage := range contents {
+		if mediaMessage.Type == "text" {
+			text += mediaMessage.Text
+		}
+	}
+	return text
+}
+
+// RequestClaude2OpenAI converts an inbound Claude Messages request to the
+// OpenAI chat format so that it can be served by any adaptor
+func RequestClaude2OpenAI(claudeRequest ClaudeRequest) (*dto.GeneralOpenAIRequest, error) {
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:       claudeRequest.Model,
+		MaxTokens:   claudeRequest.MaxTokens,
+		Temperature: claudeRequest.Temperature,
+		TopP:        claudeRequest.TopP,
+		TopK:        claudeRequest.TopK,
+		Stream:      claudeRequest.Stream,
+	}
+	if len(claudeRequest.StopSequences) > 0 {
+		openAIRequest.Stop = claudeRequest.StopSequences
+	}
+	if len(claudeRequest.Tools) > 0 {
+		tools := make([]dto.OpenAITools, 0, len(claudeRequest.Tools))
+		for _, tool := range claudeRequest.Tools {
+			tools = append(tools, dto.OpenAITools{
+				Type: "function",
+				Function: dto.OpenAIFunction{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.InputSchema,
+				},
+			})
+		}
+		openAIRequest.Tools = tools
+		openAIRequest.ToolChoice = toolChoiceClaude2OpenAI(claudeRequest.ToolChoice)
+	}
+	messages := make([]dto.Message, 0, len(claudeRequest.Messages)+1)
+	if claudeRequest.System != nil {
+		systemContents, err := ParseClaudeContent(claudeRequest.System)
+		if err != nil {
+			return nil, fmt.Errorf("invalid system: %w", err)
+		}
+		system := ""
+		for _, systemContent := range systemContents {
+			if systemContent.Type == "text" {
+				system += systemContent.Text
+			}
+		}
+		if system != "" {
+			content, _ := json.Marshal(system)
+			messages = append(messages, dto.Message{
+				Role:    "system",
+				Content: content,
+			})
+		}
+	}
+	for _, claudeMessage := range claudeRequest.Messages {
+		message := dto.Message{
+			Role: claudeMessage.Role,
+		}
+		if text, ok := claudeMessage.Content.(string); ok {
+			message.Content, _ = json.Marshal(text)
+			messages = append(messages, message)
+			continue
+		}
+		mediaMessages, err := ParseClaudeContent(claudeMessage.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message content: %w", err)
+		}
+		openaiContents := make([]dto.MediaMessage, 0, len(mediaMessages))
+		var toolCalls []dto.ToolCall
+		for _, mediaMessage := range mediaMessages {
+			switch mediaMessage.Type {
+			case "tool_use":
+				arguments, _ := json.Marshal(mediaMessage.Input)
+				toolCalls = append(toolCalls, dto.ToolCall{
+					ID:   mediaMessage.Id,
+					Type: "function",
+					Function: dto.FunctionCall{
+						Name:      mediaMessage.Name,
+						Arguments: string(arguments),
+					},
+				})
+			case "tool_result":
+				// tool results become tool messages, which must directly follow the tool calls
+				toolMessage := dto.Message{
+					Role:       "tool",
+					ToolCallId: mediaMessage.ToolUseId,
+				}
+				toolMessage.Content, _ = json.Marshal(toolResultText(mediaMessage.Content))
+				messages = append(messages, toolMessage)
+			case "text":
+				openaiContents = append(openaiContents, dto.MediaMessage{
+					Type: dto.ContentTypeText,
+					Text: mediaMessage.Text,
+				})
+			case "image":
+				if mediaMessage.Source == nil {
+					continue
+				}
+				openaiContents = append(openaiContents, dto.MediaMessage{
+					Type: dto.ContentTypeImageURL,
+					ImageUrl: dto.MessageImageUrl{
+						Url:    fmt.Sprintf("data:%s;base64,%s", mediaMessage.Source.MediaType, mediaMessage.Source.Data),
+						Detail: "auto",
+					},
+				})
+			}
+		}
+		if len(toolCalls) > 0 {
+			message.ToolCalls = toolCalls
+			if len(openaiContents) == 0 {
+				message.Content = json.RawMessage("null")
+				messages = append(messages, message)
+				continue
+			}
+		} else if len(openaiContents) == 0 {
+			continue
+		}
+		message.Content, _ = json.Marshal(openaiContents)
+		messages = append(messages, message)
+	}
+	openAIRequest.Messages = messages
+	return &openAIRequest, nil
+}
+
+// ResponseOpenAI2Claude converts an OpenAI chat completion into a Claude Messages response
+func ResponseOpenAI2Claude(openaiResponse *dto.TextResponse, model string) *ClaudeMessageResponse {
+	claudeResponse := ClaudeMessageResponse{
+		Id:      fmt.Sprintf("msg_%s", common.GetUUID()),
+		Type:    "message",
+		Role:    "assistant",
+		Content: make([]ClaudeContentBlock, 0),
+		Model:   model,
+		Usage: ClaudeUsage{
+			InputTokens:  openaiResponse.PromptTokens,
+			OutputTokens: openaiResponse.CompletionTokens,
+		},
+	}
+	stopReason := "end_turn"
+	if len(openaiResponse.Choices) > 0 {
+		choice := openaiResponse.Choices[0]
+		text := choice.Message.StringContent()
+		if text != "" && text != "null" {
+			claudeResponse.Content = append(claudeResponse.Content, ClaudeContentBlock{
+				Type: "text",
+				Text: &text,
+			})
+		}
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			input := make(map[string]any)
+			if toolCall.Function.Arguments != "" {
+				_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &input)
+			}
+			claudeResponse.Content = append(claudeResponse.Content, ClaudeContentBlock{
+				Type:  "tool_use",
+				Id:    toolCall.ID,
+				Name:  toolCall.Function.Name,
+				Input: input,
+			})
+		}
+		if choice.FinishReason != "" {
+			stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
+		}
+	}
+	claudeResponse.StopReason = &stopReason
+	return &claudeResponse
+}
+
+// StreamConverter turns OpenAI chat completion chunks into Claude Messages stream events
+type StreamConverter struct {
+	Id           string
+	Model        string
+	PromptTokens int
+	started      bool
+	blockOpen    bool
+	blockType    string
+	blockIndex   int
+	toolCallId   string
+	stopReason   string
+}
+
+func (s *StreamConverter) start() []ClaudeStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []ClaudeStreamEvent{
+		{
+			Type: "message_start",
+			Message: &ClaudeMessageResponse{
+				Id:      s.Id,
+				Type:    "message",
+				Role:    "assistant",
+				Content: make([]ClaudeContentBlock, 0),
+				Model:   s.Model,
+				Usage: ClaudeUsage{
+					InputTokens: s.PromptTokens,
+				},
+			},
+		},
+	}
+}
+
+func (s *StreamConverter) closeBlock() []ClaudeStreamEvent {
+	if !s.blockOpen {
+		return nil
+	}
+	s.blockOpen = false
+	s.blockType = ""
+	index := s.blockIndex
+	s.blockIndex++
+	return []ClaudeStreamEvent{
+		{
+			Type:  "content_block_stop",
+			Index: &index,
+		},
+	}
+}
+
+// Convert returns the Claude events produced by a single OpenAI stream chunk
+func (s *StreamConverter) Convert(openaiResponse *dto.ChatCompletionsStreamResponse) []ClaudeStreamEvent {
+	events := s.start()
+	for _, choice := range openaiResponse.Choices {
+		text := choice.Delta.GetContentString()
+		if text != "" {
+			if s.blockType != "text" {
+				events = append(events, s.closeBlock()...)
+			}
+			index := s.blockIndex
+			if !s.blockOpen {
+				s.blockOpen = true
+				s.blockType = "text"
+				emptyText := ""
+				events = append(events, ClaudeStreamEvent{
+					Type:  "content_block_start",
+					Index: &index,
+					ContentBlock: &ClaudeContentBlock{
+						Type: "text",
+						Text: &emptyText,
+					},
+				})
+			}
+			events = append(events, ClaudeStreamEvent{
+				Type:  "content_block_delta",
+				Index: &index,
+				Delta: &ClaudeStreamDelta{
+					Type: "text_delta",
+					Text: text,
+				},
+			})
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			// a tool call starts with its id, the following chunks only carry arguments
+			if s.blockType != "tool_use" || (toolCall.ID != "" && toolCall.ID != s.toolCallId) {
+				events = append(events, s.closeBlock()...)
+				s.blockOpen = true
+				s.blockType = "tool_use"
+				s.toolCallId = toolCall.ID
+				if s.toolCallId == "" {
+					s.toolCallId = fmt.Sprintf("toolu_%s", common.GetUUID())
+				}
+				index := s.blockIndex
+				events = append(events, ClaudeStreamEvent{
+					Type:  "content_block_start",
+					Index: &index,
+					ContentBlock: &ClaudeContentBlock{
+						Type:  "tool_use",
+						Id:    s.toolCallId,
+						Name:  toolCall.Function.Name,
+						Input: map[string]any{},
+					},
+				})
+			}
+			if toolCall.Function.Arguments != "" {
+				index := s.blockIndex
+				partialJson := toolCall.Function.Arguments
+				events = append(events, ClaudeStreamEvent{
+					Type:  "content_block_delta",
+					Index: &index,
+					Delta: &ClaudeStreamDelta{
+						Type:        "input_json_delta",
+						PartialJson: &partialJson,
+					},
+				})
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.stopReason = stopReasonOpenAI2Claude(*choice.FinishReason)
+		}
+	}
+	return events
+}
+
+// Finish closes the stream, reporting the final usage in message_delta
+func (s *StreamConverter) Finish(usage *dto.Usage) []ClaudeStreamEvent {
+	events := s.start()
+	events = append(events, s.closeBlock()...)
+	stopReason := s.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	messageDelta := ClaudeStreamEvent{
+		Type: "message_delta",
+		Delta: &ClaudeStreamDelta{
+			StopReason: &stopReason,
+		},
+		Usage: &ClaudeUsage{},
+	}
+	if usage != nil {
+		messageDelta.Usage.OutputTokens = usage.CompletionTokens
+	}
+	events = append(events, messageDelta, ClaudeStreamEvent{Type: "message_stop"})
+	return events
+}
+
+// ClaudeNativeStreamHandler relays a Claude Messages stream untouched and
+// collects usage from the message_start and message_delta events
+func ClaudeNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	usage := &dto.Usage{}
+	responseText := ""
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	service.SetEventStreamHeaders(c)
+	c.Writer.WriteHeader(resp.StatusCode)
+	for scanner.Scan() {
+		line := scanner.Text()
+		_, err := c.Writer.Write([]byte(line + "\n"))
+		if err != nil {
+			break
+		}
+		if line == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		var claudeResponse ClaudeResponse
+		if err := json.Unmarshal([]byte(data), &claudeResponse); err != nil {
+			continue
+		}
+		switch claudeResponse.Type {
+		case "message_start":
+			if claudeResponse.Message != nil {
+				usage.PromptTokens = claudeResponse.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if claudeResponse.Delta != nil {
+				responseText += claudeResponse.Delta.Text
+			}
+		case "message_delta":
+			usage.CompletionTokens = claudeResponse.Usage.OutputTokens
+		}
+	}
+	c.Writer.Flush()
+	err := resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage, _ = service.ResponseText2Usage(responseText, info.UpstreamModelName, usage.PromptTokens)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return nil, usage
+}
+
+// ClaudeNativeHandler relays a Claude Messages response untouched and reads its usage
+func ClaudeNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var claudeResponse ClaudeResponse
+	err = json.Unmarshal(responseBody, &claudeResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	if claudeResponse.Error.Type != "" {
+		return &dto.OpenAIErrorWithStatusCode{
+			Error: dto.OpenAIError{
+				Message: claudeResponse.Error.Message,
+				Type:    claudeResponse.Error.Type,
+				Param:   "",
+				Code:    claudeResponse.Error.Type,
+			},
+			StatusCode: resp.StatusCode,
+		}, nil
+	}
+	usage := dto.Usage{
+		PromptTokens:     claudeResponse.Usage.InputTokens,
+		CompletionTokens: claudeResponse.Usage.OutputTokens,
+		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return nil, &usage
+}
+
+// ErrorOpenAI2Claude converts a relay error into the Claude error body
+func ErrorOpenAI2Claude(openaiErr *dto.OpenAIErrorWithStatusCode) *ClaudeErrorResponse {
+	errorType := "api_error"
+	switch openaiErr.StatusCode {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusForbidden:
+		errorType = "permission_error"
+	case http.StatusNotFound:
+		errorType = "not_found_error"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		errorType = "overloaded_error"
+	}
+	return &ClaudeErrorResponse{
+		Type: "error",
+		Error: ClaudeError{
+			Type:    errorType,
+			Message: openaiErr.Error.Message,
+		},
+	}
+}
@@ -0,0 +1,365 @@
+package claude
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"reflect"
+	"testing"
+)
+
+// assertJSON compares the JSON encoding of got with the expected JSON document
+func assertJSON(t *testing.T, got any, want string) {
+	t.Helper()
+	gotData, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(gotData, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", gotData, want)
+	}
+}
+
+func TestRequestClaude2OpenAI(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{
+			"text",
+			`{"model":"claude-3-haiku","max_tokens":256,"system":"Be brief.","stop_sequences":["END"],"stream":true,
+				"messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":[{"type":"text","text":"Hello"}]}]}`,
+			`{"model":"claude-3-haiku","max_tokens":256,"stream":true,"stop":["END"],"messages":[
+				{"role":"system","content":"Be brief."},
+				{"role":"user","content":"Hi"},
+				{"role":"assistant","content":[{"type":"text","text":"Hello"}]}]}`,
+		},
+		{
+			"system blocks and image",
+			`{"model":"claude-3-haiku","system":[{"type":"text","text":"a"},{"type":"text","text":"b"}],
+				"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBO"}},{"type":"text","text":"What is it?"}]}]}`,
+			`{"model":"claude-3-haiku","messages":[
+				{"role":"system","content":"ab"},
+				{"role":"user","content":[{"type":"image_url","text":"","image_url":{"url":"data:image/png;base64,iVBO","detail":"auto"}},{"type":"text","text":"What is it?"}]}]}`,
+		},
+		{
+			"tools",
+			`{"model":"claude-3-haiku","tool_choice":{"type":"tool","name":"get_weather"},
+				"tools":[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}],
+				"messages":[
+					{"role":"user","content":"Weather in Paris and Rome?"},
+					{"role":"assistant","content":[
+						{"type":"text","text":"Checking."},
+						{"type":"tool_use","id":"toolu_01A","name":"get_weather","input":{"city":"Paris"}},
+						{"type":"tool_use","id":"toolu_01B","name":"get_weather","input":{"city":"Rome"}}]},
+					{"role":"user","content":[
+						{"type":"tool_result","tool_use_id":"toolu_01A","content":"18C"},
+						{"type":"tool_result","tool_use_id":"toolu_01B","content":[{"type":"text","text":"24C"}]}]}]}`,
+			`{"model":"claude-3-haiku",
+				"tools":[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}],
+				"tool_choice":{"type":"function","function":{"name":"get_weather"}},
+				"messages":[
+					{"role":"user","content":"Weather in Paris and Rome?"},
+					{"role":"assistant","content":[{"type":"text","text":"Checking."}],"tool_calls":[
+						{"id":"toolu_01A","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+						{"id":"toolu_01B","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+					{"role":"tool","content":"18C","tool_call_id":"toolu_01A"},
+					{"role":"tool","content":"24C","tool_call_id":"toolu_01B"}]}`,
+		},
+		{
+			"tool use without text",
+			`{"model":"claude-3-haiku","tool_choice":{"type":"any"},"tools":[{"name":"now","input_schema":{"type":"object"}}],
+				"messages":[{"role":"assistant","content":[{"type":"tool_use","id":"toolu_01C","name":"now","input":{}}]}]}`,
+			`{"model":"claude-3-haiku","tools":[{"type":"function","function":{"name":"now","parameters":{"type":"object"}}}],"tool_choice":"required",
+				"messages":[{"role":"assistant","content":null,"tool_calls":[{"id":"toolu_01C","type":"function","function":{"name":"now","arguments":"{}"}}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claudeRequest ClaudeRequest
+			if err := json.Unmarshal([]byte(tt.request), &claudeRequest); err != nil {
+				t.Fatal(err)
+			}
+			openAIRequest, err := RequestClaude2OpenAI(claudeRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, openAIRequest, tt.want)
+		})
+	}
+}
+
+func TestResponseOpenAI2Claude(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			"text",
+			`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"length"}],
+				"usage":{"prompt_tokens":9,"completion_tokens":1,"total_tokens":10}}`,
+			`{"type":"message","role":"assistant","content":[{"type":"text","text":"Hello"}],"model":"claude-3-haiku",
+				"stop_reason":"max_tokens","stop_sequence":null,"usage":{"input_tokens":9,"output_tokens":1}}`,
+		},
+		{
+			"tool calls",
+			`{"id":"chatcmpl-2","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},"finish_reason":"tool_calls"}],
+				"usage":{"prompt_tokens":20,"completion_tokens":30,"total_tokens":50}}`,
+			`{"type":"message","role":"assistant","model":"claude-3-haiku","content":[
+					{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}},
+					{"type":"tool_use","id":"call_2","name":"get_weather","input":{"city":"Rome"}}],
+				"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":20,"output_tokens":30}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var textResponse dto.TextResponse
+			if err := json.Unmarshal([]byte(tt.response), &textResponse); err != nil {
+				t.Fatal(err)
+			}
+			claudeResponse := ResponseOpenAI2Claude(&textResponse, "claude-3-haiku")
+			if claudeResponse.Id == "" {
+				t.Error("the response has no id")
+			}
+			var got map[string]any
+			data, _ := json.Marshal(claudeResponse)
+			_ = json.Unmarshal(data, &got)
+			delete(got, "id")
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestStreamConverter(t *testing.T) {
+	// chunks recorded from an OpenAI stream answering with text and then two tool calls
+	chunks := []string{
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":""},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Checking"},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"."},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-3","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+	converter := &StreamConverter{Id: "msg_1", Model: "claude-3-haiku", PromptTokens: 12}
+	var events []ClaudeStreamEvent
+	for _, chunk := range chunks {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(chunk), &streamResponse); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, converter.Convert(&streamResponse)...)
+	}
+	events = append(events, converter.Finish(&dto.Usage{PromptTokens: 12, CompletionTokens: 25})...)
+	assertJSON(t, events, `[
+		{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-haiku","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":12,"output_tokens":0}}},
+		{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}},
+		{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking"}},
+		{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"."}},
+		{"type":"content_block_stop","index":0},
+		{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"call_1","name":"get_weather","input":{}}},
+		{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}},
+		{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}},
+		{"type":"content_block_stop","index":1},
+		{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"call_2","name":"get_weather","input":{}}},
+		{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Rome\"}"}},
+		{"type":"content_block_stop","index":2},
+		{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"input_tokens":0,"output_tokens":25}},
+		{"type":"message_stop"}]`)
+}
+
+func TestRequestOpenAI2ClaudeMessage(t *testing.T) {
+	weatherTool := `[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}},
+		{"type":"function","function":{"name":"now"}}]`
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{
+			"tools and auto",
+			`{"model":"claude-3-haiku","tool_choice":"auto","tools":` + weatherTool + `,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Weather?"}]}`,
+			`{"model":"claude-3-haiku","system":"Be brief.","max_tokens":4096,"messages":[{"role":"user","content":"Weather?"}],
+				"tools":[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}},
+					{"name":"now","input_schema":{"type":"object","properties":{}}}],
+				"tool_choice":{"type":"auto"}}`,
+		},
+		{
+			"required",
+			`{"model":"claude-3-haiku","tool_choice":"required","tools":` + weatherTool + `,"messages":[{"role":"user","content":"Weather?"}]}`,
+			`{"model":"claude-3-haiku","max_tokens":4096,"messages":[{"role":"user","content":"Weather?"}],
+				"tools":[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}},
+					{"name":"now","input_schema":{"type":"object","properties":{}}}],
+				"tool_choice":{"type":"any"}}`,
+		},
+		{
+			"named function",
+			`{"model":"claude-3-haiku","tool_choice":{"type":"function","function":{"name":"now"}},"tools":` + weatherTool + `,"messages":[{"role":"user","content":"Time?"}]}`,
+			`{"model":"claude-3-haiku","max_tokens":4096,"messages":[{"role":"user","content":"Time?"}],
+				"tools":[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}},
+					{"name":"now","input_schema":{"type":"object","properties":{}}}],
+				"tool_choice":{"type":"tool","name":"now"}}`,
+		},
+		{
+			"none drops the tools",
+			`{"model":"claude-3-haiku","tool_choice":"none","tools":` + weatherTool + `,"messages":[{"role":"user","content":"Weather?"}]}`,
+			`{"model":"claude-3-haiku","max_tokens":4096,"messages":[{"role":"user","content":"Weather?"}]}`,
+		},
+		{
+			"tool calls and results",
+			`{"model":"claude-3-haiku","max_tokens":100,"tools":` + weatherTool + `,"messages":[
+				{"role":"user","content":"Weather in Paris and Rome?"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"toolu_01A","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"toolu_01B","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+				{"role":"tool","tool_call_id":"toolu_01A","content":"18C"},
+				{"role":"tool","tool_call_id":"toolu_01B","content":[{"type":"text","text":"24C"}]},
+				{"role":"user","content":"Thanks"}]}`,
+			`{"model":"claude-3-haiku","max_tokens":100,
+				"tools":[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}},
+					{"name":"now","input_schema":{"type":"object","properties":{}}}],
+				"messages":[
+					{"role":"user","content":"Weather in Paris and Rome?"},
+					{"role":"assistant","content":[
+						{"type":"tool_use","id":"toolu_01A","name":"get_weather","input":{"city":"Paris"}},
+						{"type":"tool_use","id":"toolu_01B","name":"get_weather","input":{"city":"Rome"}}]},
+					{"role":"user","content":[
+						{"type":"tool_result","tool_use_id":"toolu_01A","content":"18C"},
+						{"type":"tool_result","tool_use_id":"toolu_01B","content":"24C"},
+						{"type":"text","text":"Thanks"}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var textRequest dto.GeneralOpenAIRequest
+			if err := json.Unmarshal([]byte(tt.request), &textRequest); err != nil {
+				t.Fatal(err)
+			}
+			claudeRequest, err := RequestOpenAI2ClaudeMessage(textRequest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, claudeRequest, tt.want)
+		})
+	}
+}
+
+func TestStreamResponseClaude2OpenAI(t *testing.T) {
+	// events recorded from a Claude stream answering with text and two tool_use blocks
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-haiku-20240307","usage":{"input_tokens":350,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01A","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Pa"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"ris\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_01B","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Rome\"}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":89}}`,
+		`{"type":"message_stop"}`,
+	}
+	toolCallIndexes := make(map[int]int)
+	var chunks []*dto.ChatCompletionsStreamResponse
+	var usage ClaudeUsage
+	for _, event := range events {
+		var claudeResponse ClaudeResponse
+		if err := json.Unmarshal([]byte(event), &claudeResponse); err != nil {
+			t.Fatal(err)
+		}
+		response, claudeUsage := StreamResponseClaude2OpenAI(RequestModeMessage, &claudeResponse, toolCallIndexes)
+		if response == nil {
+			continue
+		}
+		chunks = append(chunks, response)
+		if claudeUsage.InputTokens != 0 {
+			usage.InputTokens = claudeUsage.InputTokens
+		}
+		if claudeUsage.OutputTokens != 0 {
+			usage.OutputTokens = claudeUsage.OutputTokens
+		}
+	}
+	var choices []dto.ChatCompletionsStreamResponseChoice
+	for _, chunk := range chunks {
+		choices = append(choices, chunk.Choices...)
+	}
+	assertJSON(t, choices, `[
+		{"index":0,"delta":{"content":"","role":"assistant"},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"content":"Checking."},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":0,"id":"toolu_01A","type":"function","function":{"name":"get_weather"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":0,"function":{}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\": \"Pa"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ris\"}"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":1,"id":"toolu_01B","type":"function","function":{"name":"get_weather"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"{\"city\": \"Rome\"}"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{},"logprobs":null,"finish_reason":"tool_calls"}]`)
+	if len(toolCallIndexes) != 2 || toolCallIndexes[1] != 0 || toolCallIndexes[2] != 1 {
+		t.Errorf("toolCallIndexes = %v, want map[1:0 2:1]", toolCallIndexes)
+	}
+	if usage.InputTokens != 350 || usage.OutputTokens != 89 {
+		t.Errorf("usage = %+v, want 350 input and 89 output tokens", usage)
+	}
+}
+
+func TestResponseClaude2OpenAI(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			"text",
+			`{"id":"msg_01","type":"message","role":"assistant","model":"claude-3-haiku-20240307","content":[{"type":"text","text":"Hello"}],
+				"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":1}}`,
+			`{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}`,
+		},
+		{
+			"text and tool uses",
+			`{"id":"msg_02","type":"message","role":"assistant","model":"claude-3-haiku-20240307","content":[
+					{"type":"text","text":"Checking."},
+					{"type":"tool_use","id":"toolu_01A","name":"get_weather","input":{"city":"Paris"}},
+					{"type":"tool_use","id":"toolu_01B","name":"get_weather","input":{"city":"Rome"}}],
+				"stop_reason":"tool_use","usage":{"input_tokens":350,"output_tokens":89}}`,
+			`{"index":0,"message":{"role":"assistant","content":"Checking.","tool_calls":[
+					{"id":"toolu_01A","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"toolu_01B","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+				"finish_reason":"tool_calls"}`,
+		},
+		{
+			"tool use only",
+			`{"id":"msg_03","type":"message","role":"assistant","model":"claude-3-haiku-20240307","content":[
+					{"type":"tool_use","id":"toolu_01C","name":"now","input":{}}],
+				"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":5}}`,
+			`{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+					{"id":"toolu_01C","type":"function","function":{"name":"now","arguments":"{}"}}]},
+				"finish_reason":"tool_calls"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claudeResponse ClaudeResponse
+			if err := json.Unmarshal([]byte(tt.response), &claudeResponse); err != nil {
+				t.Fatal(err)
+			}
+			response := ResponseClaude2OpenAI(RequestModeMessage, &claudeResponse)
+			if response.Id != claudeResponse.Id || len(response.Choices) != 1 {
+				t.Fatalf("response = %+v", response)
+			}
+			assertJSON(t, response.Choices[0], tt.want)
+		})
+	}
+}
@@ -1,31 +1,67 @@
 package cohere
 
 type CohereRequest struct {
-	Model       string        `json:"model"`
-	ChatHistory []ChatHistory `json:"chat_history"`
-	Message     string        `json:"message"`
-	Stream      bool          `json:"stream"`
-	MaxTokens   int64         `json:"max_tokens"`
+	Model       string             `json:"model"`
+	ChatHistory []ChatHistory      `json:"chat_history"`
+	Message     string             `json:"message"`
+	Stream      bool               `json:"stream"`
+	MaxTokens   int64              `json:"max_tokens"`
+	Tools       []CohereTool       `json:"tools,omitempty"`
+	ToolResults []CohereToolResult `json:"tool_results,omitempty"`
 }
 
 type ChatHistory struct {
-	Role    string `json:"role"`
-	Message string `json:"message"`
+	Role        string             `json:"role"`
+	Message     string             `json:"message,omitempty"`
+	ToolCalls   []CohereToolCall   `json:"tool_calls,omitempty"`
+	ToolResults []CohereToolResult `json:"tool_results,omitempty"`
+}
+
+type CohereTool struct {
+	Name                 string                               `json:"name"`
+	Description          string                               `json:"description"`
+	ParameterDefinitions map[string]CohereParameterDefinition `json:"parameter_definitions,omitempty"`
+}
+
+type CohereParameterDefinition struct {
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+}
+
+type CohereToolCall struct {
+	Name       string         `json:"name"`
+	Parameters map[string]any `json:"parameters"`
+}
+
+type CohereToolResult struct {
+	Call    CohereToolCall   `json:"call"`
+	Outputs []map[string]any `json:"outputs"`
+}
+
+type CohereToolCallDelta struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name,omitempty"`
+	Parameters string `json:"parameters,omitempty"`
+	Text       string `json:"text,omitempty"`
 }
 
 type CohereResponse struct {
-	IsFinished   bool                  `json:"is_finished"`
-	EventType    string                `json:"event_type"`
-	Text         string                `json:"text,omitempty"`
-	FinishReason string                `json:"finish_reason,omitempty"`
-	Response     *CohereResponseResult `json:"response"`
+	IsFinished    bool                  `json:"is_finished"`
+	EventType     string                `json:"event_type"`
+	Text          string                `json:"text,omitempty"`
+	FinishReason  string                `json:"finish_reason,omitempty"`
+	ToolCalls     []CohereToolCall      `json:"tool_calls,omitempty"`
+	ToolCallDelta *CohereToolCallDelta  `json:"tool_call_delta,omitempty"`
+	Response      *CohereResponseResult `json:"response"`
 }
 
 type CohereResponseResult struct {
-	ResponseId   string     `json:"response_id"`
-	FinishReason string     `json:"finish_reason,omitempty"`
-	Text         string     `json:"text"`
-	Meta         CohereMeta `json:"meta"`
+	ResponseId   string           `json:"response_id"`
+	FinishReason string           `json:"finish_reason,omitempty"`
+	Text         string           `json:"text"`
+	ToolCalls    []CohereToolCall `json:"tool_calls,omitempty"`
+	Meta         CohereMeta       `json:"meta"`
 }
 
 type CohereMeta struct {
@@ -24,27 +24,177 @@ func requestOpenAI2Cohere(textRequest dto.GeneralOpenAIRequest) *CohereRequest {
 	if cohereReq.MaxTokens == 0 {
 		cohereReq.MaxTokens = 4000
 	}
-	for _, msg := range textRequest.Messages {
-		if msg.Role == "user" {
-			cohereReq.Message = msg.StringContent()
-		} else {
-			var role string
-			if msg.Role == "assistant" {
-				role = "CHATBOT"
-			} else if msg.Role == "system" {
-				role = "SYSTEM"
+	// the v1 chat api has no tool_choice, tools are only dropped for "none"
+	if textRequest.ToolChoice != "none" {
+		for _, tool := range textRequest.ParseTools() {
+			cohereReq.Tools = append(cohereReq.Tools, toolOpenAI2Cohere(tool))
+		}
+	}
+	// the last user message is the message of this turn, trailing tool
+	// messages are the tool results of it instead
+	messages := textRequest.Messages
+	end := len(messages)
+	for end > 0 && messages[end-1].Role == "tool" {
+		end--
+	}
+	if end == len(messages) && end > 0 && messages[end-1].Role == "user" {
+		end--
+		cohereReq.Message = messages[end].StringContent()
+	}
+	// cohere has no tool call ids, results refer to the call itself
+	toolCalls := make(map[string]CohereToolCall)
+	for _, msg := range messages[:end] {
+		switch msg.Role {
+		case "assistant":
+			history := ChatHistory{
+				Role:    "CHATBOT",
+				Message: msg.StringContent(),
+			}
+			for _, toolCall := range msg.ParseToolCalls() {
+				call := toolCallOpenAI2Cohere(toolCall)
+				toolCalls[toolCall.ID] = call
+				history.ToolCalls = append(history.ToolCalls, call)
+			}
+			cohereReq.ChatHistory = append(cohereReq.ChatHistory, history)
+		case "tool":
+			toolResult := toolResultOpenAI2Cohere(msg, toolCalls)
+			last := len(cohereReq.ChatHistory) - 1
+			if last >= 0 && cohereReq.ChatHistory[last].Role == "TOOL" {
+				cohereReq.ChatHistory[last].ToolResults = append(cohereReq.ChatHistory[last].ToolResults, toolResult)
 			} else {
-				role = "USER"
+				cohereReq.ChatHistory = append(cohereReq.ChatHistory, ChatHistory{
+					Role:        "TOOL",
+					ToolResults: []CohereToolResult{toolResult},
+				})
 			}
+		case "system":
+			cohereReq.ChatHistory = append(cohereReq.ChatHistory, ChatHistory{
+				Role:    "SYSTEM",
+				Message: msg.StringContent(),
+			})
+		default:
 			cohereReq.ChatHistory = append(cohereReq.ChatHistory, ChatHistory{
-				Role:    role,
+				Role:    "USER",
 				Message: msg.StringContent(),
 			})
 		}
 	}
+	for _, msg := range messages[end:] {
+		if msg.Role == "tool" {
+			cohereReq.ToolResults = append(cohereReq.ToolResults, toolResultOpenAI2Cohere(msg, toolCalls))
+		}
+	}
 	return &cohereReq
 }
 
+func toolOpenAI2Cohere(tool dto.OpenAITools) CohereTool {
+	cohereTool := CohereTool{
+		Name:        tool.Function.Name,
+		Description: tool.Function.Description,
+	}
+	parameters, _ := tool.Function.Parameters.(map[string]any)
+	properties, _ := parameters["properties"].(map[string]any)
+	if len(properties) == 0 {
+		return cohereTool
+	}
+	required := make(map[string]bool)
+	if requiredList, ok := parameters["required"].([]any); ok {
+		for _, name := range requiredList {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+	cohereTool.ParameterDefinitions = make(map[string]CohereParameterDefinition, len(properties))
+	for name, property := range properties {
+		schema, _ := property.(map[string]any)
+		description, _ := schema["description"].(string)
+		cohereTool.ParameterDefinitions[name] = CohereParameterDefinition{
+			Description: description,
+			Type:        schemaTypeOpenAI2Cohere(schema),
+			Required:    required[name],
+		}
+	}
+	return cohereTool
+}
+
+// schemaTypeOpenAI2Cohere maps a JSON schema type to the python type names cohere expects
+func schemaTypeOpenAI2Cohere(schema map[string]any) string {
+	switch schema["type"] {
+	case "string":
+		return "str"
+	case "integer":
+		return "int"
+	case "number":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "array":
+		if items, ok := schema["items"].(map[string]any); ok && items["type"] != nil {
+			return fmt.Sprintf("List[%s]", schemaTypeOpenAI2Cohere(items))
+		}
+		return "list"
+	case "object":
+		return "dict"
+	default:
+		return "str"
+	}
+}
+
+func toolCallOpenAI2Cohere(toolCall dto.ToolCall) CohereToolCall {
+	parameters := make(map[string]any)
+	if toolCall.Function.Arguments != "" {
+		_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &parameters)
+	}
+	return CohereToolCall{
+		Name:       toolCall.Function.Name,
+		Parameters: parameters,
+	}
+}
+
+func toolResultOpenAI2Cohere(msg dto.Message, toolCalls map[string]CohereToolCall) CohereToolResult {
+	call, ok := toolCalls[msg.ToolCallId]
+	if !ok {
+		call = CohereToolCall{
+			Parameters: map[string]any{},
+		}
+		if msg.Name != nil {
+			call.Name = *msg.Name
+		}
+	}
+	content := msg.StringContent()
+	var outputs []map[string]any
+	var output map[string]any
+	if err := json.Unmarshal([]byte(content), &output); err == nil && output != nil {
+		outputs = []map[string]any{output}
+	} else if err := json.Unmarshal([]byte(content), &outputs); err != nil || len(outputs) == 0 {
+		outputs = []map[string]any{{"result": content}}
+	}
+	return CohereToolResult{
+		Call:    call,
+		Outputs: outputs,
+	}
+}
+
+func toolCallsCohere2OpenAI(toolCalls []CohereToolCall) []dto.ToolCall {
+	openaiToolCalls := make([]dto.ToolCall, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		arguments, _ := json.Marshal(toolCall.Parameters)
+		if toolCall.Parameters == nil {
+			arguments = []byte("{}")
+		}
+		openaiToolCalls = append(openaiToolCalls, dto.ToolCall{
+			ID:   fmt.Sprintf("call_%s", common.GetUUID()),
+			Type: "function",
+			Function: dto.FunctionCall{
+				Name:      toolCall.Name,
+				Arguments: string(arguments),
+			},
+		})
+	}
+	return openaiToolCalls
+}
+
 func stopReasonCohere2OpenAI(reason string) string {
 	switch reason {
 	case "COMPLETE":
@@ -61,6 +211,7 @@ func cohereStreamHandler(c *gin.Context, resp *http.Response, modelName string,
 	createdTime := common.GetTimestamp()
 	usage := &dto.Usage{}
 	responseText := ""
+	toolCallCount := 0
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		if atEOF && len(data) == 0 {
@@ -99,8 +250,12 @@ func cohereStreamHandler(c *gin.Context, resp *http.Response, modelName string,
 			openaiResp.Created = createdTime
 			openaiResp.Object = "chat.completion.chunk"
 			openaiResp.Model = modelName
-			if cohereResp.IsFinished {
+			switch {
+			case cohereResp.IsFinished:
 				finishReason := stopReasonCohere2OpenAI(cohereResp.FinishReason)
+				if toolCallCount > 0 {
+					finishReason = "tool_calls"
+				}
 				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
 					{
 						Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{},
@@ -112,7 +267,60 @@ func cohereStreamHandler(c *gin.Context, resp *http.Response, modelName string,
 					usage.PromptTokens = cohereResp.Response.Meta.BilledUnits.InputTokens
 					usage.CompletionTokens = cohereResp.Response.Meta.BilledUnits.OutputTokens
 				}
-			} else {
+			case cohereResp.EventType == "tool-calls-chunk" && cohereResp.ToolCallDelta != nil:
+				toolCallDelta := cohereResp.ToolCallDelta
+				delta := dto.ChatCompletionsStreamResponseChoiceDelta{
+					Role: "assistant",
+				}
+				if toolCallDelta.Name != "" || toolCallDelta.Parameters != "" {
+					index := toolCallDelta.Index
+					toolCall := dto.ToolCall{
+						Index: &index,
+						Function: dto.FunctionCall{
+							Name:      toolCallDelta.Name,
+							Arguments: toolCallDelta.Parameters,
+						},
+					}
+					if toolCallDelta.Name != "" {
+						toolCall.ID = fmt.Sprintf("call_%s", common.GetUUID())
+						toolCall.Type = "function"
+						toolCallCount++
+					}
+					delta.ToolCalls = []dto.ToolCall{toolCall}
+				} else if toolCallDelta.Text != "" {
+					delta.SetContentString(toolCallDelta.Text)
+				} else {
+					return true
+				}
+				responseText += toolCallDelta.Name + toolCallDelta.Parameters + toolCallDelta.Text
+				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
+					{
+						Delta: delta,
+						Index: 0,
+					},
+				}
+			case cohereResp.EventType == "tool-calls-generation":
+				// models that do not stream tool call chunks send the calls in one piece here
+				if toolCallCount > 0 || len(cohereResp.ToolCalls) == 0 {
+					return true
+				}
+				toolCalls := toolCallsCohere2OpenAI(cohereResp.ToolCalls)
+				for i := range toolCalls {
+					index := i
+					toolCalls[i].Index = &index
+					responseText += toolCalls[i].Function.Name + toolCalls[i].Function.Arguments
+				}
+				toolCallCount = len(toolCalls)
+				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
+					{
+						Delta: dto.ChatCompletionsStreamResponseChoiceDelta{
+							Role:      "assistant",
+							ToolCalls: toolCalls,
+						},
+						Index: 0,
+					},
+				}
+			default:
 				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
 					{
 						Delta: dto.ChatCompletionsStreamResponseChoiceDelta{
@@ -177,6 +385,13 @@ func cohereHandler(c *gin.Context, resp *http.Response, modelName string, prompt
 			FinishReason: stopReasonCohere2OpenAI(cohereResp.FinishReason),
 		},
 	}
+	if len(cohereResp.ToolCalls) > 0 {
+		openaiResp.Choices[0].Message.ToolCalls = toolCallsCohere2OpenAI(cohereResp.ToolCalls)
+		openaiResp.Choices[0].FinishReason = "tool_calls"
+		if cohereResp.Text == "" {
+			openaiResp.Choices[0].Message.Content = nil
+		}
+	}
 
 	jsonResponse, err := json.Marshal(openaiResp)
 	if err != nil {
@@ -0,0 +1,233 @@
+package cohere
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// assertJSON compares the JSON encoding of got with the expected JSON document
+func assertJSON(t *testing.T, got any, want string) {
+	t.Helper()
+	gotData, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(gotData, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", gotData, want)
+	}
+}
+
+// clearToolCallIds checks the generated tool call ids and blanks them for the comparison
+func clearToolCallIds(t *testing.T, toolCalls []dto.ToolCall) {
+	t.Helper()
+	for i := range toolCalls {
+		if toolCalls[i].Function.Name != "" && !strings.HasPrefix(toolCalls[i].ID, "call_") {
+			t.Errorf("tool call id %q has no call_ prefix", toolCalls[i].ID)
+		}
+		toolCalls[i].ID = ""
+	}
+}
+
+// streamRecorder adds the CloseNotify gin streams with to the recorder
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func newUpstreamResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestRequestOpenAI2Cohere(t *testing.T) {
+	tools := `[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","required":["city"],"properties":{
+			"city":{"type":"string","description":"The city"},"days":{"type":"integer"},"hourly":{"type":"boolean"},"units":{"type":"array","items":{"type":"string"}}}}}},
+		{"type":"function","function":{"name":"now","description":"The time"}}]`
+	toolDefinitions := `[{"name":"get_weather","description":"Get the weather","parameter_definitions":{
+			"city":{"description":"The city","type":"str","required":true},"days":{"type":"int","required":false},
+			"hourly":{"type":"bool","required":false},"units":{"type":"List[str]","required":false}}},
+		{"name":"now","description":"The time"}]`
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{
+			"tools",
+			`{"model":"command-r","tools":` + tools + `,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Weather?"}]}`,
+			`{"model":"command-r","chat_history":[{"role":"SYSTEM","message":"Be brief."}],"message":"Weather?","stream":false,"max_tokens":4000,"tools":` + toolDefinitions + `}`,
+		},
+		{
+			"none drops the tools",
+			`{"model":"command-r","tool_choice":"none","tools":` + tools + `,"messages":[{"role":"user","content":"Weather?"}]}`,
+			`{"model":"command-r","chat_history":[],"message":"Weather?","stream":false,"max_tokens":4000}`,
+		},
+		{
+			"tool results of this turn",
+			`{"model":"command-r","max_tokens":100,"tools":` + tools + `,"messages":[
+				{"role":"user","content":"Weather in Paris and the time?"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"now","arguments":""}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"{\"temperature\":18}"},
+				{"role":"tool","tool_call_id":"call_2","content":"12:00"}]}`,
+			`{"model":"command-r","message":"","stream":false,"max_tokens":100,"tools":` + toolDefinitions + `,"chat_history":[
+					{"role":"USER","message":"Weather in Paris and the time?"},
+					{"role":"CHATBOT","tool_calls":[{"name":"get_weather","parameters":{"city":"Paris"}},{"name":"now","parameters":{}}]}],
+				"tool_results":[
+					{"call":{"name":"get_weather","parameters":{"city":"Paris"}},"outputs":[{"temperature":18}]},
+					{"call":{"name":"now","parameters":{}},"outputs":[{"result":"12:00"}]}]}`,
+		},
+		{
+			"tool results of an earlier turn",
+			`{"model":"command-r","messages":[
+				{"role":"user","content":"Time?"},
+				{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"now","arguments":"{}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"[{\"time\":\"12:00\"},{\"zone\":\"UTC\"}]"},
+				{"role":"assistant","content":"It is noon."},
+				{"role":"user","content":"Thanks"}]}`,
+			`{"model":"command-r","message":"Thanks","stream":false,"max_tokens":4000,"chat_history":[
+				{"role":"USER","message":"Time?"},
+				{"role":"CHATBOT","tool_calls":[{"name":"now","parameters":{}}]},
+				{"role":"TOOL","tool_results":[{"call":{"name":"now","parameters":{}},"outputs":[{"time":"12:00"},{"zone":"UTC"}]}]},
+				{"role":"CHATBOT","message":"It is noon."}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var textRequest dto.GeneralOpenAIRequest
+			if err := json.Unmarshal([]byte(tt.request), &textRequest); err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, requestOpenAI2Cohere(textRequest), tt.want)
+		})
+	}
+}
+
+func TestCohereHandlerToolCalls(t *testing.T) {
+	body := `{"response_id":"a1b2","text":"","generation_id":"c3d4","finish_reason":"COMPLETE",
+		"tool_calls":[{"name":"get_weather","parameters":{"city":"Paris"}},{"name":"now","parameters":{}}],
+		"meta":{"billed_units":{"input_tokens":40,"output_tokens":25}}}`
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	openaiErr, usage := cohereHandler(c, newUpstreamResponse(body), "command-r", 0)
+	if openaiErr != nil {
+		t.Fatal(openaiErr.Error)
+	}
+	if usage.PromptTokens != 40 || usage.CompletionTokens != 25 || usage.TotalTokens != 65 {
+		t.Errorf("usage = %+v, want 40 prompt and 25 completion tokens", usage)
+	}
+	var textResponse dto.TextResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &textResponse); err != nil {
+		t.Fatal(err)
+	}
+	choice := textResponse.Choices[0]
+	toolCalls := choice.Message.ParseToolCalls()
+	clearToolCallIds(t, toolCalls)
+	choice.Message.ToolCalls = toolCalls
+	assertJSON(t, choice, `{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+			{"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"type":"function","function":{"name":"now","arguments":"{}"}}]},
+		"finish_reason":"tool_calls"}`)
+}
+
+func TestCohereStreamHandlerToolCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []string
+		want   string
+	}{
+		{
+			"tool call chunks",
+			[]string{
+				`{"is_finished":false,"event_type":"stream-start","generation_id":"c3d4"}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":0,"text":"I will look it up."}}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":0,"name":"get_weather"}}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":0,"parameters":"{\"city\":"}}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":0,"parameters":"\"Paris\"}"}}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":1,"name":"now"}}`,
+				`{"is_finished":false,"event_type":"tool-calls-chunk","tool_call_delta":{"index":1,"parameters":"{}"}}`,
+				`{"is_finished":false,"event_type":"tool-calls-generation","text":"I will look it up.","tool_calls":[{"name":"get_weather","parameters":{"city":"Paris"}},{"name":"now","parameters":{}}]}`,
+				`{"is_finished":true,"event_type":"stream-end","finish_reason":"COMPLETE","response":{"response_id":"a1b2","text":"I will look it up.","finish_reason":"COMPLETE","meta":{"billed_units":{"input_tokens":40,"output_tokens":25}}}}`,
+			},
+			`[{"index":0,"delta":{"role":"assistant","content":""},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","content":"I will look it up."},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"type":"function","function":{"name":"get_weather"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":1,"type":"function","function":{"name":"now"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":1,"function":{"arguments":"{}"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{},"logprobs":null,"finish_reason":"tool_calls"}]`,
+		},
+		{
+			"tool calls in one piece",
+			[]string{
+				`{"is_finished":false,"event_type":"stream-start","generation_id":"c3d4"}`,
+				`{"is_finished":false,"event_type":"tool-calls-generation","tool_calls":[{"name":"get_weather","parameters":{"city":"Paris"}},{"name":"now","parameters":{}}]}`,
+				`{"is_finished":true,"event_type":"stream-end","finish_reason":"COMPLETE","response":{"response_id":"a1b2","text":"","finish_reason":"COMPLETE","meta":{"billed_units":{"input_tokens":40,"output_tokens":25}}}}`,
+			},
+			`[{"index":0,"delta":{"role":"assistant","content":""},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{"role":"assistant","tool_calls":[
+					{"index":0,"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"index":1,"type":"function","function":{"name":"now","arguments":"{}"}}]},"logprobs":null,"finish_reason":null},
+				{"index":0,"delta":{},"logprobs":null,"finish_reason":"tool_calls"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &streamRecorder{httptest.NewRecorder()}
+			c, _ := gin.CreateTestContext(recorder)
+			openaiErr, usage := cohereStreamHandler(c, newUpstreamResponse(strings.Join(tt.stream, "\n")+"\n"), "command-r", 0)
+			if openaiErr != nil {
+				t.Fatal(openaiErr.Error)
+			}
+			if usage.PromptTokens != 40 || usage.CompletionTokens != 25 {
+				t.Errorf("usage = %+v, want 40 prompt and 25 completion tokens", usage)
+			}
+			var choices []dto.ChatCompletionsStreamResponseChoice
+			done := false
+			for _, line := range strings.Split(recorder.Body.String(), "\n") {
+				data := strings.TrimPrefix(line, "data: ")
+				if data == line {
+					continue
+				}
+				if data == "[DONE]" {
+					done = true
+					continue
+				}
+				var streamResponse dto.ChatCompletionsStreamResponse
+				if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+					t.Fatal(err)
+				}
+				for _, choice := range streamResponse.Choices {
+					clearToolCallIds(t, choice.Delta.ToolCalls)
+					choices = append(choices, choice)
+				}
+			}
+			if !done {
+				t.Error("the stream did not end with [DONE]")
+			}
+			assertJSON(t, choices, tt.want)
+		})
+	}
+}
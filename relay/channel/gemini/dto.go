@@ -1,10 +1,44 @@
 package gemini
 
+import "encoding/json"
+
 type GeminiChatRequest struct {
-	Contents         []GeminiChatContent        `json:"contents"`
-	SafetySettings   []GeminiChatSafetySettings `json:"safety_settings,omitempty"`
-	GenerationConfig GeminiChatGenerationConfig `json:"generation_config,omitempty"`
-	Tools            []GeminiChatTools          `json:"tools,omitempty"`
+	Contents          []GeminiChatContent        `json:"contents"`
+	SafetySettings    []GeminiChatSafetySettings `json:"safety_settings,omitempty"`
+	GenerationConfig  GeminiChatGenerationConfig `json:"generation_config,omitempty"`
+	Tools             []GeminiChatTools          `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig          `json:"tool_config,omitempty"`
+	SystemInstruction *GeminiChatContent         `json:"system_instruction,omitempty"`
+}
+
+// UnmarshalJSON accepts both the snake_case and the camelCase field names,
+// as the Gemini REST API does
+func (r *GeminiChatRequest) UnmarshalJSON(data []byte) error {
+	type geminiChatRequest GeminiChatRequest
+	var request struct {
+		geminiChatRequest
+		SafetySettings    []GeminiChatSafetySettings  `json:"safetySettings"`
+		GenerationConfig  *GeminiChatGenerationConfig `json:"generationConfig"`
+		SystemInstruction *GeminiChatContent          `json:"systemInstruction"`
+		ToolConfig        *GeminiToolConfig           `json:"toolConfig"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return err
+	}
+	*r = GeminiChatRequest(request.geminiChatRequest)
+	if request.SafetySettings != nil {
+		r.SafetySettings = request.SafetySettings
+	}
+	if request.GenerationConfig != nil {
+		r.GenerationConfig = *request.GenerationConfig
+	}
+	if request.SystemInstruction != nil {
+		r.SystemInstruction = request.SystemInstruction
+	}
+	if request.ToolConfig != nil {
+		r.ToolConfig = request.ToolConfig
+	}
+	return nil
 }
 
 type GeminiInlineData struct {
@@ -12,9 +46,53 @@ type GeminiInlineData struct {
 	Data     string `json:"data"`
 }
 
+type GeminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args"`
+}
+
+type GeminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
 type GeminiPart struct {
-	Text       string            `json:"text,omitempty"`
-	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// UnmarshalJSON also accepts the snake_case inline_data, mime_type,
+// function_call and function_response names
+func (p *GeminiPart) UnmarshalJSON(data []byte) error {
+	type geminiPart GeminiPart
+	var part struct {
+		geminiPart
+		InlineData *struct {
+			MimeType string `json:"mime_type"`
+			Data     string `json:"data"`
+		} `json:"inline_data"`
+		FunctionCall     *GeminiFunctionCall     `json:"function_call"`
+		FunctionResponse *GeminiFunctionResponse `json:"function_response"`
+	}
+	if err := json.Unmarshal(data, &part); err != nil {
+		return err
+	}
+	*p = GeminiPart(part.geminiPart)
+	if p.InlineData == nil && part.InlineData != nil {
+		p.InlineData = &GeminiInlineData{
+			MimeType: part.InlineData.MimeType,
+			Data:     part.InlineData.Data,
+		}
+	}
+	if p.FunctionCall == nil {
+		p.FunctionCall = part.FunctionCall
+	}
+	if p.FunctionResponse == nil {
+		p.FunctionResponse = part.FunctionResponse
+	}
+	return nil
 }
 
 type GeminiChatContent struct {
@@ -31,6 +109,70 @@ type GeminiChatTools struct {
 	FunctionDeclarations any `json:"functionDeclarations,omitempty"`
 }
 
+// UnmarshalJSON also accepts the snake_case function_declarations name
+func (t *GeminiChatTools) UnmarshalJSON(data []byte) error {
+	var tools struct {
+		FunctionDeclarations      any `json:"functionDeclarations"`
+		FunctionDeclarationsSnake any `json:"function_declarations"`
+	}
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return err
+	}
+	t.FunctionDeclarations = tools.FunctionDeclarations
+	if t.FunctionDeclarations == nil {
+		t.FunctionDeclarations = tools.FunctionDeclarationsSnake
+	}
+	return nil
+}
+
+type GeminiFunctionDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"function_calling_config,omitempty"`
+}
+
+// UnmarshalJSON also accepts the camelCase functionCallingConfig name
+func (t *GeminiToolConfig) UnmarshalJSON(data []byte) error {
+	var config struct {
+		FunctionCallingConfig      *GeminiFunctionCallingConfig `json:"function_calling_config"`
+		FunctionCallingConfigCamel *GeminiFunctionCallingConfig `json:"functionCallingConfig"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	t.FunctionCallingConfig = config.FunctionCallingConfig
+	if t.FunctionCallingConfig == nil {
+		t.FunctionCallingConfig = config.FunctionCallingConfigCamel
+	}
+	return nil
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowed_function_names,omitempty"`
+}
+
+// UnmarshalJSON also accepts the camelCase allowedFunctionNames name
+func (f *GeminiFunctionCallingConfig) UnmarshalJSON(data []byte) error {
+	type geminiFunctionCallingConfig GeminiFunctionCallingConfig
+	var config struct {
+		geminiFunctionCallingConfig
+		AllowedFunctionNames []string `json:"allowedFunctionNames"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	*f = GeminiFunctionCallingConfig(config.geminiFunctionCallingConfig)
+	if f.AllowedFunctionNames == nil {
+		f.AllowedFunctionNames = config.AllowedFunctionNames
+	}
+	return nil
+}
+
 type GeminiChatGenerationConfig struct {
 	Temperature     float64  `json:"temperature,omitempty"`
 	TopP            float64  `json:"topP,omitempty"`
@@ -42,9 +184,9 @@ type GeminiChatGenerationConfig struct {
 
 type GeminiChatCandidate struct {
 	Content       GeminiChatContent        `json:"content"`
-	FinishReason  string                   `json:"finishReason"`
+	FinishReason  string                   `json:"finishReason,omitempty"`
 	Index         int64                    `json:"index"`
-	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings"`
+	SafetyRatings []GeminiChatSafetyRating `json:"safetyRatings,omitempty"`
 }
 
 type GeminiChatSafetyRating struct {
@@ -57,6 +199,23 @@ type GeminiChatPromptFeedback struct {
 }
 
 type GeminiChatResponse struct {
-	Candidates     []GeminiChatCandidate    `json:"candidates"`
-	PromptFeedback GeminiChatPromptFeedback `json:"promptFeedback"`
+	Candidates     []GeminiChatCandidate     `json:"candidates"`
+	PromptFeedback *GeminiChatPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *GeminiUsageMetadata      `json:"usageMetadata,omitempty"`
+}
+
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type GeminiErrorResponse struct {
+	Error GeminiError `json:"error"`
+}
+
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
 }
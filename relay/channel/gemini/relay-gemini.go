@@ -2,6 +2,7 @@ package gemini
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -50,8 +51,41 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest) *GeminiChatReques
 			},
 		}
 	}
+	if tools := textRequest.ParseTools(); len(tools) > 0 {
+		functionDeclarations := make([]GeminiFunctionDeclaration, 0, len(tools))
+		for _, tool := range tools {
+			functionDeclarations = append(functionDeclarations, GeminiFunctionDeclaration{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  cleanFunctionParameters(tool.Function.Parameters),
+			})
+		}
+		geminiRequest.Tools = []GeminiChatTools{
+			{
+				FunctionDeclarations: functionDeclarations,
+			},
+		}
+		geminiRequest.ToolConfig = toolChoiceOpenAI2Gemini(textRequest.ToolChoice)
+	}
+	// gemini identifies function responses by name, the tool_call_id is resolved through the earlier tool calls
+	toolCallNames := make(map[string]string)
 	shouldAddDummyModelMessage := false
 	for _, message := range textRequest.Messages {
+		if message.Role == "tool" {
+			functionResponse := toolMessage2Gemini(message, toolCallNames)
+			// parallel function responses have to be sent in a single content
+			lastContent := len(geminiRequest.Contents) - 1
+			if lastContent >= 0 && len(geminiRequest.Contents[lastContent].Parts) > 0 &&
+				geminiRequest.Contents[lastContent].Parts[0].FunctionResponse != nil {
+				geminiRequest.Contents[lastContent].Parts = append(geminiRequest.Contents[lastContent].Parts, functionResponse)
+			} else {
+				geminiRequest.Contents = append(geminiRequest.Contents, GeminiChatContent{
+					Role:  "user",
+					Parts: []GeminiPart{functionResponse},
+				})
+			}
+			continue
+		}
 		content := GeminiChatContent{
 			Role: message.Role,
 			Parts: []GeminiPart{
@@ -66,6 +100,10 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest) *GeminiChatReques
 		for _, part := range openaiContent {
 
 			if part.Type == dto.ContentTypeText {
+				// null content of assistant tool call messages shall not become an empty part
+				if part.Text == "" {
+					continue
+				}
 				parts = append(parts, GeminiPart{
 					Text: part.Text,
 				})
@@ -83,6 +121,19 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest) *GeminiChatReques
 				})
 			}
 		}
+		for _, toolCall := range message.ParseToolCalls() {
+			toolCallNames[toolCall.ID] = toolCall.Function.Name
+			args := make(map[string]any)
+			if toolCall.Function.Arguments != "" {
+				_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+			}
+			parts = append(parts, GeminiPart{
+				FunctionCall: &GeminiFunctionCall{
+					Name: toolCall.Function.Name,
+					Args: args,
+				},
+			})
+		}
 		content.Parts = parts
 
 		// there's no assistant role in gemini and API shall vomit if Role is not user or model
@@ -113,14 +164,147 @@ func CovertGemini2OpenAI(textRequest dto.GeneralOpenAIRequest) *GeminiChatReques
 	return &geminiRequest
 }
 
+// cleanFunctionParameters drops the JSON schema keywords gemini rejects,
+// an object schema without properties is not accepted either
+func cleanFunctionParameters(parameters any) any {
+	switch schema := parameters.(type) {
+	case map[string]any:
+		if schema["type"] == "object" {
+			if properties, ok := schema["properties"].(map[string]any); !ok || len(properties) == 0 {
+				return nil
+			}
+		}
+		cleaned := make(map[string]any, len(schema))
+		for key, value := range schema {
+			if key == "$schema" || key == "additionalProperties" {
+				continue
+			}
+			if key == "properties" {
+				if properties, ok := value.(map[string]any); ok {
+					cleanedProperties := make(map[string]any, len(properties))
+					for name, property := range properties {
+						cleanedProperties[name] = cleanPropertySchema(property)
+					}
+					value = cleanedProperties
+				}
+			} else if key == "items" {
+				value = cleanPropertySchema(value)
+			}
+			cleaned[key] = value
+		}
+		return cleaned
+	}
+	return parameters
+}
+
+func cleanPropertySchema(property any) any {
+	if schema, ok := property.(map[string]any); ok && schema["type"] == "object" {
+		if properties, ok := schema["properties"].(map[string]any); !ok || len(properties) == 0 {
+			// keep the description of a free form object, drop the empty properties
+			cleaned := make(map[string]any, len(schema))
+			for key, value := range schema {
+				if key != "properties" && key != "$schema" && key != "additionalProperties" {
+					cleaned[key] = value
+				}
+			}
+			return cleaned
+		}
+	}
+	return cleanFunctionParameters(property)
+}
+
+func toolChoiceOpenAI2Gemini(toolChoice any) *GeminiToolConfig {
+	config := &GeminiFunctionCallingConfig{}
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			config.Mode = "NONE"
+		case "required":
+			config.Mode = "ANY"
+		default:
+			config.Mode = "AUTO"
+		}
+	case map[string]any:
+		function, _ := choice["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		if name == "" {
+			return nil
+		}
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{name}
+	default:
+		return nil
+	}
+	return &GeminiToolConfig{
+		FunctionCallingConfig: config,
+	}
+}
+
+func toolMessage2Gemini(message dto.Message, toolCallNames map[string]string) GeminiPart {
+	name := toolCallNames[message.ToolCallId]
+	if name == "" && message.Name != nil {
+		name = *message.Name
+	}
+	text := ""
+	for _, content := range message.ParseContent() {
+		text += content.Text
+	}
+	response := make(map[string]any)
+	if err := json.Unmarshal([]byte(text), &response); err != nil || len(response) == 0 {
+		response = map[string]any{
+			"content": text,
+		}
+	}
+	return GeminiPart{
+		FunctionResponse: &GeminiFunctionResponse{
+			Name:     name,
+			Response: response,
+		},
+	}
+}
+
+// GetResponseText returns the text of the first candidate, function calls
+// are included in JSON form so that the result can be used for token counting
 func (g *GeminiChatResponse) GetResponseText() string {
-	if g == nil {
+	if g == nil || len(g.Candidates) == 0 {
 		return ""
 	}
-	if len(g.Candidates) > 0 && len(g.Candidates[0].Content.Parts) > 0 {
-		return g.Candidates[0].Content.Parts[0].Text
+	responseText := ""
+	for _, part := range g.Candidates[0].Content.Parts {
+		responseText += part.Text
+		if part.FunctionCall != nil {
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			responseText += part.FunctionCall.Name + string(args)
+		}
+	}
+	return responseText
+}
+
+func finishReasonGemini2OpenAI(reason string) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return relaycommon.StopFinishReason
+	}
+}
+
+func functionCall2OpenAI(functionCall *GeminiFunctionCall) dto.ToolCall {
+	args, _ := json.Marshal(functionCall.Args)
+	if functionCall.Args == nil {
+		args = []byte("{}")
+	}
+	return dto.ToolCall{
+		ID:   fmt.Sprintf("call_%s", common.GetUUID()),
+		Type: "function",
+		Function: dto.FunctionCall{
+			Name:      functionCall.Name,
+			Arguments: string(args),
+		},
 	}
-	return ""
 }
 
 func responseGeminiChat2OpenAI(response *GeminiChatResponse) *dto.OpenAITextResponse {
@@ -130,29 +314,67 @@ func responseGeminiChat2OpenAI(response *GeminiChatResponse) *dto.OpenAITextResp
 		Created: common.GetTimestamp(),
 		Choices: make([]dto.OpenAITextResponseChoice, 0, len(response.Candidates)),
 	}
-	content, _ := json.Marshal("")
 	for i, candidate := range response.Candidates {
 		choice := dto.OpenAITextResponseChoice{
 			Index: i,
 			Message: dto.Message{
-				Role:    "assistant",
-				Content: content,
+				Role: "assistant",
 			},
-			FinishReason: relaycommon.StopFinishReason,
+			FinishReason: finishReasonGemini2OpenAI(candidate.FinishReason),
 		}
-		if len(candidate.Content.Parts) > 0 {
-			content, _ = json.Marshal(candidate.Content.Parts[0].Text)
-			choice.Message.Content = content
+		text := ""
+		var toolCalls []dto.ToolCall
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall != nil {
+				toolCalls = append(toolCalls, functionCall2OpenAI(part.FunctionCall))
+			} else {
+				text += part.Text
+			}
+		}
+		if len(toolCalls) > 0 {
+			choice.Message.ToolCalls = toolCalls
+			choice.FinishReason = "tool_calls"
+		}
+		if text != "" || len(toolCalls) == 0 {
+			choice.Message.Content, _ = json.Marshal(text)
 		}
 		fullTextResponse.Choices = append(fullTextResponse.Choices, choice)
 	}
 	return &fullTextResponse
 }
 
-func streamResponseGeminiChat2OpenAI(geminiResponse *GeminiChatResponse) *dto.ChatCompletionsStreamResponse {
+// streamResponseGeminiChat2OpenAI converts a streamGenerateContent chunk, gemini sends
+// every function call in one piece so each of them becomes a complete tool call delta
+func streamResponseGeminiChat2OpenAI(geminiResponse *GeminiChatResponse, toolCallCount *int) *dto.ChatCompletionsStreamResponse {
 	var choice dto.ChatCompletionsStreamResponseChoice
-	choice.Delta.SetContentString(geminiResponse.GetResponseText())
-	choice.FinishReason = &relaycommon.StopFinishReason
+	text := ""
+	finishReason := ""
+	if len(geminiResponse.Candidates) > 0 {
+		candidate := geminiResponse.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			if part.FunctionCall == nil {
+				text += part.Text
+				continue
+			}
+			toolCall := functionCall2OpenAI(part.FunctionCall)
+			index := *toolCallCount
+			toolCall.Index = &index
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, toolCall)
+			*toolCallCount++
+		}
+		if candidate.FinishReason != "" {
+			finishReason = finishReasonGemini2OpenAI(candidate.FinishReason)
+			if *toolCallCount > 0 {
+				finishReason = "tool_calls"
+			}
+		}
+	}
+	if text != "" || len(choice.Delta.ToolCalls) == 0 {
+		choice.Delta.SetContentString(text)
+	}
+	if finishReason != "" {
+		choice.FinishReason = &finishReason
+	}
 	var response dto.ChatCompletionsStreamResponse
 	response.Object = "chat.completion.chunk"
 	response.Model = "gemini"
@@ -160,11 +382,17 @@ func streamResponseGeminiChat2OpenAI(geminiResponse *GeminiChatResponse) *dto.Ch
 	return &response
 }
 
-func geminiChatStreamHandler(c *gin.Context, resp *http.Response) (*dto.OpenAIErrorWithStatusCode, string) {
+// geminiChatStreamHandler reads the SSE (alt=sse) form of streamGenerateContent
+func geminiChatStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
 	responseText := ""
+	responseId := fmt.Sprintf("chatcmpl-%s", common.GetUUID())
+	createdTime := common.GetTimestamp()
+	toolCallCount := 0
+	var usageMetadata *GeminiUsageMetadata
 	dataChan := make(chan string)
 	stopChan := make(chan bool)
 	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
 	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
 		if atEOF && len(data) == 0 {
 			return 0, nil, nil
@@ -179,14 +407,11 @@ func geminiChatStreamHandler(c *gin.Context, resp *http.Response) (*dto.OpenAIEr
 	})
 	go func() {
 		for scanner.Scan() {
-			data := scanner.Text()
-			data = strings.TrimSpace(data)
-			if !strings.HasPrefix(data, "\"text\": \"") {
+			data := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(data, "data:") {
 				continue
 			}
-			data = strings.TrimPrefix(data, "\"text\": \"")
-			data = strings.TrimSuffix(data, "\"")
-			dataChan <- data
+			dataChan <- strings.TrimSpace(strings.TrimPrefix(data, "data:"))
 		}
 		stopChan <- true
 	}()
@@ -194,23 +419,20 @@ func geminiChatStreamHandler(c *gin.Context, resp *http.Response) (*dto.OpenAIEr
 	c.Stream(func(w io.Writer) bool {
 		select {
 		case data := <-dataChan:
-			// this is used to prevent annoying \ related format bug
-			data = fmt.Sprintf("{\"content\": \"%s\"}", data)
-			type dummyStruct struct {
-				Content string `json:"content"`
+			var geminiResponse GeminiChatResponse
+			err := json.Unmarshal([]byte(data), &geminiResponse)
+			if err != nil {
+				common.SysError("error unmarshalling stream response: " + err.Error())
+				return true
 			}
-			var dummy dummyStruct
-			err := json.Unmarshal([]byte(data), &dummy)
-			responseText += dummy.Content
-			var choice dto.ChatCompletionsStreamResponseChoice
-			choice.Delta.SetContentString(dummy.Content)
-			response := dto.ChatCompletionsStreamResponse{
-				Id:      fmt.Sprintf("chatcmpl-%s", common.GetUUID()),
-				Object:  "chat.completion.chunk",
-				Created: common.GetTimestamp(),
-				Model:   "gemini-pro",
-				Choices: []dto.ChatCompletionsStreamResponseChoice{choice},
+			if geminiResponse.UsageMetadata != nil {
+				usageMetadata = geminiResponse.UsageMetadata
 			}
+			responseText += geminiResponse.GetResponseText()
+			response := streamResponseGeminiChat2OpenAI(&geminiResponse, &toolCallCount)
+			response.Id = responseId
+			response.Created = createdTime
+			response.Model = info.UpstreamModelName
 			jsonResponse, err := json.Marshal(response)
 			if err != nil {
 				common.SysError("error marshalling stream response: " + err.Error())
@@ -225,9 +447,9 @@ func geminiChatStreamHandler(c *gin.Context, resp *http.Response) (*dto.OpenAIEr
 	})
 	err := resp.Body.Close()
 	if err != nil {
-		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), ""
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
 	}
-	return nil, responseText
+	return nil, geminiUsage(usageMetadata, responseText, info)
 }
 
 func geminiChatHandler(c *gin.Context, resp *http.Response, promptTokens int, model string) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
@@ -272,3 +494,387 @@ func geminiChatHandler(c *gin.Context, resp *http.Response, promptTokens int, mo
 	_, err = c.Writer.Write(jsonResponse)
 	return nil, &usage
 }
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// geminiCallIds hands out tool call ids for the inbound function calls, gemini
+// pairs function responses with calls by name so the ids are matched in order
+type geminiCallIds struct {
+	count   int
+	pending map[string][]string
+}
+
+func (g *geminiCallIds) call(name string) string {
+	g.count++
+	id := fmt.Sprintf("call_%d", g.count)
+	g.pending[name] = append(g.pending[name], id)
+	return id
+}
+
+func (g *geminiCallIds) response(name string) string {
+	ids := g.pending[name]
+	if len(ids) == 0 {
+		g.count++
+		return fmt.Sprintf("call_%d", g.count)
+	}
+	g.pending[name] = ids[1:]
+	return ids[0]
+}
+
+func geminiContent2OpenAI(content GeminiChatContent, callIds *geminiCallIds) []dto.Message {
+	message := dto.Message{
+		Role: content.Role,
+	}
+	if message.Role == "model" {
+		message.Role = "assistant"
+	} else if message.Role == "" || message.Role == "function" {
+		message.Role = "user"
+	}
+	messages := make([]dto.Message, 0, 1)
+	mediaMessages := make([]dto.MediaMessage, 0, len(content.Parts))
+	var toolCalls []dto.ToolCall
+	for _, part := range content.Parts {
+		if part.FunctionCall != nil {
+			args, _ := json.Marshal(part.FunctionCall.Args)
+			toolCalls = append(toolCalls, dto.ToolCall{
+				ID:   callIds.call(part.FunctionCall.Name),
+				Type: "function",
+				Function: dto.FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(args),
+				},
+			})
+		} else if part.FunctionResponse != nil {
+			response, _ := json.Marshal(part.FunctionResponse.Response)
+			responseContent, _ := json.Marshal(string(response))
+			messages = append(messages, dto.Message{
+				Role:       "tool",
+				Content:    responseContent,
+				ToolCallId: callIds.response(part.FunctionResponse.Name),
+			})
+		} else if part.InlineData != nil {
+			mediaMessages = append(mediaMessages, dto.MediaMessage{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: dto.MessageImageUrl{
+					Url:    fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+					Detail: "auto",
+				},
+			})
+		} else if part.Text != "" {
+			mediaMessages = append(mediaMessages, dto.MediaMessage{
+				Type: dto.ContentTypeText,
+				Text: part.Text,
+			})
+		}
+	}
+	if len(toolCalls) > 0 {
+		message.ToolCalls = toolCalls
+	}
+	if len(mediaMessages) == 0 {
+		if len(toolCalls) == 0 && len(messages) > 0 {
+			return messages
+		}
+		message.Content, _ = json.Marshal("")
+		if len(toolCalls) > 0 {
+			message.Content = nil
+		}
+	} else if len(mediaMessages) == 1 && mediaMessages[0].Type == dto.ContentTypeText {
+		message.Content, _ = json.Marshal(mediaMessages[0].Text)
+	} else {
+		message.Content, _ = json.Marshal(mediaMessages)
+	}
+	// tool results have to directly follow the assistant message holding the calls
+	return append(messages, message)
+}
+
+func toolsGemini2OpenAI(geminiTools []GeminiChatTools) []dto.OpenAITools {
+	var tools []dto.OpenAITools
+	for _, geminiTool := range geminiTools {
+		var functionDeclarations []GeminiFunctionDeclaration
+		jsonData, err := json.Marshal(geminiTool.FunctionDeclarations)
+		if err != nil || json.Unmarshal(jsonData, &functionDeclarations) != nil {
+			continue
+		}
+		for _, functionDeclaration := range functionDeclarations {
+			tools = append(tools, dto.OpenAITools{
+				Type: "function",
+				Function: dto.OpenAIFunction{
+					Name:        functionDeclaration.Name,
+					Description: functionDeclaration.Description,
+					Parameters:  functionDeclaration.Parameters,
+				},
+			})
+		}
+	}
+	return tools
+}
+
+func toolConfigGemini2OpenAI(toolConfig *GeminiToolConfig) any {
+	if toolConfig == nil || toolConfig.FunctionCallingConfig == nil {
+		return nil
+	}
+	config := toolConfig.FunctionCallingConfig
+	switch config.Mode {
+	case "NONE":
+		return "none"
+	case "ANY":
+		if len(config.AllowedFunctionNames) == 1 {
+			return map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": config.AllowedFunctionNames[0],
+				},
+			}
+		}
+		return "required"
+	case "AUTO":
+		return "auto"
+	}
+	return nil
+}
+
+// RequestGemini2OpenAI converts an inbound generateContent request to the
+// OpenAI chat format so that it can be served by any adaptor
+func RequestGemini2OpenAI(geminiRequest GeminiChatRequest, model string, stream bool) *dto.GeneralOpenAIRequest {
+	generationConfig := geminiRequest.GenerationConfig
+	openAIRequest := dto.GeneralOpenAIRequest{
+		Model:       model,
+		Stream:      stream,
+		MaxTokens:   generationConfig.MaxOutputTokens,
+		Temperature: generationConfig.Temperature,
+		TopP:        generationConfig.TopP,
+		TopK:        int(generationConfig.TopK),
+		N:           generationConfig.CandidateCount,
+	}
+	if len(generationConfig.StopSequences) > 0 {
+		openAIRequest.Stop = generationConfig.StopSequences
+	}
+	if tools := toolsGemini2OpenAI(geminiRequest.Tools); len(tools) > 0 {
+		openAIRequest.Tools = tools
+		openAIRequest.ToolChoice = toolConfigGemini2OpenAI(geminiRequest.ToolConfig)
+	}
+	messages := make([]dto.Message, 0, len(geminiRequest.Contents)+1)
+	if geminiRequest.SystemInstruction != nil {
+		system := ""
+		for _, part := range geminiRequest.SystemInstruction.Parts {
+			system += part.Text
+		}
+		if system != "" {
+			content, _ := json.Marshal(system)
+			messages = append(messages, dto.Message{
+				Role:    "system",
+				Content: content,
+			})
+		}
+	}
+	callIds := &geminiCallIds{
+		pending: make(map[string][]string),
+	}
+	for _, content := range geminiRequest.Contents {
+		messages = append(messages, geminiContent2OpenAI(content, callIds)...)
+	}
+	openAIRequest.Messages = messages
+	return &openAIRequest
+}
+
+// ResponseOpenAI2Gemini converts an OpenAI chat completion into a generateContent response
+func ResponseOpenAI2Gemini(textResponse *dto.TextResponse) *GeminiChatResponse {
+	geminiResponse := GeminiChatResponse{
+		Candidates: make([]GeminiChatCandidate, 0, len(textResponse.Choices)),
+		UsageMetadata: &GeminiUsageMetadata{
+			PromptTokenCount:     textResponse.PromptTokens,
+			CandidatesTokenCount: textResponse.CompletionTokens,
+			TotalTokenCount:      textResponse.PromptTokens + textResponse.CompletionTokens,
+		},
+	}
+	for _, choice := range textResponse.Choices {
+		parts := make([]GeminiPart, 0, 1)
+		toolCalls := choice.Message.ParseToolCalls()
+		if text := choice.Message.StringContent(); len(toolCalls) == 0 || text != "" {
+			parts = append(parts, GeminiPart{
+				Text: text,
+			})
+		}
+		for _, toolCall := range toolCalls {
+			args := make(map[string]any)
+			if toolCall.Function.Arguments != "" {
+				_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+			}
+			parts = append(parts, GeminiPart{
+				FunctionCall: &GeminiFunctionCall{
+					Name: toolCall.Function.Name,
+					Args: args,
+				},
+			})
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, GeminiChatCandidate{
+			Content: GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: finishReasonOpenAI2Gemini(choice.FinishReason),
+			Index:        int64(choice.Index),
+		})
+	}
+	return &geminiResponse
+}
+
+// StreamResponseOpenAI2Gemini converts an OpenAI stream chunk into a
+// streamGenerateContent chunk, it returns nil if the chunk carries no text.
+// Tool call deltas are not converted here, gemini sends function calls in one
+// piece so they have to be collected until the end of the stream
+func StreamResponseOpenAI2Gemini(streamResponse *dto.ChatCompletionsStreamResponse) *GeminiChatResponse {
+	geminiResponse := GeminiChatResponse{
+		Candidates: make([]GeminiChatCandidate, 0, len(streamResponse.Choices)),
+	}
+	for _, choice := range streamResponse.Choices {
+		text := choice.Delta.GetContentString()
+		if text == "" {
+			continue
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, GeminiChatCandidate{
+			Content: GeminiChatContent{
+				Role: "model",
+				Parts: []GeminiPart{
+					{
+						Text: text,
+					},
+				},
+			},
+			Index: int64(choice.Index),
+		})
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		return nil
+	}
+	return &geminiResponse
+}
+
+// GeminiNativeStreamHandler relays a streamGenerateContent response untouched,
+// either in SSE (alt=sse) or in JSON array form, and reads its usage metadata
+func GeminiNativeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	isSSE := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+	if isSSE {
+		service.SetEventStreamHeaders(c)
+	} else {
+		c.Writer.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+	var responseBody bytes.Buffer
+	buffer := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buffer)
+		if n > 0 {
+			responseBody.Write(buffer[:n])
+			_, _ = c.Writer.Write(buffer[:n])
+			c.Writer.Flush()
+		}
+		if err != nil {
+			break
+		}
+	}
+	err := resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+
+	var geminiResponses []GeminiChatResponse
+	if isSSE {
+		for _, line := range strings.Split(responseBody.String(), "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			var geminiResponse GeminiChatResponse
+			if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &geminiResponse); err == nil {
+				geminiResponses = append(geminiResponses, geminiResponse)
+			}
+		}
+	} else {
+		_ = json.Unmarshal(responseBody.Bytes(), &geminiResponses)
+	}
+	responseText := ""
+	var usageMetadata *GeminiUsageMetadata
+	for i := range geminiResponses {
+		responseText += geminiResponses[i].GetResponseText()
+		if geminiResponses[i].UsageMetadata != nil {
+			usageMetadata = geminiResponses[i].UsageMetadata
+		}
+	}
+	return nil, geminiUsage(usageMetadata, responseText, info)
+}
+
+// GeminiNativeHandler relays a generateContent response untouched and reads its usage metadata
+func GeminiNativeHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.OpenAIErrorWithStatusCode, *dto.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var geminiResponse GeminiChatResponse
+	err = json.Unmarshal(responseBody, &geminiResponse)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), nil
+	}
+	return nil, geminiUsage(geminiResponse.UsageMetadata, geminiResponse.GetResponseText(), info)
+}
+
+func geminiUsage(usageMetadata *GeminiUsageMetadata, responseText string, info *relaycommon.RelayInfo) *dto.Usage {
+	if usageMetadata == nil || usageMetadata.CandidatesTokenCount == 0 {
+		usage, _ := service.ResponseText2Usage(responseText, info.UpstreamModelName, info.PromptTokens)
+		return usage
+	}
+	usage := &dto.Usage{
+		PromptTokens:     usageMetadata.PromptTokenCount,
+		CompletionTokens: usageMetadata.CandidatesTokenCount,
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = info.PromptTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// ErrorOpenAI2Gemini converts a relay error into the Gemini error body
+func ErrorOpenAI2Gemini(openaiErr *dto.OpenAIErrorWithStatusCode) *GeminiErrorResponse {
+	status := "INTERNAL"
+	switch openaiErr.StatusCode {
+	case http.StatusBadRequest:
+		status = "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		status = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		status = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		status = "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		status = "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		status = "UNAVAILABLE"
+	}
+	return &GeminiErrorResponse{
+		Error: GeminiError{
+			Code:    openaiErr.StatusCode,
+			Message: openaiErr.Error.Message,
+			Status:  status,
+		},
+	}
+}
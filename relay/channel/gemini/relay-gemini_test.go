@@ -0,0 +1,220 @@
+package gemini
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// assertJSON compares the JSON encoding of got with the expected JSON document
+func assertJSON(t *testing.T, got any, want string) {
+	t.Helper()
+	gotData, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(gotData, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s, want %s", gotData, want)
+	}
+}
+
+// clearToolCallIds checks the generated tool call ids and blanks them for the comparison
+func clearToolCallIds(t *testing.T, toolCalls []dto.ToolCall) {
+	t.Helper()
+	for i := range toolCalls {
+		if !strings.HasPrefix(toolCalls[i].ID, "call_") {
+			t.Errorf("tool call id %q has no call_ prefix", toolCalls[i].ID)
+		}
+		toolCalls[i].ID = ""
+	}
+}
+
+func TestCovertGemini2OpenAITools(t *testing.T) {
+	tools := `[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"$schema":"http://json-schema.org/draft-07/schema#","type":"object","additionalProperties":false,
+			"properties":{"city":{"type":"string"},"options":{"type":"object","description":"free form","properties":{}}}}}},
+		{"type":"function","function":{"name":"now","parameters":{"type":"object","properties":{}}}}]`
+	tests := []struct {
+		name    string
+		request string
+		want    string
+	}{
+		{
+			"tools and auto",
+			`{"model":"gemini-1.5-pro","tool_choice":"auto","tools":` + tools + `,"messages":[{"role":"user","content":"Weather?"}]}`,
+			`{"contents":[{"role":"user","parts":[{"text":"Weather?"}]}],
+				"tools":[{"functionDeclarations":[
+					{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"},"options":{"type":"object","description":"free form"}}}},
+					{"name":"now"}]}],
+				"tool_config":{"function_calling_config":{"mode":"AUTO"}}}`,
+		},
+		{
+			"named function",
+			`{"model":"gemini-1.5-pro","tool_choice":{"type":"function","function":{"name":"now"}},"tools":` + tools + `,"messages":[{"role":"user","content":"Time?"}]}`,
+			`{"contents":[{"role":"user","parts":[{"text":"Time?"}]}],
+				"tools":[{"functionDeclarations":[
+					{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"},"options":{"type":"object","description":"free form"}}}},
+					{"name":"now"}]}],
+				"tool_config":{"function_calling_config":{"mode":"ANY","allowed_function_names":["now"]}}}`,
+		},
+		{
+			"tool calls and results",
+			`{"model":"gemini-1.5-pro","tool_choice":"none","tools":` + tools + `,"messages":[
+				{"role":"user","content":"Weather in Paris and Rome?"},
+				{"role":"assistant","content":null,"tool_calls":[
+					{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"id":"call_2","type":"function","function":{"name":"now","arguments":""}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"{\"temperature\":18}"},
+				{"role":"tool","tool_call_id":"call_2","content":"12:00"}]}`,
+			`{"contents":[
+					{"role":"user","parts":[{"text":"Weather in Paris and Rome?"}]},
+					{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}},{"functionCall":{"name":"now","args":{}}}]},
+					{"role":"user","parts":[
+						{"functionResponse":{"name":"get_weather","response":{"temperature":18}}},
+						{"functionResponse":{"name":"now","response":{"content":"12:00"}}}]}],
+				"tools":[{"functionDeclarations":[
+					{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"},"options":{"type":"object","description":"free form"}}}},
+					{"name":"now"}]}],
+				"tool_config":{"function_calling_config":{"mode":"NONE"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var textRequest dto.GeneralOpenAIRequest
+			if err := json.Unmarshal([]byte(tt.request), &textRequest); err != nil {
+				t.Fatal(err)
+			}
+			geminiRequest := CovertGemini2OpenAI(textRequest)
+			assertJSON(t, map[string]any{
+				"contents":    geminiRequest.Contents,
+				"tools":       geminiRequest.Tools,
+				"tool_config": geminiRequest.ToolConfig,
+			}, tt.want)
+		})
+	}
+}
+
+func TestResponseGeminiChat2OpenAI(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{
+			"text",
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"finishReason":"MAX_TOKENS","index":0}]}`,
+			`{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"length"}`,
+		},
+		{
+			"function calls",
+			`{"candidates":[{"content":{"role":"model","parts":[
+				{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}},
+				{"functionCall":{"name":"now"}}]},"finishReason":"STOP","index":0}]}`,
+			`{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+					{"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+					{"type":"function","function":{"name":"now","arguments":"{}"}}]},
+				"finish_reason":"tool_calls"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var geminiResponse GeminiChatResponse
+			if err := json.Unmarshal([]byte(tt.response), &geminiResponse); err != nil {
+				t.Fatal(err)
+			}
+			response := responseGeminiChat2OpenAI(&geminiResponse)
+			if len(response.Choices) != 1 {
+				t.Fatalf("choices = %+v", response.Choices)
+			}
+			choice := response.Choices[0]
+			toolCalls := choice.Message.ParseToolCalls()
+			clearToolCallIds(t, toolCalls)
+			if toolCalls != nil {
+				choice.Message.ToolCalls = toolCalls
+			}
+			assertJSON(t, choice, tt.want)
+		})
+	}
+}
+
+func TestStreamResponseGeminiChat2OpenAI(t *testing.T) {
+	// chunks recorded from streamGenerateContent answering with text and then two function calls
+	chunks := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Checking."}]},"index":0}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"index":0}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Rome"}}}]},"finishReason":"STOP","index":0}],
+			"usageMetadata":{"promptTokenCount":30,"candidatesTokenCount":12,"totalTokenCount":42}}`,
+	}
+	toolCallCount := 0
+	var choices []dto.ChatCompletionsStreamResponseChoice
+	for _, chunk := range chunks {
+		var geminiResponse GeminiChatResponse
+		if err := json.Unmarshal([]byte(chunk), &geminiResponse); err != nil {
+			t.Fatal(err)
+		}
+		response := streamResponseGeminiChat2OpenAI(&geminiResponse, &toolCallCount)
+		for _, choice := range response.Choices {
+			clearToolCallIds(t, choice.Delta.ToolCalls)
+			choices = append(choices, choice)
+		}
+	}
+	assertJSON(t, choices, `[
+		{"index":0,"delta":{"content":"Checking."},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":0,"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"logprobs":null,"finish_reason":null},
+		{"index":0,"delta":{"tool_calls":[{"index":1,"type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},"logprobs":null,"finish_reason":"tool_calls"}]`)
+	if toolCallCount != 2 {
+		t.Errorf("toolCallCount = %d, want 2", toolCallCount)
+	}
+}
+
+func TestRequestGemini2OpenAITools(t *testing.T) {
+	request := `{"systemInstruction":{"parts":[{"text":"Be brief."}]},
+		"tools":[{"function_declarations":[{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]}],
+		"toolConfig":{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_weather"]}},
+		"contents":[
+			{"role":"user","parts":[{"text":"Weather in Paris and Rome?"}]},
+			{"role":"model","parts":[
+				{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}},
+				{"functionCall":{"name":"get_weather","args":{"city":"Rome"}}}]},
+			{"role":"function","parts":[
+				{"functionResponse":{"name":"get_weather","response":{"temperature":18}}},
+				{"functionResponse":{"name":"get_weather","response":{"temperature":24}}}]}]}`
+	var geminiRequest GeminiChatRequest
+	if err := json.Unmarshal([]byte(request), &geminiRequest); err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, RequestGemini2OpenAI(geminiRequest, "gemini-1.5-pro", false), `{"model":"gemini-1.5-pro",
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+		"tool_choice":{"type":"function","function":{"name":"get_weather"}},
+		"messages":[
+			{"role":"system","content":"Be brief."},
+			{"role":"user","content":"Weather in Paris and Rome?"},
+			{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+			{"role":"tool","content":"{\"temperature\":18}","tool_call_id":"call_1"},
+			{"role":"tool","content":"{\"temperature\":24}","tool_call_id":"call_2"}]}`)
+}
+
+func TestResponseOpenAI2GeminiTools(t *testing.T) {
+	response := `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[
+			{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"id":"call_2","type":"function","function":{"name":"now","arguments":""}}]},"finish_reason":"tool_calls"}],
+		"usage":{"prompt_tokens":20,"completion_tokens":30,"total_tokens":50}}`
+	var textResponse dto.TextResponse
+	if err := json.Unmarshal([]byte(response), &textResponse); err != nil {
+		t.Fatal(err)
+	}
+	assertJSON(t, ResponseOpenAI2Gemini(&textResponse), `{"candidates":[{"content":{"role":"model","parts":[
+			{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}},
+			{"functionCall":{"name":"now","args":{}}}]},"finishReason":"STOP","index":0}],
+		"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":30,"totalTokenCount":50}}`)
+}
@@ -11,6 +11,8 @@ type OllamaRequest struct {
 	Topp        float64       `json:"top_p,omitempty"`
 	TopK        int           `json:"top_k,omitempty"`
 	Stop        any           `json:"stop,omitempty"`
+	Tools       any           `json:"tools,omitempty"`
+	ToolChoice  any           `json:"tool_choice,omitempty"`
 }
 
 type OllamaEmbeddingRequest struct {
@@ -16,8 +16,11 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) *OllamaRequest {
 	messages := make([]dto.Message, 0, len(request.Messages))
 	for _, message := range request.Messages {
 		messages = append(messages, dto.Message{
-			Role:    message.Role,
-			Content: message.Content,
+			Role:       message.Role,
+			Content:    message.Content,
+			Name:       message.Name,
+			ToolCalls:  message.ToolCalls,
+			ToolCallId: message.ToolCallId,
 		})
 	}
 	str, ok := request.Stop.(string)
@@ -36,6 +39,8 @@ func requestOpenAI2Ollama(request dto.GeneralOpenAIRequest) *OllamaRequest {
 		Topp:        request.TopP,
 		TopK:        request.TopK,
 		Stop:        Stop,
+		Tools:       request.Tools,
+		ToolChoice:  request.ToolChoice,
 	}
 }
 
@@ -0,0 +1,43 @@
+package ollama
+
+import (
+	"encoding/json"
+	"one-api/dto"
+	"reflect"
+	"testing"
+)
+
+func TestRequestOpenAI2OllamaTools(t *testing.T) {
+	request := `{"model":"llama3.1","stream":true,"stop":"END","tool_choice":"auto",
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+		"messages":[
+			{"role":"user","content":"Weather in Paris and Rome?"},
+			{"role":"assistant","content":"","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"18C"},
+			{"role":"tool","tool_call_id":"call_2","content":"24C"}]}`
+	want := `{"model":"llama3.1","stream":true,"stop":["END"],"tool_choice":"auto",
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}],
+		"messages":[
+			{"role":"user","content":"Weather in Paris and Rome?"},
+			{"role":"assistant","content":"","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Rome\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"18C"},
+			{"role":"tool","tool_call_id":"call_2","content":"24C"}]}`
+	var textRequest dto.GeneralOpenAIRequest
+	if err := json.Unmarshal([]byte(request), &textRequest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(requestOpenAI2Ollama(textRequest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	_ = json.Unmarshal(got, &gotValue)
+	_ = json.Unmarshal([]byte(want), &wantValue)
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("requestOpenAI2Ollama = %s, want %s", got, want)
+	}
+}
@@ -26,6 +26,7 @@ type RelayInfo struct {
 	ApiKey            string
 	Organization      string
 	BaseUrl           string
+	Proxy             string
 }
 
 func GenRelayInfo(c *gin.Context) *RelayInfo {
@@ -55,6 +56,7 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 		ApiVersion:     c.GetString("api_version"),
 		ApiKey:         strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 		Organization:   c.GetString("channel_organization"),
+		Proxy:          c.GetString("proxy"),
 	}
 	if info.BaseUrl == "" {
 		info.BaseUrl = common.ChannelBaseURLs[channelType]
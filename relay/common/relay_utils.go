@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -66,3 +67,32 @@ func GetAPIVersion(c *gin.Context) string {
 	}
 	return apiVersion
 }
+
+// GetGeminiModelAction splits a native Gemini path such as
+// /v1beta/models/gemini-pro:generateContent into its model and action
+func GetGeminiModelAction(path string) (string, string) {
+	if i := strings.Index(path, "/models/"); i >= 0 {
+		path = path[i+len("/models/"):]
+	}
+	if i := strings.LastIndex(path, ":"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// UpstreamContext returns the context upstream requests are bound to, only hedged attempts
+// carry one so that the attempt which lost the race can be cancelled
+func UpstreamContext(c *gin.Context) context.Context {
+	if ctx, ok := c.Get("upstream_context"); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// HedgeLost reports whether the request is a hedged attempt whose race was won by the other attempt
+func HedgeLost(c *gin.Context) bool {
+	if lost, ok := c.Get("hedge_lost"); ok {
+		return lost.(func() bool)()
+	}
+	return false
+}
@@ -26,6 +26,10 @@ const (
 	RelayModeMidjourneyModal
 	RelayModeMidjourneyShorten
 	RelayModeSwapFace
+	RelayModeClaudeMessages
+	RelayModeGemini
+	RelayModeImagesEdits
+	RelayModeImagesVariations
 )
 
 func Path2RelayMode(path string) int {
@@ -42,6 +46,10 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeModerations
 	} else if strings.HasPrefix(path, "/v1/images/generations") {
 		relayMode = RelayModeImagesGenerations
+	} else if strings.HasPrefix(path, "/v1/images/edits") {
+		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/audio/speech") {
@@ -50,6 +58,10 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeAudioTranscription
 	} else if strings.HasPrefix(path, "/v1/audio/translations") {
 		relayMode = RelayModeAudioTranslation
+	} else if strings.HasPrefix(path, "/v1/messages") {
+		relayMode = RelayModeClaudeMessages
+	} else if strings.HasPrefix(path, "/v1beta/models/") {
+		relayMode = RelayModeGemini
 	}
 	return relayMode
 }
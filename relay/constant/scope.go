@@ -0,0 +1,62 @@
+package constant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the api surfaces a token can be limited to
+const (
+	ScopeChat       = "chat"
+	ScopeEmbeddings = "embeddings"
+	ScopeImages     = "images"
+	ScopeAudio      = "audio"
+	ScopeMidjourney = "midjourney"
+)
+
+var Scopes = []string{ScopeChat, ScopeEmbeddings, ScopeImages, ScopeAudio, ScopeMidjourney}
+
+// RelayMode2Scope returns the scope a relay mode belongs to, an empty string for unknown modes
+func RelayMode2Scope(relayMode int) string {
+	switch relayMode {
+	case RelayModeChatCompletions, RelayModeCompletions, RelayModeEdits, RelayModeModerations,
+		RelayModeClaudeMessages, RelayModeGemini:
+		return ScopeChat
+	case RelayModeEmbeddings:
+		return ScopeEmbeddings
+	case RelayModeImagesGenerations, RelayModeImagesEdits, RelayModeImagesVariations:
+		return ScopeImages
+	case RelayModeAudioSpeech, RelayModeAudioTranscription, RelayModeAudioTranslation:
+		return ScopeAudio
+	case RelayModeMidjourneyImagine, RelayModeMidjourneyDescribe, RelayModeMidjourneyBlend,
+		RelayModeMidjourneyChange, RelayModeMidjourneySimpleChange, RelayModeMidjourneyNotify,
+		RelayModeMidjourneyTaskFetch, RelayModeMidjourneyTaskImageSeed, RelayModeMidjourneyTaskFetchByCondition,
+		RelayModeMidjourneyAction, RelayModeMidjourneyModal, RelayModeMidjourneyShorten, RelayModeSwapFace:
+		return ScopeMidjourney
+	}
+	return ""
+}
+
+// NormalizeScopes validates a comma separated scope list and returns it trimmed and without duplicates
+func NormalizeScopes(scopes string) (string, error) {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, scope := range strings.Split(scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		valid := false
+		for _, s := range Scopes {
+			if s == scope {
+				valid = true
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("无效的令牌权限范围：%s", scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return strings.Join(result, ","), nil
+}
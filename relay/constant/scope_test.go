@@ -0,0 +1,67 @@
+package constant
+
+import (
+	"testing"
+)
+
+func TestRelayMode2Scope(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v1/chat/completions", ScopeChat},
+		{"/v1/completions", ScopeChat},
+		{"/v1/moderations", ScopeChat},
+		{"/v1/edits", ScopeChat},
+		{"/v1/messages", ScopeChat},
+		{"/v1beta/models/gemini-pro:generateContent", ScopeChat},
+		{"/v1/embeddings", ScopeEmbeddings},
+		{"/v1/engines/text-embedding-ada-002/embeddings", ScopeEmbeddings},
+		{"/v1/images/generations", ScopeImages},
+		{"/v1/images/edits", ScopeImages},
+		{"/v1/images/variations", ScopeImages},
+		{"/v1/audio/speech", ScopeAudio},
+		{"/v1/audio/transcriptions", ScopeAudio},
+		{"/v1/audio/translations", ScopeAudio},
+		{"/v1/unknown", ""},
+	}
+	for _, tt := range tests {
+		if got := RelayMode2Scope(Path2RelayMode(tt.path)); got != tt.want {
+			t.Errorf("scope of %s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	for _, path := range []string{"/mj/submit/imagine", "/mj/submit/action", "/mj/insight-face/swap", "/mj/task/1/fetch", "/mj/notify"} {
+		if got := RelayMode2Scope(Path2RelayModeMidjourney(path)); got != ScopeMidjourney {
+			t.Errorf("scope of %s = %q, want %q", path, got, ScopeMidjourney)
+		}
+	}
+	// every relay mode needs a scope, otherwise scoped tokens cannot call it
+	for mode := RelayModeUnknown + 1; mode <= RelayModeImagesVariations; mode++ {
+		if RelayMode2Scope(mode) == "" {
+			t.Errorf("relay mode %d has no scope", mode)
+		}
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		scopes  string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"chat", "chat", false},
+		{" chat , images ", "chat,images", false},
+		{"chat,chat,embeddings", "chat,embeddings", false},
+		{",,audio,", "audio", false},
+		{"midjourney,audio", "midjourney,audio", false},
+		{"chat,admin", "", true},
+		{"Chat", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeScopes(tt.scopes)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeScopes(%q) = %q, %v, want %q, error %v", tt.scopes, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
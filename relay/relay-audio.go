@@ -111,17 +111,11 @@ func AudioHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	}()
 
 	// map model name
-	modelMapping := c.GetString("model_mapping")
-	if modelMapping != "" {
-		modelMap := make(map[string]string)
-		err := json.Unmarshal([]byte(modelMapping), &modelMap)
-		if err != nil {
-			return service.OpenAIErrorWrapper(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
-		}
-		if modelMap[audioRequest.Model] != "" {
-			audioRequest.Model = modelMap[audioRequest.Model]
-		}
+	upstreamModel, _, openaiErr := mapRequestModel(c, audioRequest.Model)
+	if openaiErr != nil {
+		return openaiErr
 	}
+	audioRequest.Model = upstreamModel
 
 	baseURL := common.ChannelBaseURLs[channelType]
 	requestURL := c.Request.URL.String()
@@ -155,8 +149,16 @@ func AudioHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 
 	req.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
 	req.Header.Set("Accept", c.Request.Header.Get("Accept"))
+	err = service.ApplyHeaderOverride(req.Header, c.GetString("header_override"), strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "), audioRequest.Model)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "setup_header_override_failed", http.StatusInternalServerError)
+	}
 
-	resp, err := service.GetHttpClient().Do(req)
+	client, err := service.GetHttpClientWithProxy(c.GetString("proxy"))
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "get_http_client_failed", http.StatusInternalServerError)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
@@ -190,6 +192,9 @@ func AudioHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 			if ratio != 0 && quota <= 0 {
 				quota = 1
 			}
+			if c.GetInt("token_tpm_limit") > 0 {
+				common.RecordTokenTokens(tokenId, promptTokens)
+			}
 			quotaDelta := quota - preConsumedQuota
 			err := model.PostConsumeTokenQuota(tokenId, userQuota, quotaDelta, preConsumedQuota, true)
 			if err != nil {
@@ -209,6 +214,7 @@ func AudioHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 				model.UpdateUserUsedQuotaAndRequestCount(userId, quota)
 				channelId := c.GetInt("channel_id")
 				model.UpdateChannelUsedQuota(channelId, quota)
+				model.UpdateChannelKeyUsage(c.GetInt("channel_key_id"), quota)
 			}
 		}()
 	}(c.Request.Context())
@@ -0,0 +1,196 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel/claude"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+)
+
+func getAndValidateClaudeRequest(c *gin.Context) (*claude.ClaudeRequest, error) {
+	claudeRequest := &claude.ClaudeRequest{}
+	err := common.UnmarshalBodyReusable(c, claudeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if claudeRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if claudeRequest.MaxTokens == 0 {
+		return nil, errors.New("max_tokens is required")
+	}
+	if len(claudeRequest.Messages) == 0 {
+		return nil, errors.New("field messages is required")
+	}
+	return claudeRequest, nil
+}
+
+// ClaudeHelper serves the native Anthropic Messages API (/v1/messages).
+// Claude channels receive the request untouched, any other channel is served
+// through the OpenAI format and the response is converted back.
+func ClaudeHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	claudeRequest, err := getAndValidateClaudeRequest(c)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("getAndValidateClaudeRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_claude_request", http.StatusBadRequest)
+	}
+	relayInfo.IsStream = claudeRequest.Stream
+	originModel := claudeRequest.Model
+
+	textRequest, err := claude.RequestClaude2OpenAI(*claudeRequest)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusBadRequest)
+	}
+
+	// map model name
+	upstreamModel, isModelMapped, openaiErr := mapRequestModel(c, textRequest.Model)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	textRequest.Model = upstreamModel
+	relayInfo.UpstreamModelName = textRequest.Model
+	modelPrice, success := common.GetModelPrice(textRequest.Model, false)
+	groupRatio := common.GetGroupRatio(relayInfo.Group)
+
+	var preConsumedQuota int
+	var ratio float64
+	var modelRatio float64
+
+	if constant.ShouldCheckPromptSensitive() {
+		err = service.CheckSensitiveMessages(textRequest.Messages)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
+		}
+	}
+
+	promptTokens, err := service.CountTokenChatRequest(*textRequest, textRequest.Model)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
+	}
+	relayInfo.SetPromptTokens(promptTokens)
+
+	if !success {
+		preConsumedTokens := promptTokens + int(textRequest.MaxTokens)
+		modelRatio = common.GetModelRatio(textRequest.Model)
+		ratio = modelRatio * groupRatio
+		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
+	} else {
+		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatio)
+	}
+
+	// pre-consume quota 预消耗配额
+	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, preConsumedQuota, relayInfo)
+	if openaiErr != nil {
+		return openaiErr
+	}
+
+	var usage *dto.Usage
+	if relayInfo.ApiType == relayconstant.APITypeAnthropic {
+		usage, openaiErr = relayClaudePassthrough(c, relayInfo, isModelMapped)
+	} else {
+		usage, openaiErr = relayConverted(c, relayInfo, textRequest, &claudeResponseConverter{
+			model: originModel,
+			stream: &claude.StreamConverter{
+				Id:           fmt.Sprintf("msg_%s", common.GetUUID()),
+				Model:        originModel,
+				PromptTokens: relayInfo.PromptTokens,
+			},
+		})
+	}
+	if openaiErr != nil {
+		returnPreConsumedQuota(c, relayInfo.TokenId, userQuota, preConsumedQuota)
+		// reset status code 重置状态码
+		service.ResetStatusCode(openaiErr, c.GetString("status_code_mapping"))
+		return openaiErr
+	}
+	postConsumeQuota(c, relayInfo, *textRequest, usage, ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, success)
+	return nil
+}
+
+// relayClaudePassthrough sends the original request body to a Claude channel,
+// only rewriting the model name when it is mapped
+func relayClaudePassthrough(c *gin.Context, relayInfo *relaycommon.RelayInfo, isModelMapped bool) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	adaptor := &claude.Adaptor{RequestMode: claude.RequestModeMessage}
+	var requestBody io.Reader
+	if isModelMapped {
+		requestMap := make(map[string]json.RawMessage)
+		err := common.UnmarshalBodyReusable(c, &requestMap)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "unmarshal_request_body_failed", http.StatusBadRequest)
+		}
+		requestMap["model"], _ = json.Marshal(relayInfo.UpstreamModelName)
+		jsonData, err := json.Marshal(requestMap)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonData)
+	} else {
+		requestBody = c.Request.Body
+	}
+	requestBody, openaiErr := applyParamOverride(c, requestBody, relayInfo.UpstreamModelName)
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+
+	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp)
+	}
+	relayInfo.IsStream = relayInfo.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+
+	var usage *dto.Usage
+	if relayInfo.IsStream {
+		openaiErr, usage = claude.ClaudeNativeStreamHandler(c, resp, relayInfo)
+	} else {
+		openaiErr, usage = claude.ClaudeNativeHandler(c, resp, relayInfo)
+	}
+	return usage, openaiErr
+}
+
+// claudeResponseConverter renders OpenAI formatted adaptor output as Claude
+// Messages responses and stream events
+type claudeResponseConverter struct {
+	model  string
+	stream *claude.StreamConverter
+}
+
+func (r *claudeResponseConverter) renderEvents(events []claude.ClaudeStreamEvent) []byte {
+	var buffer bytes.Buffer
+	for _, event := range events {
+		jsonData, err := json.Marshal(event)
+		if err != nil {
+			common.SysError("error marshalling stream event: " + err.Error())
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, jsonData))
+	}
+	return buffer.Bytes()
+}
+
+func (r *claudeResponseConverter) StreamChunk(streamResponse *dto.ChatCompletionsStreamResponse) []byte {
+	return r.renderEvents(r.stream.Convert(streamResponse))
+}
+
+func (r *claudeResponseConverter) StreamFinish(usage *dto.Usage) []byte {
+	return r.renderEvents(r.stream.Finish(usage))
+}
+
+func (r *claudeResponseConverter) Response(textResponse *dto.TextResponse) any {
+	return claude.ResponseOpenAI2Claude(textResponse, r.model)
+}
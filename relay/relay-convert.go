@@ -0,0 +1,170 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"strings"
+)
+
+// responseConverter renders the OpenAI formatted output of an adaptor in the
+// format of the inbound API (Claude Messages, Gemini generateContent, ...)
+type responseConverter interface {
+	StreamChunk(streamResponse *dto.ChatCompletionsStreamResponse) []byte
+	StreamFinish(usage *dto.Usage) []byte
+	Response(textResponse *dto.TextResponse) any
+}
+
+// relayConverted serves a request of a native inbound API with any channel,
+// by sending it in the OpenAI chat format and converting the output back
+func relayConverted(c *gin.Context, relayInfo *relaycommon.RelayInfo, textRequest *dto.GeneralOpenAIRequest, converter responseConverter) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	relayInfo.RelayMode = relayconstant.RelayModeChatCompletions
+	relayInfo.RequestURLPath = "/v1/chat/completions"
+
+	adaptor := GetAdaptor(relayInfo.ApiType)
+	if adaptor == nil {
+		return nil, service.OpenAIErrorWrapperLocal(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(relayInfo, *textRequest)
+	var convertedRequest any = textRequest
+	if relayInfo.ApiType != relayconstant.APITypeOpenAI {
+		var err error
+		convertedRequest, err = adaptor.ConvertRequest(c, relayInfo.RelayMode, textRequest)
+		if err != nil {
+			return nil, service.OpenAIErrorWrapperLocal(err, "convert_request_failed", http.StatusInternalServerError)
+		}
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+	requestBody, openaiErr := applyParamOverride(c, bytes.NewBuffer(jsonData), relayInfo.UpstreamModelName)
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp != nil {
+		relayInfo.IsStream = relayInfo.IsStream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+		if resp.StatusCode != http.StatusOK {
+			return nil, service.RelayErrorHandler(resp)
+		}
+	}
+
+	writer := &convertResponseWriter{
+		ResponseWriter: c.Writer,
+		isStream:       relayInfo.IsStream,
+		converter:      converter,
+	}
+	c.Writer = writer
+	usage, openaiErr := adaptor.DoResponse(c, resp, relayInfo)
+	c.Writer = writer.ResponseWriter
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	writer.finish(usage)
+	return usage, nil
+}
+
+// convertResponseWriter sits between an adaptor and the client, feeding the
+// OpenAI formatted output through a responseConverter
+type convertResponseWriter struct {
+	gin.ResponseWriter
+	isStream   bool
+	statusCode int
+	buffer     bytes.Buffer
+	converter  responseConverter
+}
+
+func (w *convertResponseWriter) WriteHeader(statusCode int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.statusCode = statusCode
+}
+
+func (w *convertResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *convertResponseWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if !w.isStream {
+		return len(data), nil
+	}
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// keep the incomplete line for the next write
+			w.buffer.Reset()
+			w.buffer.WriteString(line)
+			break
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			continue
+		}
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+			common.SysError("error unmarshalling stream response: " + err.Error())
+			continue
+		}
+		w.writeStream(w.converter.StreamChunk(&streamResponse))
+	}
+	return len(data), nil
+}
+
+func (w *convertResponseWriter) writeStream(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	_, _ = w.ResponseWriter.Write(data)
+	w.ResponseWriter.Flush()
+}
+
+// finish flushes the converted response once the adaptor is done
+func (w *convertResponseWriter) finish(usage *dto.Usage) {
+	if w.isStream {
+		w.writeStream(w.converter.StreamFinish(usage))
+		return
+	}
+	w.Header().Del("Content-Length")
+	statusCode := w.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	var textResponse dto.TextResponse
+	if err := json.Unmarshal(w.buffer.Bytes(), &textResponse); err != nil {
+		common.SysError("error unmarshalling response: " + err.Error())
+		w.ResponseWriter.WriteHeader(statusCode)
+		_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		return
+	}
+	if usage != nil {
+		textResponse.Usage = *usage
+	}
+	jsonData, err := json.Marshal(w.converter.Response(&textResponse))
+	if err != nil {
+		common.SysError("error marshalling response: " + err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(statusCode)
+	_, _ = w.ResponseWriter.Write(jsonData)
+}
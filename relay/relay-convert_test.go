@@ -0,0 +1,121 @@
+package relay
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	"one-api/relay/channel/claude"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newConvertResponseWriter(isStream bool) (*convertResponseWriter, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	return &convertResponseWriter{
+		ResponseWriter: c.Writer,
+		isStream:       isStream,
+		converter: &claudeResponseConverter{
+			model:  "claude-3-haiku",
+			stream: &claude.StreamConverter{Id: "msg_1", Model: "claude-3-haiku", PromptTokens: 5},
+		},
+	}, recorder
+}
+
+func TestConvertResponseWriterStream(t *testing.T) {
+	// an OpenAI stream as written by an adaptor, lines may be split across writes
+	writes := []string{
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n",
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}",
+		"]}\n\ndata: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"function\":{\"name\":\"now\",\"arguments\":\"{}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n",
+		": keep-alive\n\n",
+		"data: [DONE]\n\n",
+	}
+	writer, recorder := newConvertResponseWriter(true)
+	writer.WriteHeader(http.StatusOK)
+	for _, data := range writes {
+		if n, err := writer.Write([]byte(data)); n != len(data) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	writer.finish(&dto.Usage{PromptTokens: 5, CompletionTokens: 7})
+
+	var types []string
+	for _, match := range regexp.MustCompile(`(?m)^event: (\w+)$`).FindAllStringSubmatch(recorder.Body.String(), -1) {
+		types = append(types, match[1])
+	}
+	want := "message_start content_block_start content_block_delta content_block_delta content_block_stop " +
+		"content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	for _, fragment := range []string{`"text":"Hel"`, `"text":"lo"`, `"id":"call_1"`, `"partial_json":"{}"`, `"stop_reason":"tool_use"`, `"output_tokens":7`} {
+		if !strings.Contains(recorder.Body.String(), fragment) {
+			t.Errorf("the stream misses %s", fragment)
+		}
+	}
+}
+
+func TestConvertResponseWriterResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			"chat completion",
+			0,
+			`{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`,
+			http.StatusOK,
+			`{"type":"message","role":"assistant","content":[{"type":"text","text":"Hello"}],"model":"claude-3-haiku","stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":5,"output_tokens":7}}`,
+		},
+		{
+			"not json",
+			http.StatusBadGateway,
+			`upstream failure`,
+			http.StatusBadGateway,
+			``,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, recorder := newConvertResponseWriter(false)
+			if tt.statusCode != 0 {
+				writer.WriteHeader(tt.statusCode)
+			}
+			// the adaptor writes its response in pieces
+			_, _ = writer.WriteString(tt.body[:10])
+			_, _ = writer.Write([]byte(tt.body[10:]))
+			if recorder.Body.Len() != 0 {
+				t.Fatal("the response was written before the adaptor finished")
+			}
+			writer.finish(&dto.Usage{PromptTokens: 5, CompletionTokens: 7, TotalTokens: 12})
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.want == "" {
+				if recorder.Body.String() != tt.body {
+					t.Errorf("body = %s, want %s", recorder.Body.String(), tt.body)
+				}
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			delete(got, "id")
+			gotData, _ := json.Marshal(got)
+			var wantValue map[string]any
+			_ = json.Unmarshal([]byte(tt.want), &wantValue)
+			wantData, _ := json.Marshal(wantValue)
+			if string(gotData) != string(wantData) {
+				t.Errorf("body = %s, want %s", gotData, wantData)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel/gemini"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+)
+
+func getAndValidateGeminiRequest(c *gin.Context) (*gemini.GeminiChatRequest, error) {
+	geminiRequest := &gemini.GeminiChatRequest{}
+	err := common.UnmarshalBodyReusable(c, geminiRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(geminiRequest.Contents) == 0 {
+		return nil, errors.New("field contents is required")
+	}
+	return geminiRequest, nil
+}
+
+// GeminiHelper serves the native Gemini generateContent and streamGenerateContent
+// APIs. Gemini channels receive the request untouched, any other channel is
+// served through the OpenAI format and the response is converted back.
+func GeminiHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
+	relayInfo := relaycommon.GenRelayInfo(c)
+
+	originModel, action := relaycommon.GetGeminiModelAction(c.Request.URL.Path)
+	if action != "generateContent" && action != "streamGenerateContent" {
+		return service.OpenAIErrorWrapperLocal(fmt.Errorf("unsupported action: %s", action), "invalid_gemini_request", http.StatusNotFound)
+	}
+	geminiRequest, err := getAndValidateGeminiRequest(c)
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("getAndValidateGeminiRequest failed: %s", err.Error()))
+		return service.OpenAIErrorWrapperLocal(err, "invalid_gemini_request", http.StatusBadRequest)
+	}
+	relayInfo.IsStream = action == "streamGenerateContent"
+	isSSE := c.Query("alt") == "sse"
+
+	textRequest := gemini.RequestGemini2OpenAI(*geminiRequest, originModel, relayInfo.IsStream)
+
+	// map model name
+	upstreamModel, _, openaiErr := mapRequestModel(c, textRequest.Model)
+	if openaiErr != nil {
+		return openaiErr
+	}
+	textRequest.Model = upstreamModel
+	relayInfo.UpstreamModelName = textRequest.Model
+	modelPrice, success := common.GetModelPrice(textRequest.Model, false)
+	groupRatio := common.GetGroupRatio(relayInfo.Group)
+
+	var preConsumedQuota int
+	var ratio float64
+	var modelRatio float64
+
+	if constant.ShouldCheckPromptSensitive() {
+		err = service.CheckSensitiveMessages(textRequest.Messages)
+		if err != nil {
+			return service.OpenAIErrorWrapperLocal(err, "sensitive_words_detected", http.StatusBadRequest)
+		}
+	}
+
+	promptTokens, err := service.CountTokenChatRequest(*textRequest, textRequest.Model)
+	if err != nil {
+		return service.OpenAIErrorWrapper(err, "count_token_messages_failed", http.StatusInternalServerError)
+	}
+	relayInfo.SetPromptTokens(promptTokens)
+
+	if !success {
+		preConsumedTokens := common.PreConsumedQuota
+		if textRequest.MaxTokens != 0 {
+			preConsumedTokens = promptTokens + int(textRequest.MaxTokens)
+		}
+		modelRatio = common.GetModelRatio(textRequest.Model)
+		ratio = modelRatio * groupRatio
+		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
+	} else {
+		preConsumedQuota = int(modelPrice * common.QuotaPerUnit * groupRatio)
+	}
+
+	// pre-consume quota 预消耗配额
+	preConsumedQuota, userQuota, openaiErr := preConsumeQuota(c, preConsumedQuota, relayInfo)
+	if openaiErr != nil {
+		return openaiErr
+	}
+
+	var usage *dto.Usage
+	if relayInfo.ApiType == relayconstant.APITypeGemini {
+		usage, openaiErr = relayGeminiPassthrough(c, relayInfo, action, isSSE)
+	} else {
+		usage, openaiErr = relayConverted(c, relayInfo, textRequest, &geminiResponseConverter{
+			isSSE: isSSE,
+		})
+	}
+	if openaiErr != nil {
+		returnPreConsumedQuota(c, relayInfo.TokenId, userQuota, preConsumedQuota)
+		// reset status code 重置状态码
+		service.ResetStatusCode(openaiErr, c.GetString("status_code_mapping"))
+		return openaiErr
+	}
+	postConsumeQuota(c, relayInfo, *textRequest, usage, ratio, preConsumedQuota, userQuota, modelRatio, groupRatio, modelPrice, success)
+	return nil
+}
+
+// relayGeminiPassthrough sends the original request body to a Gemini channel,
+// the model name only appears in the url so mapping needs no body rewrite
+func relayGeminiPassthrough(c *gin.Context, relayInfo *relaycommon.RelayInfo, action string, isSSE bool) (*dto.Usage, *dto.OpenAIErrorWithStatusCode) {
+	adaptor := &gemini.Adaptor{}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	fullRequestURL := fmt.Sprintf("%s/v1beta/models/%s:%s", relayInfo.BaseUrl, relayInfo.UpstreamModelName, action)
+	if isSSE {
+		fullRequestURL += "?alt=sse"
+	}
+	body, openaiErr := applyParamOverride(c, bytes.NewBuffer(requestBody), relayInfo.UpstreamModelName)
+	if openaiErr != nil {
+		return nil, openaiErr
+	}
+	req, err := http.NewRequestWithContext(relaycommon.UpstreamContext(c), c.Request.Method, fullRequestURL, body)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	err = adaptor.SetupRequestHeader(c, req, relayInfo)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "setup_request_header_failed", http.StatusInternalServerError)
+	}
+	err = service.ApplyHeaderOverride(req.Header, c.GetString("header_override"), relayInfo.ApiKey, relayInfo.UpstreamModelName)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "setup_header_override_failed", http.StatusInternalServerError)
+	}
+	client, err := service.GetHttpClientWithProxy(c.GetString("proxy"))
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "get_http_client_failed", http.StatusInternalServerError)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(resp)
+	}
+
+	var usage *dto.Usage
+	if relayInfo.IsStream {
+		openaiErr, usage = gemini.GeminiNativeStreamHandler(c, resp, relayInfo)
+	} else {
+		openaiErr, usage = gemini.GeminiNativeHandler(c, resp, relayInfo)
+	}
+	return usage, openaiErr
+}
+
+// geminiResponseConverter renders OpenAI formatted adaptor output as
+// generateContent responses, streamed either as SSE (alt=sse) or as a JSON array
+type geminiResponseConverter struct {
+	isSSE        bool
+	started      bool
+	finishReason string
+	toolCalls    []dto.ToolCall
+}
+
+func (r *geminiResponseConverter) render(geminiResponse *gemini.GeminiChatResponse) []byte {
+	jsonData, err := json.Marshal(geminiResponse)
+	if err != nil {
+		common.SysError("error marshalling stream response: " + err.Error())
+		return nil
+	}
+	if r.isSSE {
+		return []byte(fmt.Sprintf("data: %s\r\n\r\n", jsonData))
+	}
+	prefix := ",\r\n"
+	if !r.started {
+		prefix = "["
+		r.started = true
+	}
+	return append([]byte(prefix), jsonData...)
+}
+
+func (r *geminiResponseConverter) StreamChunk(streamResponse *dto.ChatCompletionsStreamResponse) []byte {
+	for _, choice := range streamResponse.Choices {
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			r.finishReason = *choice.FinishReason
+		}
+		for _, toolCall := range choice.Delta.ToolCalls {
+			index := len(r.toolCalls) - 1
+			if toolCall.Index != nil {
+				index = *toolCall.Index
+			} else if toolCall.ID != "" || index < 0 {
+				index++
+			}
+			for len(r.toolCalls) <= index {
+				r.toolCalls = append(r.toolCalls, dto.ToolCall{})
+			}
+			if toolCall.Function.Name != "" {
+				r.toolCalls[index].Function.Name = toolCall.Function.Name
+			}
+			r.toolCalls[index].Function.Arguments += toolCall.Function.Arguments
+		}
+	}
+	geminiResponse := gemini.StreamResponseOpenAI2Gemini(streamResponse)
+	if geminiResponse == nil {
+		return nil
+	}
+	return r.render(geminiResponse)
+}
+
+func (r *geminiResponseConverter) StreamFinish(usage *dto.Usage) []byte {
+	textResponse := &dto.TextResponse{
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Message: dto.Message{
+					Role:    "assistant",
+					Content: json.RawMessage(`""`),
+				},
+				FinishReason: r.finishReason,
+			},
+		},
+	}
+	if len(r.toolCalls) > 0 {
+		textResponse.Choices[0].Message.ToolCalls = r.toolCalls
+	}
+	if usage != nil {
+		textResponse.Usage = *usage
+	}
+	data := r.render(gemini.ResponseOpenAI2Gemini(textResponse))
+	if !r.isSSE {
+		data = append(data, []byte("]")...)
+	}
+	return data
+}
+
+func (r *geminiResponseConverter) Response(textResponse *dto.TextResponse) any {
+	return gemini.ResponseOpenAI2Gemini(textResponse)
+}
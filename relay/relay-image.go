@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
@@ -16,10 +17,74 @@ import (
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// getImageRequestFromForm reads the fields of an image edit or variation form
+func getImageRequestFromForm(form *multipart.Form) dto.ImageRequest {
+	value := func(key string) string {
+		if len(form.Value[key]) > 0 {
+			return form.Value[key][0]
+		}
+		return ""
+	}
+	n, _ := strconv.Atoi(value("n"))
+	return dto.ImageRequest{
+		Model:          value("model"),
+		Prompt:         value("prompt"),
+		N:              n,
+		Size:           value("size"),
+		Quality:        value("quality"),
+		ResponseFormat: value("response_format"),
+		User:           value("user"),
+	}
+}
+
+// buildImageMultipartBody re-encodes an image edit or variation form with the mapped model name
+func buildImageMultipartBody(form *multipart.Form, model string) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, values := range form.Value {
+		if key == "model" {
+			continue
+		}
+		for _, value := range values {
+			err := writer.WriteField(key, value)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	err := writer.WriteField("model", model)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, fileHeaders := range form.File {
+		for _, fileHeader := range fileHeaders {
+			part, err := writer.CreatePart(fileHeader.Header)
+			if err != nil {
+				return nil, "", err
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				return nil, "", err
+			}
+			_, err = io.Copy(part, file)
+			file.Close()
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
 func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusCode {
 	tokenId := c.GetInt("token_id")
 	channelType := c.GetInt("channel")
@@ -29,13 +94,35 @@ func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusC
 	startTime := time.Now()
 
 	var imageRequest dto.ImageRequest
-	err := common.UnmarshalBodyReusable(c, &imageRequest)
-	if err != nil {
-		return service.OpenAIErrorWrapper(err, "bind_request_body_failed", http.StatusBadRequest)
+	// image edits and variations are multipart forms carrying the source image
+	var form *multipart.Form
+	var err error
+	if relayMode == relayconstant.RelayModeImagesGenerations {
+		err = common.UnmarshalBodyReusable(c, &imageRequest)
+		if err != nil {
+			return service.OpenAIErrorWrapper(err, "bind_request_body_failed", http.StatusBadRequest)
+		}
+	} else {
+		if apiType, _ := relayconstant.ChannelType2APIType(channelType); apiType != relayconstant.APITypeOpenAI {
+			return service.OpenAIErrorWrapperLocal(fmt.Errorf("channel type %d does not support image edits and variations", channelType), "channel_not_supported", http.StatusBadRequest)
+		}
+		form, err = common.ParseMultipartFormReusable(c)
+		if err != nil {
+			return service.OpenAIErrorWrapper(err, "bind_request_body_failed", http.StatusBadRequest)
+		}
+		defer form.RemoveAll()
+		if len(form.File["image"]) == 0 && len(form.File["image[]"]) == 0 {
+			return service.OpenAIErrorWrapper(errors.New("image is required"), "required_field_missing", http.StatusBadRequest)
+		}
+		imageRequest = getImageRequestFromForm(form)
 	}
 
 	if imageRequest.Model == "" {
-		imageRequest.Model = "dall-e-3"
+		if relayMode == relayconstant.RelayModeImagesGenerations {
+			imageRequest.Model = "dall-e-3"
+		} else {
+			imageRequest.Model = "dall-e-2"
+		}
 	}
 	if imageRequest.Size == "" {
 		imageRequest.Size = "1024x1024"
@@ -43,12 +130,12 @@ func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusC
 	if imageRequest.N == 0 {
 		imageRequest.N = 1
 	}
-	// Prompt validation
-	if imageRequest.Prompt == "" {
+	// Prompt validation, variations are generated from the image only
+	if imageRequest.Prompt == "" && relayMode != relayconstant.RelayModeImagesVariations {
 		return service.OpenAIErrorWrapper(errors.New("prompt is required"), "required_field_missing", http.StatusBadRequest)
 	}
 
-	if constant.ShouldCheckPromptSensitive() {
+	if constant.ShouldCheckPromptSensitive() && imageRequest.Prompt != "" {
 		err = service.CheckSensitiveInput(imageRequest.Prompt)
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "sensitive_words_detected", http.StatusBadRequest)
@@ -78,33 +165,38 @@ func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusC
 	}
 
 	// map model name
-	modelMapping := c.GetString("model_mapping")
-	isModelMapped := false
-	if modelMapping != "" {
-		modelMap := make(map[string]string)
-		err := json.Unmarshal([]byte(modelMapping), &modelMap)
-		if err != nil {
-			return service.OpenAIErrorWrapper(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
-		}
-		if modelMap[imageRequest.Model] != "" {
-			imageRequest.Model = modelMap[imageRequest.Model]
-			isModelMapped = true
-		}
+	upstreamModel, isModelMapped, openaiErr := mapRequestModel(c, imageRequest.Model)
+	if openaiErr != nil {
+		return openaiErr
 	}
+	imageRequest.Model = upstreamModel
 	baseURL := common.ChannelBaseURLs[channelType]
 	requestURL := c.Request.URL.String()
 	if c.GetString("base_url") != "" {
 		baseURL = c.GetString("base_url")
 	}
 	fullRequestURL := relaycommon.GetFullRequestURL(baseURL, requestURL, channelType)
-	if channelType == common.ChannelTypeAzure && relayMode == relayconstant.RelayModeImagesGenerations {
+	if channelType == common.ChannelTypeAzure {
 		// https://learn.microsoft.com/en-us/azure/ai-services/openai/dall-e-quickstart?tabs=dalle3%2Ccommand-line&pivots=rest-api
 		apiVersion := relaycommon.GetAPIVersion(c)
 		// https://{resource_name}.openai.azure.com/openai/deployments/dall-e-3/images/generations?api-version=2023-06-01-preview
-		fullRequestURL = fmt.Sprintf("%s/openai/deployments/%s/images/generations?api-version=%s", baseURL, imageRequest.Model, apiVersion)
+		task := strings.TrimPrefix(strings.Split(c.Request.URL.Path, "?")[0], "/v1/images/")
+		fullRequestURL = fmt.Sprintf("%s/openai/deployments/%s/images/%s?api-version=%s", baseURL, imageRequest.Model, task, apiVersion)
 	}
 	var requestBody io.Reader
-	if isModelMapped || channelType == common.ChannelTypeAzure { // make Azure channel request body
+	contentType := c.Request.Header.Get("Content-Type")
+	if form != nil {
+		if isModelMapped {
+			body, formContentType, err := buildImageMultipartBody(form, imageRequest.Model)
+			if err != nil {
+				return service.OpenAIErrorWrapper(err, "build_multipart_body_failed", http.StatusInternalServerError)
+			}
+			requestBody = body
+			contentType = formContentType
+		} else {
+			requestBody = c.Request.Body
+		}
+	} else if isModelMapped || channelType == common.ChannelTypeAzure { // make Azure channel request body
 		jsonStr, err := json.Marshal(imageRequest)
 		if err != nil {
 			return service.OpenAIErrorWrapper(err, "marshal_text_request_failed", http.StatusInternalServerError)
@@ -162,10 +254,18 @@ func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusC
 	} else {
 		req.Header.Set("Authorization", token)
 	}
-	req.Header.Set("Content-Type", c.Request.Header.Get("Content-Type"))
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", c.Request.Header.Get("Accept"))
+	err = service.ApplyHeaderOverride(req.Header, c.GetString("header_override"), strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "), imageRequest.Model)
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "setup_header_override_failed", http.StatusInternalServerError)
+	}
 
-	resp, err := service.GetHttpClient().Do(req)
+	client, err := service.GetHttpClientWithProxy(c.GetString("proxy"))
+	if err != nil {
+		return service.OpenAIErrorWrapperLocal(err, "get_http_client_failed", http.StatusInternalServerError)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return service.OpenAIErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
@@ -211,6 +311,7 @@ func RelayImageHelper(c *gin.Context, relayMode int) *dto.OpenAIErrorWithStatusC
 			model.UpdateUserUsedQuotaAndRequestCount(userId, quota)
 			channelId := c.GetInt("channel_id")
 			model.UpdateChannelUsedQuota(channelId, quota)
+			model.UpdateChannelKeyUsage(c.GetInt("channel_key_id"), quota)
 		}
 	}(c.Request.Context())
 
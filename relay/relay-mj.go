@@ -209,6 +209,7 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 				model.UpdateUserUsedQuotaAndRequestCount(userId, quota)
 				channelId := c.GetInt("channel_id")
 				model.UpdateChannelUsedQuota(channelId, quota)
+				model.UpdateChannelKeyUsage(c.GetInt("channel_key_id"), quota)
 			}
 		}
 	}(c.Request.Context())
@@ -263,7 +264,14 @@ func RelayMidjourneyTaskImageSeed(c *gin.Context) *dto.MidjourneyResponse {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
 	}
 	c.Set("channel_id", originTask.ChannelId)
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+	key, keyId, err := model.SelectChannelKey(channel)
+	if err != nil {
+		common.SysError(err.Error())
+		service.DisableChannel(channel.Id, channel.Name, err.Error())
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道的密钥解密失败，渠道已被禁用")
+	}
+	c.Set("channel_key_id", keyId)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	fullRequestURL := fmt.Sprintf("%s%s", channel.GetBaseURL(), requestURL)
@@ -427,7 +435,14 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 			}
 			c.Set("base_url", channel.GetBaseURL())
 			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
+			key, keyId, err := model.SelectChannelKey(channel)
+			if err != nil {
+				common.SysError(err.Error())
+				service.DisableChannel(channel.Id, channel.Name, err.Error())
+				return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道的密钥解密失败，渠道已被禁用")
+			}
+			c.Set("channel_key_id", keyId)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
 			log.Printf("检测到此操作为放大、变换、重绘，获取原channel信息: %s,%s", strconv.Itoa(originTask.ChannelId), channel.GetBaseURL())
 		}
 		midjRequest.Prompt = originTask.Prompt
@@ -508,6 +523,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 				model.UpdateUserUsedQuotaAndRequestCount(userId, quota)
 				channelId := c.GetInt("channel_id")
 				model.UpdateChannelUsedQuota(channelId, quota)
+				model.UpdateChannelKeyUsage(c.GetInt("channel_key_id"), quota)
 			}
 		}
 	}(c.Request.Context())
@@ -76,20 +76,11 @@ func TextHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	}
 
 	// map model name
-	modelMapping := c.GetString("model_mapping")
-	isModelMapped := false
-	if modelMapping != "" && modelMapping != "{}" {
-		modelMap := make(map[string]string)
-		err := json.Unmarshal([]byte(modelMapping), &modelMap)
-		if err != nil {
-			return service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
-		}
-		if modelMap[textRequest.Model] != "" {
-			textRequest.Model = modelMap[textRequest.Model]
-			// set upstream model name
-			isModelMapped = true
-		}
+	upstreamModel, isModelMapped, openaiErr := mapRequestModel(c, textRequest.Model)
+	if openaiErr != nil {
+		return openaiErr
 	}
+	textRequest.Model = upstreamModel
 	relayInfo.UpstreamModelName = textRequest.Model
 	modelPrice, success := common.GetModelPrice(textRequest.Model, false)
 	groupRatio := common.GetGroupRatio(relayInfo.Group)
@@ -157,6 +148,11 @@ func TextHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 		}
 		requestBody = bytes.NewBuffer(jsonData)
 	}
+	requestBody, openaiErr = applyParamOverride(c, requestBody, relayInfo.UpstreamModelName)
+	if openaiErr != nil {
+		returnPreConsumedQuota(c, relayInfo.TokenId, userQuota, preConsumedQuota)
+		return openaiErr
+	}
 
 	statusCodeMappingStr := c.GetString("status_code_mapping")
 	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
@@ -186,6 +182,39 @@ func TextHelper(c *gin.Context) *dto.OpenAIErrorWithStatusCode {
 	return nil
 }
 
+// mapRequestModel returns the model a request is sent upstream with and whether it differs from the
+// requested one: the fallback model replaces the requested one once the chain moved on, otherwise a
+// global alias is resolved, then the model mapping of the channel applies
+func mapRequestModel(c *gin.Context, requestModel string) (string, bool, *dto.OpenAIErrorWithStatusCode) {
+	modelName := common.ResolveModelAlias(requestModel)
+	if fallbackModel := c.GetString("fallback_model"); fallbackModel != "" {
+		modelName = fallbackModel
+	}
+	modelMapping, err := model.GetModelMapping(c.GetString("model_mapping"))
+	if err != nil {
+		return "", false, service.OpenAIErrorWrapperLocal(err, "unmarshal_model_mapping_failed", http.StatusInternalServerError)
+	}
+	modelName, _ = modelMapping.Map(modelName)
+	return modelName, modelName != requestModel, nil
+}
+
+// applyParamOverride patches the outbound body with the param override of the channel
+func applyParamOverride(c *gin.Context, requestBody io.Reader, modelName string) (io.Reader, *dto.OpenAIErrorWithStatusCode) {
+	paramOverride := c.GetString("param_override")
+	if paramOverride == "" || paramOverride == "{}" {
+		return requestBody, nil
+	}
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	body, err = service.ApplyParamOverride(body, paramOverride, modelName)
+	if err != nil {
+		return nil, service.OpenAIErrorWrapperLocal(err, "apply_param_override_failed", http.StatusInternalServerError)
+	}
+	return bytes.NewBuffer(body), nil
+}
+
 func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) (int, error) {
 	var promptTokens int
 	var err error
@@ -293,13 +322,30 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, textRe
 	} else {
 		quota = int(modelPrice * common.QuotaPerUnit * groupRatio)
 	}
+	// the attempt that lost a hedged race is neither billed nor counted, the winner is
+	if relaycommon.HedgeLost(ctx) {
+		returnPreConsumedQuota(ctx, relayInfo.TokenId, userQuota, preConsumedQuota)
+		return
+	}
 	totalTokens := promptTokens + completionTokens
+	if ctx.GetBool("channel_limited") {
+		model.RecordChannelTokens(relayInfo.ChannelId, totalTokens)
+	}
+	if ctx.GetInt("token_tpm_limit") > 0 {
+		common.RecordTokenTokens(relayInfo.TokenId, totalTokens)
+	}
 	var logContent string
 	if modelPrice == -1 {
 		logContent = fmt.Sprintf("模型倍率 %.2f，分组倍率 %.2f，补全倍率 %.2f", modelRatio, groupRatio, completionRatio)
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
+	batchId := ctx.GetString("batch_id")
+	if batchId != "" {
+		// requests replayed by the Batch API are discounted
+		quota = int(math.Round(float64(quota) * common.BatchDiscount))
+		logContent += fmt.Sprintf("，批处理折扣 %.2f", common.BatchDiscount)
+	}
 
 	// record all the consume log even if quota is 0
 	if totalTokens == 0 {
@@ -325,6 +371,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, textRe
 		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		model.UpdateChannelKeyUsage(ctx.GetInt("channel_key_id"), quota)
 	}
 
 	logModel := textRequest.Model
@@ -332,13 +379,26 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, textRe
 		logModel = "gpt-4-gizmo-*"
 		logContent += fmt.Sprintf("，模型 %s", textRequest.Model)
 	}
+	if ctx.GetString("fallback_model") != "" {
+		logContent += fmt.Sprintf("，模型 %s 不可用，已回退至 %s", ctx.GetString("original_model"), ctx.GetString("fallback_model"))
+	}
 	other := make(map[string]interface{})
 	other["model_ratio"] = modelRatio
 	other["group_ratio"] = groupRatio
 	other["completion_ratio"] = completionRatio
 	other["model_price"] = modelPrice
+	if batchId != "" {
+		other["batch_id"] = batchId
+		other["batch_discount"] = common.BatchDiscount
+	}
+	if ctx.GetString("fallback_model") != "" {
+		other["fallback_from"] = ctx.GetString("original_model")
+	}
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
+	if ctx.GetBool("hedged") {
+		adminInfo["hedged"] = true
+	}
 	other["admin_info"] = adminInfo
 	model.RecordConsumeLog(ctx, relayInfo.UserId, relayInfo.ChannelId, promptTokens, completionTokens, logModel, tokenName, quota, logContent, relayInfo.TokenId, userQuota, int(useTimeSeconds), relayInfo.IsStream, other)
 
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"one-api/common"
 	"one-api/controller"
 	"one-api/middleware"
 
@@ -12,6 +13,7 @@ func SetApiRouter(router *gin.Engine) {
 	apiRouter := router.Group("/api")
 	apiRouter.Use(gzip.Gzip(gzip.DefaultCompression))
 	apiRouter.Use(middleware.GlobalAPIRateLimit())
+	apiRouter.Use(middleware.AuditLog())
 	{
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
@@ -26,6 +28,8 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.POST("/user/reset", middleware.CriticalRateLimit(), controller.ResetPassword)
 		apiRouter.GET("/oauth/github", middleware.CriticalRateLimit(), controller.GitHubOAuth)
 		apiRouter.GET("/oauth/state", middleware.CriticalRateLimit(), controller.GenerateOAuthCode)
+		apiRouter.GET("/oauth/oidc", middleware.CriticalRateLimit(), controller.OIDCOAuth)
+		apiRouter.GET("/oauth/oidc/url", middleware.CriticalRateLimit(), controller.OIDCAuthURL)
 		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), controller.WeChatAuth)
 		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.WeChatBind)
 		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.EmailBind)
@@ -36,6 +40,7 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Login)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.Login2FA)
 			//userRoute.POST("/tokenlog", middleware.CriticalRateLimit(), controller.TokenLog)
 			userRoute.GET("/logout", controller.Logout)
 			userRoute.GET("/epay/notify", controller.EpayNotify)
@@ -47,51 +52,62 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/models", controller.GetUserModels)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
-				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.GET("/token", middleware.RequireTwoFA(), controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.POST("/pay", controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
+				selfRoute.GET("/2fa/status", controller.GetTwoFAStatus)
+				selfRoute.POST("/2fa/setup", controller.SetupTwoFA)
+				selfRoute.POST("/2fa/enable", middleware.CriticalRateLimit(), controller.EnableTwoFA)
+				selfRoute.POST("/2fa/disable", middleware.CriticalRateLimit(), controller.DisableTwoFA)
+				selfRoute.POST("/2fa/recovery_codes", middleware.CriticalRateLimit(), controller.RegenerateTwoFARecoveryCodes)
+				selfRoute.POST("/2fa/verify", middleware.CriticalRateLimit(), controller.VerifyTwoFA)
 			}
 
 			adminRoute := userRoute.Group("/")
 			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.RequirePermission(common.PermissionUsersRead), controller.GetAllUsers)
+				adminRoute.GET("/search", middleware.RequirePermission(common.PermissionUsersRead), controller.SearchUsers)
+				adminRoute.GET("/:id", middleware.RequirePermission(common.PermissionUsersRead), controller.GetUser)
+				adminRoute.POST("/", middleware.RequirePermission(common.PermissionUsersManage), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.RequirePermission(common.PermissionUsersManage), controller.ManageUser)
+				adminRoute.PUT("/", middleware.RequirePermission(common.PermissionUsersManage), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.RequirePermission(common.PermissionUsersManage), controller.DeleteUser)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.AdminAuth())
 		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
-			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.GET("/", middleware.RequirePermission(common.PermissionOptionsRead), controller.GetOptions)
+			optionRoute.PUT("/", middleware.RequirePermission(common.PermissionOptionsWrite), middleware.RequireTwoFA(), controller.UpdateOption)
+			optionRoute.POST("/rest_model_ratio", middleware.RequirePermission(common.PermissionOptionsWrite), middleware.RequireTwoFA(), controller.ResetModelRatio)
 		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
 		{
-			channelRoute.GET("/", controller.GetAllChannels)
-			channelRoute.GET("/search", controller.SearchChannels)
-			channelRoute.GET("/models", controller.ChannelListModels)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.POST("/batch", controller.DeleteChannelBatch)
-			channelRoute.POST("/fix", controller.FixChannelsAbilities)
-			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
+			channelRoute.GET("/", middleware.RequirePermission(common.PermissionChannelsRead), controller.GetAllChannels)
+			channelRoute.GET("/search", middleware.RequirePermission(common.PermissionChannelsRead), controller.SearchChannels)
+			channelRoute.GET("/models", middleware.RequirePermission(common.PermissionChannelsRead), controller.ChannelListModels)
+			channelRoute.GET("/scores", middleware.RequirePermission(common.PermissionChannelsRead), controller.GetChannelScores)
+			channelRoute.GET("/:id", middleware.RequirePermission(common.PermissionChannelsRead), controller.GetChannel)
+			channelRoute.GET("/test", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.TestAllChannels)
+			channelRoute.GET("/test/:id", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.TestChannel)
+			channelRoute.GET("/update_balance", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.UpdateChannelBalance)
+			channelRoute.POST("/", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.AddChannel)
+			channelRoute.PUT("/", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.UpdateChannel)
+			channelRoute.DELETE("/disabled", middleware.RequirePermission(common.PermissionChannelsWrite), controller.DeleteDisabledChannel)
+			channelRoute.DELETE("/:id", middleware.RequirePermission(common.PermissionChannelsWrite), controller.DeleteChannel)
+			channelRoute.POST("/batch", middleware.RequirePermission(common.PermissionChannelsWrite), controller.DeleteChannelBatch)
+			channelRoute.POST("/fix", middleware.RequirePermission(common.PermissionChannelsWrite), controller.FixChannelsAbilities)
+			channelRoute.POST("/reencrypt_keys", middleware.RootAuth(), controller.ReencryptChannelKeys)
+			channelRoute.GET("/fetch_models/:id", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.FetchUpstreamModels)
+			channelRoute.GET("/keys/:id", middleware.RequirePermission(common.PermissionChannelsRead), controller.GetChannelKeys)
+			channelRoute.GET("/key/:id", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.GetChannelKey)
+			channelRoute.PUT("/keys/:id", middleware.RequirePermission(common.PermissionChannelsWrite), middleware.RequireTwoFA(), controller.UpdateChannelKey)
 
 		}
 		tokenRoute := apiRouter.Group("/token")
@@ -107,24 +123,24 @@ func SetApiRouter(router *gin.Engine) {
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", middleware.RequirePermission(common.PermissionBillingManage), controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", middleware.RequirePermission(common.PermissionBillingManage), controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", middleware.RequirePermission(common.PermissionBillingManage), controller.GetRedemption)
+			redemptionRoute.POST("/", middleware.RequirePermission(common.PermissionBillingManage), controller.AddRedemption)
+			redemptionRoute.PUT("/", middleware.RequirePermission(common.PermissionBillingManage), controller.UpdateRedemption)
+			redemptionRoute.DELETE("/:id", middleware.RequirePermission(common.PermissionBillingManage), controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsRead), controller.GetAllLogs)
+		logRoute.DELETE("/", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsManage), controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsRead), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/search", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsRead), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsRead), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
 
 		logRoute.Use(middleware.CORS())
@@ -132,6 +148,12 @@ func SetApiRouter(router *gin.Engine) {
 			logRoute.GET("/token", controller.GetLogByKey)
 
 		}
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.RootAuth())
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+			auditRoute.GET("/export", controller.ExportAuditLogs)
+		}
 		groupRoute := apiRouter.Group("/group")
 		groupRoute.Use(middleware.AdminAuth())
 		{
@@ -139,6 +161,6 @@ func SetApiRouter(router *gin.Engine) {
 		}
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(common.PermissionLogsRead), controller.GetAllMidjourney)
 	}
 }
@@ -17,6 +17,23 @@ func DisableChannel(channelId int, channelName string, reason string) {
 	notifyRootUser(subject, content)
 }
 
+// DisableChannelKey retires one key of a multi-key channel, the channel itself is only
+// disabled once none of its keys are left
+func DisableChannelKey(channelId int, keyId int, channelName string, reason string) {
+	err := model.UpdateChannelKeyStatusById(keyId, common.ChannelStatusAutoDisabled, reason)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to disable key #%d of channel #%d: %s", keyId, channelId, err.Error()))
+		return
+	}
+	subject := fmt.Sprintf("通道「%s」（#%d）的密钥 #%d 已被禁用", channelName, channelId, keyId)
+	content := fmt.Sprintf("通道「%s」（#%d）的密钥 #%d 已被禁用，原因：%s", channelName, channelId, keyId, reason)
+	notifyRootUser(subject, content)
+	count, err := model.CountEnabledChannelKeys(channelId)
+	if err == nil && count == 0 {
+		DisableChannel(channelId, channelName, "所有密钥均已被禁用，最后的原因："+reason)
+	}
+}
+
 func EnableChannel(channelId int, channelName string) {
 	model.UpdateChannelStatusById(channelId, common.ChannelStatusEnabled)
 	subject := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
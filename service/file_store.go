@@ -0,0 +1,97 @@
+package service
+
+import (
+	"io"
+	"one-api/common"
+	"os"
+	"path/filepath"
+)
+
+// FileStore keeps the content of files uploaded through the Files API and
+// the results produced by the Batch API
+type FileStore interface {
+	Save(name string, reader io.Reader) (int64, error)
+	Append(name string, data []byte) error
+	Open(name string) (io.ReadCloser, error)
+	Size(name string) (int64, error)
+	Delete(name string) error
+}
+
+var fileStore FileStore
+
+func InitFileStore() {
+	store, err := NewLocalFileStore(common.FileStoragePath)
+	if err != nil {
+		common.FatalLog("failed to initialize file store: " + err.Error())
+	}
+	fileStore = store
+	common.SysLog("file store initialized at " + store.dir)
+}
+
+func GetFileStore() FileStore {
+	return fileStore
+}
+
+// LocalFileStore stores files in a directory on the local disk, when running
+// multiple nodes the directory should be shared between them
+type LocalFileStore struct {
+	dir string
+}
+
+func NewLocalFileStore(dir string) (*LocalFileStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalFileStore{dir: dir}, nil
+}
+
+func (s *LocalFileStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.Base(name))
+}
+
+func (s *LocalFileStore) Save(name string, reader io.Reader) (int64, error) {
+	file, err := os.Create(s.path(name))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(file, reader)
+}
+
+func (s *LocalFileStore) Append(name string, data []byte) error {
+	file, err := os.OpenFile(s.path(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+func (s *LocalFileStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *LocalFileStore) Size(name string) (int64, error) {
+	info, err := os.Stat(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *LocalFileStore) Delete(name string) error {
+	err := os.Remove(s.path(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -1,14 +1,26 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"one-api/common"
+	"strings"
+	"sync"
 	"time"
 )
 
 var httpClient *http.Client
 var impatientHTTPClient *http.Client
 
+// the clients of the channel proxies, keyed by proxy url and timeout, the cache is emptied once it holds
+// maxProxyClients so the clients of changed or deleted proxies do not pile up
+const maxProxyClients = 64
+
+var proxyClientLock sync.Mutex
+var proxyClients = make(map[string]*http.Client)
+
 func init() {
 	if common.RelayTimeout == 0 {
 		httpClient = &http.Client{}
@@ -30,3 +42,97 @@ func GetHttpClient() *http.Client {
 func GetImpatientHttpClient() *http.Client {
 	return impatientHTTPClient
 }
+
+// GetHttpClientWithProxy returns the relay client sending requests through the proxy of a channel,
+// an empty proxy means a direct connection
+func GetHttpClientWithProxy(proxy string) (*http.Client, error) {
+	return getProxyClient(httpClient, proxy)
+}
+
+func GetImpatientHttpClientWithProxy(proxy string) (*http.Client, error) {
+	return getProxyClient(impatientHTTPClient, proxy)
+}
+
+// ParseProxyURL parses the proxy of a channel, http, https and socks5 proxies are supported
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return proxyURL, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
+}
+
+func getProxyClient(base *http.Client, proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return base, nil
+	}
+	key := fmt.Sprintf("%s|%s", proxy, base.Timeout)
+	proxyClientLock.Lock()
+	defer proxyClientLock.Unlock()
+	if client, ok := proxyClients[key]; ok {
+		return client, nil
+	}
+	proxyURL, err := ParseProxyURL(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if len(proxyClients) >= maxProxyClients {
+		for cachedKey, cached := range proxyClients {
+			cached.CloseIdleConnections()
+			delete(proxyClients, cachedKey)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   base.Timeout,
+	}
+	proxyClients[key] = client
+	return client, nil
+}
+
+// CheckHeaderOverride reports whether the header templates of a channel are valid header names and values
+func CheckHeaderOverride(headerOverride string) error {
+	if headerOverride == "" {
+		return nil
+	}
+	templates := make(map[string]string)
+	err := json.Unmarshal([]byte(headerOverride), &templates)
+	if err != nil {
+		return err
+	}
+	for name, template := range templates {
+		if name == "" || strings.IndexFunc(name, func(r rune) bool {
+			return r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r)
+		}) >= 0 {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(template, "\r\n\x00") {
+			return fmt.Errorf("invalid value of header %s", name)
+		}
+	}
+	return nil
+}
+
+// ApplyHeaderOverride sets the header templates of a channel on an upstream request,
+// {api_key} in a value is replaced by the key the request is sent with and {model} by the upstream model
+func ApplyHeaderOverride(header http.Header, headerOverride string, apiKey string, model string) error {
+	if headerOverride == "" || headerOverride == "{}" {
+		return nil
+	}
+	templates := make(map[string]string)
+	err := json.Unmarshal([]byte(headerOverride), &templates)
+	if err != nil {
+		return err
+	}
+	replacer := strings.NewReplacer("{api_key}", apiKey, "{model}", model)
+	for name, template := range templates {
+		header.Set(name, replacer.Replace(template))
+	}
+	return nil
+}
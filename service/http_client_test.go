@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestCheckHeaderOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		wantErr  bool
+	}{
+		{"empty", "", false},
+		{"templates", `{"X-Api-Key":"{api_key}","X-Model":"{model}"}`, false},
+		{"invalid json", `{"X-Api-Key":`, true},
+		{"not a string", `{"X-Retry":1}`, true},
+		{"space in name", `{"X Api":"a"}`, true},
+		{"colon in name", `{"X-Api:":"a"}`, true},
+		{"empty name", `{"":"a"}`, true},
+		{"newline in value", `{"X-Api":"a\r\nX-Other: b"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckHeaderOverride(tt.override); (err != nil) != tt.wantErr {
+				t.Errorf("CheckHeaderOverride(%s) = %v, want error %v", tt.override, err, tt.wantErr)
+			}
+		})
+	}
+}
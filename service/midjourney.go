@@ -202,7 +202,15 @@ func DoMidjourneyHttpRequest(c *gin.Context, timeout time.Duration, fullRequestU
 		req.Header.Set("mj-api-secret", auth)
 	}
 	defer cancel()
-	resp, err := GetHttpClient().Do(req)
+	err = ApplyHeaderOverride(req.Header, c.GetString("header_override"), auth, "")
+	if err != nil {
+		return MidjourneyErrorWithStatusCodeWrapper(constant.MjErrorUnknown, "setup_header_override_failed", http.StatusInternalServerError), nullBytes, err
+	}
+	client, err := GetHttpClientWithProxy(c.GetString("proxy"))
+	if err != nil {
+		return MidjourneyErrorWithStatusCodeWrapper(constant.MjErrorUnknown, "get_http_client_failed", http.StatusInternalServerError), nullBytes, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		common.SysError("do request failed: " + err.Error())
 		return MidjourneyErrorWithStatusCodeWrapper(constant.MjErrorUnknown, "do_request_failed", http.StatusInternalServerError), nullBytes, err
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParamOverrideRule patches the request body, parameters are deleted first, then the defaults fill
+// the missing ones and finally the set ones are forced. Names may address nested fields with dots.
+type ParamOverrideRule struct {
+	Set     map[string]any `json:"set"`
+	Delete  []string       `json:"delete"`
+	Default map[string]any `json:"default"`
+}
+
+// ParamOverride is the param override spec of a channel, the rules of the models it names are
+// applied after the channel wide rule, a name ending with * matches the models it prefixes, e.g.
+// {"set": {"temperature": 0.7}, "delete": ["logprobs"], "models": {"o1-*": {"delete": ["temperature"]}}}
+type ParamOverride struct {
+	ParamOverrideRule
+	Models map[string]ParamOverrideRule `json:"models"`
+}
+
+// ApplyParamOverride patches the json body sent to the upstream model with the param override spec of the channel
+func ApplyParamOverride(body []byte, paramOverrideStr string, model string) ([]byte, error) {
+	if paramOverrideStr == "" || paramOverrideStr == "{}" {
+		return body, nil
+	}
+	paramOverride := ParamOverride{}
+	err := json.Unmarshal([]byte(paramOverrideStr), &paramOverride)
+	if err != nil {
+		return nil, err
+	}
+	request := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keep large integers like seeds intact
+	decoder.UseNumber()
+	err = decoder.Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	paramOverride.ParamOverrideRule.apply(request)
+	for _, rule := range paramOverride.getModelRules(model) {
+		rule.apply(request)
+	}
+	return json.Marshal(request)
+}
+
+// CheckParamOverride reports whether the param override spec of a channel is valid, unknown fields are
+// rejected since a misspelled rule would be silently ignored
+func CheckParamOverride(paramOverrideStr string) error {
+	if paramOverrideStr == "" {
+		return nil
+	}
+	paramOverride := ParamOverride{}
+	decoder := json.NewDecoder(strings.NewReader(paramOverrideStr))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&paramOverride)
+	if err != nil {
+		return err
+	}
+	err = paramOverride.ParamOverrideRule.check()
+	if err != nil {
+		return err
+	}
+	for model, rule := range paramOverride.Models {
+		if model == "" || strings.Contains(strings.TrimSuffix(model, "*"), "*") {
+			return fmt.Errorf("invalid model name %q, * is only allowed at the end", model)
+		}
+		err = rule.check()
+		if err != nil {
+			return fmt.Errorf("model %s: %s", model, err.Error())
+		}
+	}
+	return nil
+}
+
+func (r ParamOverrideRule) check() error {
+	names := make([]string, 0, len(r.Delete)+len(r.Default)+len(r.Set))
+	names = append(names, r.Delete...)
+	for name := range r.Default {
+		names = append(names, name)
+	}
+	for name := range r.Set {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		for _, part := range strings.Split(name, ".") {
+			if part == "" {
+				return fmt.Errorf("invalid parameter name %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// getModelRules returns the rules matching the model from the least to the most specific,
+// prefixes by length and the exact name last
+func (o *ParamOverride) getModelRules(model string) []ParamOverrideRule {
+	prefixes := make([]string, 0)
+	for name := range o.Models {
+		if strings.HasSuffix(name, "*") && strings.HasPrefix(model, strings.TrimSuffix(name, "*")) {
+			prefixes = append(prefixes, name)
+		}
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) < len(prefixes[j])
+	})
+	rules := make([]ParamOverrideRule, 0, len(prefixes)+1)
+	for _, name := range prefixes {
+		rules = append(rules, o.Models[name])
+	}
+	if rule, ok := o.Models[model]; ok {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (r ParamOverrideRule) apply(request map[string]any) {
+	for _, name := range r.Delete {
+		parent, key := lookupParamParent(request, name, false)
+		if parent != nil {
+			delete(parent, key)
+		}
+	}
+	for name, value := range r.Default {
+		parent, key := lookupParamParent(request, name, true)
+		if parent == nil {
+			continue
+		}
+		if _, ok := parent[key]; !ok {
+			parent[key] = value
+		}
+	}
+	for name, value := range r.Set {
+		parent, key := lookupParamParent(request, name, true)
+		if parent != nil {
+			parent[key] = value
+		}
+	}
+}
+
+// lookupParamParent returns the object holding the parameter addressed by the dotted name and its key,
+// missing objects on the way are created if asked to, nil if the path runs into a non object value
+func lookupParamParent(request map[string]any, name string, create bool) (map[string]any, string) {
+	parts := strings.Split(name, ".")
+	parent := request
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := parent[part]
+		if !ok {
+			if !create {
+				return nil, ""
+			}
+			child := make(map[string]any)
+			parent[part] = child
+			parent = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return nil, ""
+		}
+		parent = child
+	}
+	return parent, parts[len(parts)-1]
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestApplyParamOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		override string
+		model    string
+		want     string
+	}{
+		{"empty spec", `{"model":"gpt-4o","temperature":1}`, "", "gpt-4o", `{"model":"gpt-4o","temperature":1}`},
+		{"set", `{"temperature":1}`, `{"set":{"temperature":0.7}}`, "gpt-4o", `{"temperature":0.7}`},
+		{"delete", `{"logprobs":true,"top_p":1}`, `{"delete":["logprobs","missing"]}`, "gpt-4o", `{"top_p":1}`},
+		{"default keeps the request value", `{"max_tokens":10}`, `{"default":{"max_tokens":100,"top_p":0.9}}`, "gpt-4o", `{"max_tokens":10,"top_p":0.9}`},
+		{"delete before default before set", `{"a":1,"b":1}`, `{"delete":["a","b"],"default":{"a":2,"b":2},"set":{"b":3}}`, "gpt-4o", `{"a":2,"b":3}`},
+		{"nested set creates objects", `{}`, `{"set":{"thinking.type":"enabled"}}`, "gpt-4o", `{"thinking":{"type":"enabled"}}`},
+		{"nested delete", `{"stream_options":{"include_usage":true,"x":1}}`, `{"delete":["stream_options.x"]}`, "gpt-4o", `{"stream_options":{"include_usage":true}}`},
+		{"path through a non object", `{"stop":"x"}`, `{"set":{"stop.a":1}}`, "gpt-4o", `{"stop":"x"}`},
+		{"large integers stay intact", `{"seed":12345678901234567890}`, `{"set":{"top_p":1}}`, "gpt-4o", `{"seed":12345678901234567890,"top_p":1}`},
+		{"model prefix rule", `{"temperature":1}`, `{"models":{"o1-*":{"delete":["temperature"]}}}`, "o1-mini", `{}`},
+		{"other model", `{"temperature":1}`, `{"models":{"o1-*":{"delete":["temperature"]}}}`, "gpt-4o", `{"temperature":1}`},
+		{"channel rule before model rules", `{}`, `{"set":{"a":1},"models":{"gpt-*":{"set":{"a":2}}}}`, "gpt-4o", `{"a":2}`},
+		{"longer prefix and exact name win", `{}`, `{"models":{"gpt-*":{"set":{"a":1,"b":1,"c":1}},"gpt-4*":{"set":{"b":2,"c":2}},"gpt-4o":{"set":{"c":3}}}}`, "gpt-4o", `{"a":1,"b":2,"c":3}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyParamOverride([]byte(tt.body), tt.override, tt.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ApplyParamOverride = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyParamOverrideErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		override string
+	}{
+		{"invalid spec", `{}`, `{"set":`},
+		{"invalid body", `not json`, `{"set":{"a":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyParamOverride([]byte(tt.body), tt.override, "gpt-4o"); err == nil {
+				t.Error("ApplyParamOverride did not fail")
+			}
+		})
+	}
+}
+
+func TestCheckParamOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		wantErr  bool
+	}{
+		{"empty", "", false},
+		{"empty object", "{}", false},
+		{"rules", `{"set":{"a.b":1},"delete":["c"],"default":{"d":2},"models":{"o1-*":{"delete":["temperature"]}}}`, false},
+		{"invalid json", `{"set":`, true},
+		{"unknown field", `{"sets":{"a":1}}`, true},
+		{"unknown field of a model rule", `{"models":{"o1":{"remove":["a"]}}}`, true},
+		{"wrong type", `{"delete":"a"}`, true},
+		{"empty parameter name", `{"set":{"":1}}`, true},
+		{"empty path segment", `{"delete":["a..b"]}`, true},
+		{"empty model name", `{"models":{"":{}}}`, true},
+		{"star in the middle", `{"models":{"o*-mini":{}}}`, true},
+		{"invalid rule of a model", `{"models":{"o1":{"default":{"a.":1}}}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckParamOverride(tt.override); (err != nil) != tt.wantErr {
+				t.Errorf("CheckParamOverride(%s) = %v, want error %v", tt.override, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -10,13 +10,14 @@ import Setting from './pages/Setting';
 import EditUser from './pages/User/EditUser';
 import { getLogo, getSystemName } from './helpers';
 import PasswordResetForm from './components/PasswordResetForm';
-import GitHubOAuth from './components/GitHubOAuth';
+import OAuth2Callback from './components/OAuth2Callback';
 import PasswordResetConfirm from './components/PasswordResetConfirm';
 import { UserContext } from './context/User';
 import Channel from './pages/Channel';
 import Token from './pages/Token';
 import EditChannel from './pages/Channel/EditChannel';
 import Redemption from './pages/Redemption';
+import Audit from './pages/Audit';
 import TopUp from './pages/TopUp';
 import Log from './pages/Log';
 import Chat from './pages/Chat';
@@ -108,6 +109,14 @@ function App() {
               </PrivateRoute>
             }
           />
+          <Route
+            path='/audit'
+            element={
+              <PrivateRoute>
+                <Audit />
+              </PrivateRoute>
+            }
+          />
           <Route
             path='/user'
             element={
@@ -168,7 +177,15 @@ function App() {
             path='/oauth/github'
             element={
               <Suspense fallback={<Loading></Loading>}>
-                <GitHubOAuth />
+                <OAuth2Callback type='github' />
+              </Suspense>
+            }
+          />
+          <Route
+            path='/oauth/oidc'
+            element={
+              <Suspense fallback={<Loading></Loading>}>
+                <OAuth2Callback type='oidc' />
               </Suspense>
             }
           />
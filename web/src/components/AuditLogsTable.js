@@ -0,0 +1,171 @@
+import React, { useEffect, useState } from 'react';
+import { API, showError, timestamp2string } from '../helpers';
+
+import { ITEMS_PER_PAGE } from '../constants';
+import { Button, Form, Table, Tag, Typography } from '@douyinfe/semi-ui';
+
+const AuditLogsTable = () => {
+  const columns = [
+    {
+      title: '时间',
+      dataIndex: 'created_at',
+      render: (text) => <>{timestamp2string(text)}</>,
+    },
+    {
+      title: '操作人',
+      dataIndex: 'username',
+      render: (text, record) => <>{text ? text : '匿名'}</>,
+    },
+    {
+      title: '操作',
+      dataIndex: 'action',
+    },
+    {
+      title: '对象',
+      dataIndex: 'target_type',
+      render: (text, record) => (
+        <>{record.target_id ? `${text} #${record.target_id}` : text}</>
+      ),
+    },
+    {
+      title: '结果',
+      dataIndex: 'success',
+      render: (text) =>
+        text ? (
+          <Tag color='green' size='large'>
+            成功
+          </Tag>
+        ) : (
+          <Tag color='red' size='large'>
+            失败
+          </Tag>
+        ),
+    },
+    {
+      title: 'IP',
+      dataIndex: 'ip',
+    },
+    {
+      title: '变更',
+      dataIndex: 'changes',
+      render: (text) => (
+        <Typography.Paragraph
+          ellipsis={{ rows: 2, expandable: true }}
+          style={{ maxWidth: 400, fontFamily: 'JetBrains Mono, Consolas' }}
+        >
+          {text}
+        </Typography.Paragraph>
+      ),
+    },
+  ];
+
+  const [logs, setLogs] = useState([]);
+  const [loading, setLoading] = useState(true);
+  const [activePage, setActivePage] = useState(1);
+  const [logCount, setLogCount] = useState(0);
+  const [inputs, setInputs] = useState({
+    username: '',
+    action: '',
+    target_type: '',
+    target_id: '',
+  });
+
+  const getQuery = () =>
+    Object.keys(inputs)
+      .filter((key) => inputs[key] !== '')
+      .map((key) => `&${key}=${encodeURIComponent(inputs[key])}`)
+      .join('');
+
+  const loadLogs = async (page) => {
+    setLoading(true);
+    const res = await API.get(
+      `/api/audit/?p=${page - 1}&page_size=${ITEMS_PER_PAGE}${getQuery()}`,
+    );
+    const { success, message, data, total } = res.data;
+    if (success) {
+      setLogs(data);
+      setLogCount(total);
+    } else {
+      showError(message);
+    }
+    setLoading(false);
+  };
+
+  useEffect(() => {
+    loadLogs(1).then();
+  }, []);
+
+  const handlePageChange = (page) => {
+    setActivePage(page);
+    loadLogs(page).then();
+  };
+
+  const search = () => {
+    setActivePage(1);
+    loadLogs(1).then();
+  };
+
+  const exportLogs = () => {
+    window.open(`/api/audit/export?${getQuery().slice(1)}`);
+  };
+
+  const handleInputChange = (value, name) => {
+    setInputs((inputs) => ({ ...inputs, [name]: value }));
+  };
+
+  return (
+    <>
+      <Form layout='horizontal' style={{ marginTop: 10 }}>
+        <Form.Input
+          field='username'
+          label='操作人'
+          placeholder='用户名'
+          onChange={(value) => handleInputChange(value, 'username')}
+        />
+        <Form.Input
+          field='action'
+          label='操作'
+          placeholder='例如：/api/channel/'
+          onChange={(value) => handleInputChange(value, 'action')}
+        />
+        <Form.Input
+          field='target_type'
+          label='对象类型'
+          placeholder='例如：channel'
+          onChange={(value) => handleInputChange(value, 'target_type')}
+        />
+        <Form.Input
+          field='target_id'
+          label='对象 ID'
+          onChange={(value) => handleInputChange(value, 'target_id')}
+        />
+        <Button
+          label='查询'
+          type='primary'
+          htmlType='submit'
+          style={{ marginRight: 8 }}
+          onClick={search}
+          loading={loading}
+        >
+          查询
+        </Button>
+        <Button onClick={exportLogs}>导出 CSV</Button>
+      </Form>
+      <Table
+        style={{ marginTop: 5 }}
+        columns={columns}
+        dataSource={logs}
+        rowKey='id'
+        loading={loading}
+        pagination={{
+          currentPage: activePage,
+          pageSize: ITEMS_PER_PAGE,
+          total: logCount,
+          onPageChange: handlePageChange,
+        }}
+      />
+    </>
+  );
+};
+
+export default AuditLogsTable;
@@ -2,6 +2,7 @@ import React, { useEffect, useState } from 'react';
 import {
   API,
   isMobile,
+  isRoot,
   shouldShowPrompt,
   showError,
   showInfo,
@@ -631,6 +632,16 @@ const ChannelsTable = () => {
     }
   };
 
+  const reencryptChannelKeys = async () => {
+    const res = await API.post(`/api/channel/reencrypt_keys`);
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(`已重新加密 ${data} 个通道的密钥！`);
+    } else {
+      showError(message);
+    }
+  };
+
   let pageData = channels.slice(
     (activePage - 1) * pageSize,
     activePage * pageSize,
@@ -907,6 +918,19 @@ const ChannelsTable = () => {
               修复数据库一致性
             </Button>
           </Popconfirm>
+          {isRoot() && (
+            <Popconfirm
+              title='确定是否要重新加密所有渠道密钥？'
+              content='将使用 CHANNEL_KEY_SECRET 加密明文存储或使用旧主密钥加密的渠道密钥'
+              okType={'warning'}
+              onConfirm={reencryptChannelKeys}
+              position={'top'}
+            >
+              <Button theme='light' type='secondary' style={{ marginRight: 8 }}>
+                重新加密渠道密钥
+              </Button>
+            </Popconfirm>
+          )}
         </Space>
       </div>
     </>
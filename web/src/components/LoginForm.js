@@ -2,7 +2,7 @@ import React, { useContext, useEffect, useState } from 'react';
 import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 import { UserContext } from '../context/User';
 import { API, getLogo, showError, showInfo, showSuccess } from '../helpers';
-import { onGitHubOAuthClicked } from './utils';
+import { onGitHubOAuthClicked, onOIDCClicked } from './utils';
 import Turnstile from 'react-turnstile';
 import {
   Button,
@@ -17,7 +17,7 @@ import Title from '@douyinfe/semi-ui/lib/es/typography/title';
 import Text from '@douyinfe/semi-ui/lib/es/typography/text';
 import TelegramLoginButton from 'react-telegram-login';
 
-import { IconGithubLogo } from '@douyinfe/semi-icons';
+import { IconGithubLogo, IconKey } from '@douyinfe/semi-icons';
 import WeChatIcon from './WeChatIcon';
 import { setUserData } from '../helpers/data.js';
 
@@ -42,6 +42,9 @@ const LoginForm = () => {
     if (searchParams.get('expired')) {
       showError('未登录或登录已过期，请重新登录！');
     }
+    if (searchParams.get('2fa')) {
+      setShowTwoFAModal(true);
+    }
     let status = localStorage.getItem('status');
     if (status) {
       status = JSON.parse(status);
@@ -54,6 +57,26 @@ const LoginForm = () => {
   }, []);
 
   const [showWeChatLoginModal, setShowWeChatLoginModal] = useState(false);
+  const [showTwoFAModal, setShowTwoFAModal] = useState(false);
+  const [twoFACode, setTwoFACode] = useState('');
+
+  const onSubmitTwoFACode = async () => {
+    if (twoFACode === '') {
+      showInfo('请输入两步验证码！');
+      return;
+    }
+    const res = await API.post('/api/user/login/2fa', { code: twoFACode });
+    const { success, message, data } = res.data;
+    if (success) {
+      userDispatch({ type: 'login', payload: data });
+      setUserData(data);
+      showSuccess('登录成功！');
+      setShowTwoFAModal(false);
+      navigate('/token');
+    } else {
+      showError(message);
+    }
+  };
 
   const onWeChatLoginClicked = () => {
     setShowWeChatLoginModal(true);
@@ -69,11 +92,15 @@ const LoginForm = () => {
     );
     const { success, message, data } = res.data;
     if (success) {
+      setShowWeChatLoginModal(false);
+      if (data.require_2fa) {
+        setShowTwoFAModal(true);
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       navigate('/');
       showSuccess('登录成功！');
-      setShowWeChatLoginModal(false);
     } else {
       showError(message);
     }
@@ -99,6 +126,10 @@ const LoginForm = () => {
       );
       const { success, message, data } = res.data;
       if (success) {
+        if (data.require_2fa) {
+          setShowTwoFAModal(true);
+          return;
+        }
         userDispatch({ type: 'login', payload: data });
         setUserData(data);
         showSuccess('登录成功！');
@@ -139,6 +170,10 @@ const LoginForm = () => {
     const res = await API.get(`/api/oauth/telegram/login`, { params });
     const { success, message, data } = res.data;
     if (success) {
+      if (data.require_2fa) {
+        setShowTwoFAModal(true);
+        return;
+      }
       userDispatch({ type: 'login', payload: data });
       localStorage.setItem('user', JSON.stringify(data));
       showSuccess('登录成功！');
@@ -208,6 +243,7 @@ const LoginForm = () => {
                   </Text>
                 </div>
                 {status.github_oauth ||
+                status.oidc_enabled ||
                 status.wechat_login ||
                 status.telegram_oauth ? (
                   <>
@@ -232,6 +268,17 @@ const LoginForm = () => {
                       ) : (
                         <></>
                       )}
+                      {status.oidc_enabled ? (
+                        <Button
+                          type='primary'
+                          icon={<IconKey />}
+                          onClick={onOIDCClicked}
+                        >
+                          {status.oidc_name}
+                        </Button>
+                      ) : (
+                        <></>
+                      )}
                       {status.wechat_login ? (
                         <Button
                           type='primary'
@@ -301,6 +348,27 @@ const LoginForm = () => {
                     />
                   </Form>
                 </Modal>
+                <Modal
+                  title='两步验证'
+                  visible={showTwoFAModal}
+                  maskClosable={false}
+                  onOk={onSubmitTwoFACode}
+                  onCancel={() => setShowTwoFAModal(false)}
+                  okText={'登录'}
+                  size={'small'}
+                  centered={true}
+                >
+                  <p>请输入身份验证器中的验证码，或使用一个恢复码</p>
+                  <Form size='large'>
+                    <Form.Input
+                      field={'two_fa_code'}
+                      placeholder='验证码或恢复码'
+                      label={'验证码'}
+                      value={twoFACode}
+                      onChange={(value) => setTwoFACode(value)}
+                    />
+                  </Form>
+                </Modal>
               </Card>
               {turnstileEnabled ? (
                 <div
@@ -2,6 +2,7 @@ import React, { useEffect, useState } from 'react';
 import {
   API,
   copy,
+  hasPermission,
   isAdmin,
   showError,
   showSuccess,
@@ -379,7 +380,7 @@ const LogsTable = () => {
   const [searchKeyword, setSearchKeyword] = useState('');
   const [searching, setSearching] = useState(false);
   const [logType, setLogType] = useState(0);
-  const isAdminUser = isAdmin();
+  const isAdminUser = hasPermission('logs:read');
   let now = new Date();
   // 初始化start_timestamp为前一天
   const [inputs, setInputs] = useState({
@@ -4,7 +4,7 @@ import { useNavigate, useSearchParams } from 'react-router-dom';
 import { API, showError, showSuccess } from '../helpers';
 import { UserContext } from '../context/User';
 
-const GitHubOAuth = () => {
+const OAuth2Callback = (props) => {
   const [searchParams, setSearchParams] = useSearchParams();
 
   const [userState, userDispatch] = useContext(UserContext);
@@ -14,12 +14,16 @@ const GitHubOAuth = () => {
   let navigate = useNavigate();
 
   const sendCode = async (code, state, count) => {
-    const res = await API.get(`/api/oauth/github?code=${code}&state=${state}`);
+    const res = await API.get(
+      `/api/oauth/${props.type}?code=${code}&state=${state}`,
+    );
     const { success, message, data } = res.data;
     if (success) {
       if (message === 'bind') {
         showSuccess('绑定成功！');
         navigate('/setting');
+      } else if (data.require_2fa) {
+        navigate('/login?2fa=true');
       } else {
         userDispatch({ type: 'login', payload: data });
         localStorage.setItem('user', JSON.stringify(data));
@@ -30,7 +34,7 @@ const GitHubOAuth = () => {
       showError(message);
       if (count === 0) {
         setPrompt(`操作失败，重定向至登录界面中...`);
-        navigate('/setting'); // in case this is failed to bind GitHub
+        navigate('/setting'); // in case this is failed to bind
         return;
       }
       count++;
@@ -55,4 +59,4 @@ const GitHubOAuth = () => {
   );
 };
 
-export default GitHubOAuth;
+export default OAuth2Callback;
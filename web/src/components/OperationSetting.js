@@ -18,11 +18,16 @@ const OperationSetting = () => {
     QuotaForInvitee: 0,
     QuotaRemindThreshold: 0,
     PreConsumedQuota: 0,
+    BatchDiscount: 0,
     StreamCacheQueueLength: 0,
     ModelRatio: '',
     CompletionRatio: '',
     ModelPrice: '',
     GroupRatio: '',
+    GroupChannelSelectMode: '',
+    GroupModelFallback: '',
+    ModelAliases: '',
+    GroupHedgeDelay: '',
     TopUpLink: '',
     ChatLink: '',
     ChatLink2: '', // 添加的新状态变量
@@ -30,6 +35,13 @@ const OperationSetting = () => {
     AutomaticDisableChannelEnabled: false,
     AutomaticEnableChannelEnabled: false,
     ChannelDisableThreshold: 0,
+    CircuitBreakerEnabled: false,
+    CircuitBreakerThreshold: 0,
+    CircuitBreakerCooldown: 0,
+    CircuitBreakerHalfOpenRate: 0,
+    StickyRoutingEnabled: false,
+    StickyRoutingHeader: '',
+    StickyRoutingTTL: 0,
     LogConsumeEnabled: false,
     DisplayInCurrencyEnabled: false,
     DisplayTokenStatEnabled: false,
@@ -61,6 +73,10 @@ const OperationSetting = () => {
         if (
           item.key === 'ModelRatio' ||
           item.key === 'GroupRatio' ||
+          item.key === 'GroupChannelSelectMode' ||
+          item.key === 'GroupModelFallback' ||
+          item.key === 'ModelAliases' ||
+          item.key === 'GroupHedgeDelay' ||
           item.key === 'CompletionRatio' ||
           item.key === 'ModelPrice'
         ) {
@@ -10,7 +10,7 @@ import {
 } from '../helpers';
 import Turnstile from 'react-turnstile';
 import { UserContext } from '../context/User';
-import { onGitHubOAuthClicked } from './utils';
+import { onGitHubOAuthClicked, onOIDCClicked } from './utils';
 import {
   Avatar,
   Banner,
@@ -62,6 +62,10 @@ const PersonalSetting = () => {
   const [models, setModels] = useState([]);
   const [openTransfer, setOpenTransfer] = useState(false);
   const [transferAmount, setTransferAmount] = useState(0);
+  const [twoFAStatus, setTwoFAStatus] = useState({});
+  const [twoFASetup, setTwoFASetup] = useState(null);
+  const [twoFACode, setTwoFACode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState([]);
 
   useEffect(() => {
     // let user = localStorage.getItem('user');
@@ -84,6 +88,7 @@ const PersonalSetting = () => {
     });
     loadModels().then();
     getAffLink().then();
+    loadTwoFAStatus().then();
     setTransferAmount(getQuotaPerUnit());
   }, []);
 
@@ -116,6 +121,72 @@ const PersonalSetting = () => {
     }
   };
 
+  const loadTwoFAStatus = async () => {
+    const res = await API.get('/api/user/2fa/status');
+    const { success, message, data } = res.data;
+    if (success) {
+      setTwoFAStatus(data);
+      if (data.required && !data.enabled) {
+        showInfo('管理员要求你的账户启用两步验证，请先完成设置');
+      }
+    } else {
+      showError(message);
+    }
+  };
+
+  const setupTwoFA = async () => {
+    const res = await API.post('/api/user/2fa/setup');
+    const { success, message, data } = res.data;
+    if (success) {
+      setTwoFACode('');
+      setTwoFASetup(data);
+    } else {
+      showError(message);
+    }
+  };
+
+  const enableTwoFA = async () => {
+    const res = await API.post('/api/user/2fa/enable', { code: twoFACode });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess('两步验证已启用，请妥善保存恢复码');
+      setTwoFASetup(null);
+      setTwoFACode('');
+      setRecoveryCodes(data);
+      await loadTwoFAStatus();
+    } else {
+      showError(message);
+    }
+  };
+
+  const disableTwoFA = async () => {
+    const res = await API.post('/api/user/2fa/disable', { code: twoFACode });
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess('两步验证已关闭');
+      setTwoFACode('');
+      setRecoveryCodes([]);
+      await loadTwoFAStatus();
+    } else {
+      showError(message);
+    }
+  };
+
+  const regenerateRecoveryCodes = async () => {
+    const res = await API.post('/api/user/2fa/recovery_codes', {
+      code: twoFACode,
+    });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess('恢复码已重新生成，旧的恢复码已失效');
+      setTwoFACode('');
+      setRecoveryCodes(data);
+      await loadTwoFAStatus();
+    } else {
+      showError(message);
+    }
+  };
+
   const getAffLink = async () => {
     const res = await API.get('/api/user/aff');
     const { success, message, data } = res.data;
@@ -519,6 +590,36 @@ const PersonalSetting = () => {
                   </div>
                 </div>
               </div>
+              <div style={{ marginTop: 10 }}>
+                <Typography.Text strong>
+                  {status.oidc_name ? status.oidc_name : 'OIDC'}
+                </Typography.Text>
+                <div
+                  style={{ display: 'flex', justifyContent: 'space-between' }}
+                >
+                  <div>
+                    <Input
+                      value={
+                        userState.user && userState.user.oidc_id
+                          ? userState.user.oidc_id
+                          : '未绑定'
+                      }
+                      readonly={true}
+                    ></Input>
+                  </div>
+                  <div>
+                    <Button
+                      onClick={onOIDCClicked}
+                      disabled={
+                        (userState.user && userState.user.oidc_id) ||
+                        !status.oidc_enabled
+                      }
+                    >
+                      {status.oidc_enabled ? '绑定' : '未启用'}
+                    </Button>
+                  </div>
+                </div>
+              </div>
 
               <div style={{ marginTop: 10 }}>
                 <Typography.Text strong>Telegram</Typography.Text>
@@ -552,6 +653,94 @@ const PersonalSetting = () => {
                 </div>
               </div>
 
+              <div style={{ marginTop: 20 }}>
+                <Typography.Text strong>两步验证</Typography.Text>
+                <div
+                  style={{
+                    display: 'flex',
+                    justifyContent: 'space-between',
+                    marginTop: 10,
+                  }}
+                >
+                  <div>
+                    <Input
+                      value={
+                        twoFAStatus.enabled
+                          ? `已启用，剩余 ${twoFAStatus.remaining_recovery_codes} 个恢复码`
+                          : '未启用'
+                      }
+                      readonly={true}
+                    ></Input>
+                  </div>
+                  <div>
+                    {twoFAStatus.enabled ? (
+                      <Space>
+                        <Input
+                          placeholder='验证码或恢复码'
+                          value={twoFACode}
+                          onChange={(v) => setTwoFACode(v)}
+                        />
+                        <Button onClick={regenerateRecoveryCodes}>
+                          重新生成恢复码
+                        </Button>
+                        <Button
+                          type={'danger'}
+                          disabled={twoFAStatus.required}
+                          onClick={disableTwoFA}
+                        >
+                          关闭
+                        </Button>
+                      </Space>
+                    ) : (
+                      <Button onClick={setupTwoFA}>启用</Button>
+                    )}
+                  </div>
+                </div>
+                {recoveryCodes.length > 0 && (
+                  <div style={{ marginTop: 10 }}>
+                    <Typography.Text type='warning'>
+                      恢复码只显示这一次，每个恢复码只能使用一次：
+                    </Typography.Text>
+                    <Input
+                      readOnly
+                      value={recoveryCodes.join(' ')}
+                      onClick={() => copy(recoveryCodes.join('\n'))}
+                      style={{ marginTop: '10px' }}
+                    />
+                  </div>
+                )}
+                <Modal
+                  title='启用两步验证'
+                  onCancel={() => setTwoFASetup(null)}
+                  onOk={enableTwoFA}
+                  visible={twoFASetup !== null}
+                  size={'small'}
+                  centered={true}
+                  maskClosable={false}
+                >
+                  <p>
+                    使用身份验证器应用添加以下密钥（或导入链接），然后输入应用生成的验证码
+                  </p>
+                  <Input
+                    readOnly
+                    value={twoFASetup ? twoFASetup.secret : ''}
+                    style={{ marginTop: '10px' }}
+                  />
+                  <Input
+                    readOnly
+                    value={twoFASetup ? twoFASetup.uri : ''}
+                    onClick={() => twoFASetup && copy(twoFASetup.uri)}
+                    style={{ marginTop: '10px' }}
+                  />
+                  <Input
+                    placeholder='验证码'
+                    value={twoFACode}
+                    onChange={(v) => setTwoFACode(v)}
+                    style={{ marginTop: '10px' }}
+                  />
+                </Modal>
+              </div>
+
               <div style={{ marginTop: 10 }}>
                 <Space>
                   <Button onClick={generateAccessToken}>
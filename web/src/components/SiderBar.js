@@ -7,7 +7,8 @@ import {
   API,
   getLogo,
   getSystemName,
-  isAdmin,
+  hasPermission,
+  isRoot,
   isMobile,
   showError,
 } from '../helpers';
@@ -16,6 +17,7 @@ import '../index.css';
 import {
   IconCalendarClock,
   IconComment,
+  IconFile,
   IconCreditCard,
   IconGift,
   IconHistogram,
@@ -52,6 +54,7 @@ const SiderBar = () => {
     topup: '/topup',
     user: '/user',
     log: '/log',
+    audit: '/audit',
     midjourney: '/midjourney',
     setting: '/setting',
     about: '/about',
@@ -73,7 +76,9 @@ const SiderBar = () => {
         itemKey: 'channel',
         to: '/channel',
         icon: <IconLayers />,
-        className: isAdmin() ? 'semi-navigation-item-normal' : 'tableHiddle',
+        className: hasPermission('channels:read')
+          ? 'semi-navigation-item-normal'
+          : 'tableHiddle',
       },
       {
         text: '聊天',
@@ -95,7 +100,9 @@ const SiderBar = () => {
         itemKey: 'redemption',
         to: '/redemption',
         icon: <IconGift />,
-        className: isAdmin() ? 'semi-navigation-item-normal' : 'tableHiddle',
+        className: hasPermission('billing:manage')
+          ? 'semi-navigation-item-normal'
+          : 'tableHiddle',
       },
       {
         text: '钱包',
@@ -114,7 +121,9 @@ const SiderBar = () => {
         itemKey: 'user',
         to: '/user',
         icon: <IconUser />,
-        className: isAdmin() ? 'semi-navigation-item-normal' : 'tableHiddle',
+        className: hasPermission('users:read')
+          ? 'semi-navigation-item-normal'
+          : 'tableHiddle',
       },
       {
         text: '日志',
@@ -122,6 +131,13 @@ const SiderBar = () => {
         to: '/log',
         icon: <IconHistogram />,
       },
+      {
+        text: '审计日志',
+        itemKey: 'audit',
+        to: '/audit',
+        icon: <IconFile />,
+        className: isRoot() ? 'semi-navigation-item-normal' : 'tableHiddle',
+      },
       {
         text: '数据看板',
         itemKey: 'detail',
@@ -159,7 +175,7 @@ const SiderBar = () => {
       localStorage.getItem('enable_data_export'),
       localStorage.getItem('enable_drawing'),
       localStorage.getItem('chat_link'),
-      isAdmin(),
+      localStorage.getItem('user'),
     ],
   );
 
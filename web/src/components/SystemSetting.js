@@ -20,6 +20,16 @@ const SystemSetting = () => {
     GitHubOAuthEnabled: '',
     GitHubClientId: '',
     GitHubClientSecret: '',
+    OIDCEnabled: '',
+    OIDCName: '',
+    OIDCIssuer: '',
+    OIDCClientId: '',
+    OIDCClientSecret: '',
+    OIDCScopes: '',
+    OIDCUsernameClaim: '',
+    OIDCEmailClaim: '',
+    OIDCGroupClaim: '',
+    OIDCGroupMapping: '',
     Notice: '',
     SMTPServer: '',
     SMTPPort: '',
@@ -53,6 +63,8 @@ const SystemSetting = () => {
     TelegramOAuthEnabled: '',
     TelegramBotToken: '',
     TelegramBotName: '',
+    TwoFARequiredRole: '0',
+    AdminRoles: '',
   });
   const [originInputs, setOriginInputs] = useState({});
   let [loading, setLoading] = useState(false);
@@ -70,7 +82,7 @@ const SystemSetting = () => {
     if (success) {
       let newInputs = {};
       data.forEach((item) => {
-        if (item.key === 'TopupGroupRatio') {
+        if (item.key === 'TopupGroupRatio' || item.key === 'AdminRoles') {
           item.value = JSON.stringify(JSON.parse(item.value), null, 2);
         }
         newInputs[item.key] = item.value;
@@ -103,6 +115,7 @@ const SystemSetting = () => {
       case 'PasswordRegisterEnabled':
       case 'EmailVerificationEnabled':
       case 'GitHubOAuthEnabled':
+      case 'OIDCEnabled':
       case 'WeChatAuthEnabled':
       case 'TelegramOAuthEnabled':
       case 'TurnstileCheckEnabled':
@@ -155,6 +168,7 @@ const SystemSetting = () => {
       name === 'PayAddress' ||
       name === 'GitHubClientId' ||
       name === 'GitHubClientSecret' ||
+      (name.startsWith('OIDC') && name !== 'OIDCEnabled') ||
       name === 'WeChatServerAddress' ||
       name === 'WeChatServerToken' ||
       name === 'WeChatAccountQRCodeImageURL' ||
@@ -162,6 +176,7 @@ const SystemSetting = () => {
       name === 'TurnstileSecretKey' ||
       name === 'EmailDomainWhitelist' ||
       name === 'TopupGroupRatio' ||
+      name === 'AdminRoles' ||
       name === 'TelegramBotToken' ||
       name === 'TelegramBotName'
     ) {
@@ -280,6 +295,43 @@ const SystemSetting = () => {
     }
   };
 
+  const submitOIDC = async () => {
+    const keys = [
+      'OIDCName',
+      'OIDCIssuer',
+      'OIDCClientId',
+      'OIDCScopes',
+      'OIDCUsernameClaim',
+      'OIDCEmailClaim',
+      'OIDCGroupClaim',
+      'OIDCGroupMapping',
+    ];
+    for (const key of keys) {
+      let value = inputs[key];
+      if (key === 'OIDCIssuer') {
+        value = removeTrailingSlash(value);
+      }
+      if (originInputs[key] !== value) {
+        await updateOption(key, value);
+      }
+    }
+    if (
+      originInputs['OIDCClientSecret'] !== inputs.OIDCClientSecret &&
+      inputs.OIDCClientSecret !== ''
+    ) {
+      await updateOption('OIDCClientSecret', inputs.OIDCClientSecret);
+    }
+  };
+
+  const submitAdminRoles = async () => {
+    if (originInputs['AdminRoles'] === inputs.AdminRoles) return;
+    if (!verifyJSON(inputs.AdminRoles)) {
+      showError('管理角色不是合法的 JSON 字符串');
+      return;
+    }
+    await updateOption('AdminRoles', inputs.AdminRoles);
+  };
+
   const submitTelegramSettings = async () => {
     // await updateOption('TelegramOAuthEnabled', inputs.TelegramOAuthEnabled);
     await updateOption('TelegramBotToken', inputs.TelegramBotToken);
@@ -483,6 +535,12 @@ const SystemSetting = () => {
               name='GitHubOAuthEnabled'
               onChange={handleInputChange}
             />
+            <Form.Checkbox
+              checked={inputs.OIDCEnabled === 'true'}
+              label='允许通过 OIDC 账户登录 & 注册'
+              name='OIDCEnabled'
+              onChange={handleInputChange}
+            />
             <Form.Checkbox
               checked={inputs.WeChatAuthEnabled === 'true'}
               label='允许通过微信登录 & 注册'
@@ -510,6 +568,38 @@ const SystemSetting = () => {
               onChange={handleInputChange}
             />
           </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Dropdown
+              label='强制启用两步验证'
+              name='TwoFARequiredRole'
+              selection
+              onChange={handleInputChange}
+              value={inputs.TwoFARequiredRole}
+              options={[
+                { key: '0', text: '不强制', value: '0' },
+                { key: '1', text: '所有用户', value: '1' },
+                { key: '10', text: '管理员及以上', value: '10' },
+                { key: '100', text: '仅超级管理员', value: '100' },
+              ]}
+            />
+          </Form.Group>
+          <Divider />
+          <Header as='h3' inverted={isDark}>
+            配置管理角色
+            <Header.Subheader>
+              管理员的权限由其管理角色决定，未设置管理角色的管理员拥有除系统设置外的全部权限
+            </Header.Subheader>
+          </Header>
+          <Form.TextArea
+            label='管理角色'
+            name='AdminRoles'
+            onChange={handleInputChange}
+            style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+            autoComplete='new-password'
+            value={inputs.AdminRoles}
+            placeholder='为一个 JSON 文本，键为角色名称，值为权限列表，可用权限：channels:read、channels:write、users:read、users:manage、logs:read、logs:manage、billing:manage、options:read、options:write'
+          />
+          <Form.Button onClick={submitAdminRoles}>保存管理角色</Form.Button>
           <Divider />
           <Header as='h3' inverted={isDark}>
             配置邮箱域名白名单
@@ -677,6 +767,93 @@ const SystemSetting = () => {
             保存 GitHub OAuth 设置
           </Form.Button>
           <Divider />
+          <Header as='h3' inverted={isDark}>
+            配置 OIDC
+            <Header.Subheader>
+              用以支持通过 Keycloak、Okta 等 OpenID Connect 服务进行登录注册
+            </Header.Subheader>
+          </Header>
+          <Message>
+            回调地址（Redirect URI）填{' '}
+            <code>{`${inputs.ServerAddress}/oauth/oidc`}</code>
+            ，服务配置通过 Issuer 下的 /.well-known/openid-configuration 自动发现
+          </Message>
+          <Form.Group widths={3}>
+            <Form.Input
+              label='显示名称'
+              name='OIDCName'
+              onChange={handleInputChange}
+              value={inputs.OIDCName}
+              placeholder='登录按钮上显示的名称，例如：Keycloak'
+            />
+            <Form.Input
+              label='Issuer'
+              name='OIDCIssuer'
+              onChange={handleInputChange}
+              value={inputs.OIDCIssuer}
+              placeholder='例如：https://sso.example.com/realms/main'
+            />
+            <Form.Input
+              label='Scopes'
+              name='OIDCScopes'
+              onChange={handleInputChange}
+              value={inputs.OIDCScopes}
+              placeholder='以空格分隔，例如：openid profile email'
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label='Client ID'
+              name='OIDCClientId'
+              onChange={handleInputChange}
+              autoComplete='new-password'
+              value={inputs.OIDCClientId}
+              placeholder='输入 OIDC 客户端的 ID'
+            />
+            <Form.Input
+              label='Client Secret'
+              name='OIDCClientSecret'
+              onChange={handleInputChange}
+              type='password'
+              autoComplete='new-password'
+              value={inputs.OIDCClientSecret}
+              placeholder='敏感信息不会发送到前端显示'
+            />
+          </Form.Group>
+          <Form.Group widths={3}>
+            <Form.Input
+              label='用户名字段'
+              name='OIDCUsernameClaim'
+              onChange={handleInputChange}
+              value={inputs.OIDCUsernameClaim}
+              placeholder='例如：preferred_username'
+            />
+            <Form.Input
+              label='邮箱字段'
+              name='OIDCEmailClaim'
+              onChange={handleInputChange}
+              value={inputs.OIDCEmailClaim}
+              placeholder='例如：email'
+            />
+            <Form.Input
+              label='分组字段'
+              name='OIDCGroupClaim'
+              onChange={handleInputChange}
+              value={inputs.OIDCGroupClaim}
+              placeholder='留空则不同步分组，支持嵌套字段，例如：realm_access.roles'
+            />
+          </Form.Group>
+          <Form.TextArea
+            label='分组映射'
+            name='OIDCGroupMapping'
+            onChange={handleInputChange}
+            style={{ minHeight: 150, fontFamily: 'JetBrains Mono, Consolas' }}
+            autoComplete='new-password'
+            value={inputs.OIDCGroupMapping}
+            placeholder='为一个 JSON 文本，键为分组字段的取值，值为分组名称，例如：{"llm-vip": "vip"}；留空则直接使用与已有分组同名的取值'
+          />
+          <Form.Button onClick={submitOIDC}>保存 OIDC 设置</Form.Button>
+          <Divider />
           <Header as='h3' inverted={isDark}>
             配置 WeChat Server
             <Header.Subheader>
@@ -1,7 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import {
   API,
-  copy,
   showError,
   showSuccess,
   timestamp2string,
@@ -13,7 +12,6 @@ import {
   Button,
   Dropdown,
   Form,
-  Modal,
   Popconfirm,
   Popover,
   SplitButtonGroup,
@@ -172,7 +170,7 @@ const TokensTable = () => {
       render: (text, record, index) => (
         <div>
           <Popover
-            content={'sk-' + record.key}
+            content={`sk-${record.key_prefix}...（完整令牌仅在创建时显示）`}
             style={{ padding: 20 }}
             position='top'
           >
@@ -180,16 +178,6 @@ const TokensTable = () => {
               查看
             </Button>
           </Popover>
-          <Button
-            theme='light'
-            type='secondary'
-            style={{ marginRight: 1 }}
-            onClick={async (text) => {
-              await copyText('sk-' + record.key);
-            }}
-          >
-            复制
-          </Button>
           <SplitButtonGroup
             style={{ marginRight: 1 }}
             aria-label='项目操作按钮组'
@@ -198,7 +186,7 @@ const TokensTable = () => {
               theme='light'
               style={{ color: 'rgba(var(--semi-teal-7), 1)' }}
               onClick={() => {
-                onOpenLink('next', record.key);
+                onOpenLink('next');
               }}
             >
               聊天
@@ -213,7 +201,7 @@ const TokensTable = () => {
                   disabled: !localStorage.getItem('chat_link'),
                   name: 'ChatGPT Next Web',
                   onClick: () => {
-                    onOpenLink('next', record.key);
+                    onOpenLink('next');
                   },
                 },
                 {
@@ -222,7 +210,7 @@ const TokensTable = () => {
                   disabled: !localStorage.getItem('chat_link2'),
                   name: 'ChatGPT Web & Midjourney',
                   onClick: () => {
-                    onOpenLink('next-mj', record.key);
+                    onOpenLink('next-mj');
                   },
                 },
                 {
@@ -230,7 +218,7 @@ const TokensTable = () => {
                   key: 'ama',
                   name: 'AMA 问天（BotGem）',
                   onClick: () => {
-                    onOpenLink('ama', record.key);
+                    onOpenLink('ama');
                   },
                 },
                 {
@@ -238,7 +226,7 @@ const TokensTable = () => {
                   key: 'opencat',
                   name: 'OpenCat',
                   onClick: () => {
-                    onOpenLink('opencat', record.key);
+                    onOpenLink('opencat');
                   },
                 },
               ]}
@@ -260,7 +248,7 @@ const TokensTable = () => {
             position={'left'}
             onConfirm={() => {
               manageToken(record.id, 'delete', record).then(() => {
-                removeRecord(record.key);
+                removeRecord(record.id);
               });
             }}
           >
@@ -310,7 +298,6 @@ const TokensTable = () => {
   const [pageSize, setPageSize] = useState(ITEMS_PER_PAGE);
   const [showEdit, setShowEdit] = useState(false);
   const [tokens, setTokens] = useState([]);
-  const [selectedKeys, setSelectedKeys] = useState([]);
   const [tokenCount, setTokenCount] = useState(pageSize);
   const [loading, setLoading] = useState(true);
   const [activePage, setActivePage] = useState(1);
@@ -422,19 +409,15 @@ const TokensTable = () => {
     // }
   };
 
-  const copyText = async (text) => {
-    if (await copy(text)) {
-      showSuccess('已复制到剪贴板！');
-    } else {
-      Modal.error({
-        title: '无法复制到剪贴板，请手动复制',
-        content: text,
-        size: 'large',
-      });
+  const onOpenLink = async (type) => {
+    // only the prefix of a token is kept, the full key has to be entered again
+    let key = window.prompt(
+      '完整令牌仅在创建时显示，请输入要使用的令牌（sk-...）',
+    );
+    if (!key) {
+      return;
     }
-  };
-
-  const onOpenLink = async (type, key) => {
+    key = key.trim().replace(/^sk-/, '');
     let status = localStorage.getItem('status');
     let serverAddress = '';
     if (status) {
@@ -484,10 +467,10 @@ const TokensTable = () => {
       });
   }, [pageSize]);
 
-  const removeRecord = (key) => {
+  const removeRecord = (id) => {
     let newDataSource = [...tokens];
-    if (key != null) {
-      let idx = newDataSource.findIndex((data) => data.key === key);
+    if (id != null) {
+      let idx = newDataSource.findIndex((data) => data.id === id);
 
       if (idx > -1) {
         newDataSource.splice(idx, 1);
@@ -582,14 +565,6 @@ const TokensTable = () => {
     }
   };
 
-  const rowSelection = {
-    onSelect: (record, selected) => {},
-    onSelectAll: (selected, selectedRows) => {},
-    onChange: (selectedRowKeys, selectedRows) => {
-      setSelectedKeys(selectedRows);
-    },
-  };
-
   const handleRow = (record, index) => {
     if (record.status !== 1) {
       return {
@@ -662,7 +637,7 @@ const TokensTable = () => {
           onPageChange: handlePageChange,
         }}
         loading={loading}
-        rowSelection={rowSelection}
+        rowKey='id'
         onRow={handleRow}
       ></Table>
       <Button
@@ -678,24 +653,6 @@ const TokensTable = () => {
       >
         添加令牌
       </Button>
-      <Button
-        label='复制所选令牌'
-        type='warning'
-        onClick={async () => {
-          if (selectedKeys.length === 0) {
-            showError('请至少选择一个令牌！');
-            return;
-          }
-          let keys = '';
-          for (let i = 0; i < selectedKeys.length; i++) {
-            keys +=
-              selectedKeys[i].name + '    sk-' + selectedKeys[i].key + '\n';
-          }
-          await copyText(keys);
-        }}
-      >
-        复制所选令牌到剪贴板
-      </Button>
     </>
   );
 };
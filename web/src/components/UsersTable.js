@@ -197,6 +197,21 @@ const UsersTable = () => {
                   启用
                 </Button>
               )}
+              <Popconfirm
+                title='确定重置此用户的两步验证？'
+                okType={'warning'}
+                onConfirm={() => {
+                  manageUser(record.username, 'reset_2fa', record);
+                }}
+              >
+                <Button
+                  theme='light'
+                  type='secondary'
+                  style={{ marginRight: 1 }}
+                >
+                  重置2FA
+                </Button>
+              </Popconfirm>
               <Button
                 theme='light'
                 type='tertiary'
@@ -19,6 +19,16 @@ export async function onGitHubOAuthClicked(github_client_id) {
   );
 }
 
+export async function onOIDCClicked() {
+  const res = await API.get('/api/oauth/oidc/url');
+  const { success, message, data } = res.data;
+  if (!success) {
+    showError(message);
+    return;
+  }
+  window.open(data);
+}
+
 let channelModels = undefined;
 export async function loadChannelModels() {
   const res = await API.get('/api/models');
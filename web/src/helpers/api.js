@@ -8,7 +8,34 @@ export const API = axios.create({
 });
 
 API.interceptors.response.use(
-  (response) => response,
+  async (response) => {
+    // sensitive operations ask for a 2fa code, verify it once and retry the request
+    if (
+      response.data &&
+      response.data.success === false &&
+      response.data.require_2fa &&
+      !response.config._twoFARetried
+    ) {
+      const code = window.prompt('该操作需要两步验证，请输入验证码：');
+      if (!code) {
+        return response;
+      }
+      const res = await API.post('/api/user/2fa/verify', { code });
+      if (!res || !res.data.success) {
+        return res || response;
+      }
+      return API.request({ ...response.config, _twoFARetried: true });
+    }
+    // users required to enable 2fa can only reach the personal settings until they did
+    if (
+      response.data &&
+      response.data.require_2fa_setup &&
+      window.location.pathname !== '/setting'
+    ) {
+      window.location.href = '/setting';
+    }
+    return response;
+  },
   (error) => {
     showError(error);
   },
@@ -21,6 +21,14 @@ export function isRoot() {
   return user.role >= 100;
 }
 
+export function hasPermission(permission) {
+  let user = localStorage.getItem('user');
+  if (!user) return false;
+  user = JSON.parse(user);
+  if (!user.permissions) return user.role >= 10;
+  return user.permissions.includes(permission);
+}
+
 export function getSystemName() {
   let system_name = localStorage.getItem('system_name');
   if (!system_name) return 'New API';
@@ -0,0 +1,18 @@
+import React from 'react';
+import AuditLogsTable from '../../components/AuditLogsTable';
+import { Layout } from '@douyinfe/semi-ui';
+
+const Audit = () => (
+  <>
+    <Layout>
+      <Layout.Header>
+        <h3>审计日志</h3>
+      </Layout.Header>
+      <Layout.Content>
+        <AuditLogsTable />
+      </Layout.Content>
+    </Layout>
+  </>
+);
+
+export default Audit;
@@ -5,7 +5,7 @@ import { Button, Col, Form, Layout, Row, Spin } from '@douyinfe/semi-ui';
 import VChart from '@visactor/vchart';
 import {
   API,
-  isAdmin,
+  hasPermission,
   showError,
   timestamp2string,
   timestamp2string1,
@@ -38,7 +38,7 @@ const Detail = (props) => {
   });
   const { username, model_name, start_timestamp, end_timestamp, channel } =
     inputs;
-  const isAdminUser = isAdmin();
+  const isAdminUser = hasPermission('logs:read');
   const initialized = useRef(false);
   const [modelDataChart, setModelDataChart] = useState(null);
   const [modelDataPieChart, setModelDataPieChart] = useState(null);
@@ -15,6 +15,7 @@ export default function SettingsCreditLimit(props) {
     PreConsumedQuota: '',
     QuotaForInviter: '',
     QuotaForInvitee: '',
+    BatchDiscount: '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -142,6 +143,24 @@ export default function SettingsCreditLimit(props) {
                 />
               </Col>
             </Row>
+            <Row gutter={16}>
+              <Col span={6}>
+                <Form.InputNumber
+                  label={'批处理折扣'}
+                  field={'BatchDiscount'}
+                  step={0.1}
+                  min={0}
+                  extraText={'Batch API 请求的额度倍率'}
+                  placeholder={'例如：0.5'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      BatchDiscount: String(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
 
             <Row>
               <Button size='large' onClick={onSubmit}>
@@ -6,6 +6,7 @@ import {
   showError,
   showSuccess,
   showWarning,
+  verifyJSON,
 } from '../../../helpers';
 
 export default function SettingsMonitoring(props) {
@@ -15,6 +16,17 @@ export default function SettingsMonitoring(props) {
     QuotaRemindThreshold: '',
     AutomaticDisableChannelEnabled: false,
     AutomaticEnableChannelEnabled: false,
+    GroupChannelSelectMode: '',
+    GroupModelFallback: '',
+    ModelAliases: '',
+    GroupHedgeDelay: '',
+    CircuitBreakerEnabled: false,
+    CircuitBreakerThreshold: '',
+    CircuitBreakerCooldown: '',
+    CircuitBreakerHalfOpenRate: '',
+    StickyRoutingEnabled: false,
+    StickyRoutingHeader: '',
+    StickyRoutingTTL: '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -141,6 +153,248 @@ export default function SettingsMonitoring(props) {
                 />
               </Col>
             </Row>
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.Switch
+                  field={'CircuitBreakerEnabled'}
+                  label={'启用熔断'}
+                  extraText={'启用后失败的通道会被暂时熔断，而不是被自动禁用'}
+                  size='large'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      CircuitBreakerEnabled: value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.InputNumber
+                  label={'熔断失败次数'}
+                  step={1}
+                  min={1}
+                  suffix={'次'}
+                  extraText={'连续失败达到此次数后熔断'}
+                  placeholder={''}
+                  field={'CircuitBreakerThreshold'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      CircuitBreakerThreshold: String(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  label={'熔断冷却时间'}
+                  step={1}
+                  min={1}
+                  suffix={'秒'}
+                  extraText={'冷却后进入半开状态，放行少量请求探测'}
+                  placeholder={''}
+                  field={'CircuitBreakerCooldown'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      CircuitBreakerCooldown: String(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  label={'半开放行比例'}
+                  step={0.05}
+                  min={0}
+                  max={1}
+                  extraText={'半开状态下放行的请求比例，连续成功 3 次后恢复'}
+                  placeholder={''}
+                  field={'CircuitBreakerHalfOpenRate'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      CircuitBreakerHalfOpenRate: String(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={8}>
+                <Form.Switch
+                  field={'StickyRoutingEnabled'}
+                  label={'启用会话粘性路由'}
+                  extraText={'同一会话的请求会优先发往上次使用的渠道和密钥，以命中上游的提示缓存'}
+                  size='large'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      StickyRoutingEnabled: value,
+                    })
+                  }
+                />
+              </Col>
+              <Col span={8}>
+                <Form.Input
+                  label={'会话请求头'}
+                  extraText={'没有此请求头时使用请求的 user 字段，再没有则使用开头的消息识别会话'}
+                  placeholder={'X-Session-Id'}
+                  field={'StickyRoutingHeader'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      StickyRoutingHeader: value,
+                    })
+                  }
+                />
+              </Col>
+              <Col span={8}>
+                <Form.InputNumber
+                  label={'会话保持时间'}
+                  step={1}
+                  min={1}
+                  suffix={'秒'}
+                  extraText={'会话在此时间内没有请求后不再粘性路由'}
+                  placeholder={''}
+                  field={'StickyRoutingTTL'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      StickyRoutingTTL: String(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={16}>
+                <Form.TextArea
+                  label={'分组渠道选择模式'}
+                  extraText={
+                    'weight 按优先级和权重选择渠道，adaptive 会根据渠道近期的延迟和错误率调整权重，未配置的分组使用 weight'
+                  }
+                  placeholder={'为一个 JSON 文本，键为分组名称，值为 weight 或 adaptive'}
+                  field={'GroupChannelSelectMode'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        return verifyJSON(value);
+                      },
+                      message: '不是合法的 JSON 字符串',
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      GroupChannelSelectMode: value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={16}>
+                <Form.TextArea
+                  label={'分组模型回退链'}
+                  extraText={
+                    '当模型没有可用渠道或所有渠道均失败时，请求会依次由回退链中的模型处理，并按实际使用的模型计费'
+                  }
+                  placeholder={
+                    '为一个 JSON 文本，键为分组名称，值为模型到回退模型列表的映射，例如：{"default": {"gpt-4o": ["gpt-4-turbo", "claude-3-5-sonnet-20240620"]}}'
+                  }
+                  field={'GroupModelFallback'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        return verifyJSON(value);
+                      },
+                      message: '不是合法的 JSON 字符串',
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      GroupModelFallback: value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={16}>
+                <Form.TextArea
+                  label={'全局模型别名'}
+                  extraText={
+                    '请求别名时按其指向的模型选择渠道并计费，别名会出现在模型列表中'
+                  }
+                  placeholder={
+                    '为一个 JSON 文本，键为别名，值为实际模型名称，例如：{"smart": "gpt-4o", "fast": "gpt-4o-mini"}'
+                  }
+                  field={'ModelAliases'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        return verifyJSON(value);
+                      },
+                      message: '不是合法的 JSON 字符串',
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      ModelAliases: value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col span={16}>
+                <Form.TextArea
+                  label={'分组对冲请求阈值'}
+                  extraText={
+                    '首个渠道在阈值（毫秒）内未返回首字节时，并行请求另一个渠道，先返回的一方胜出，另一方被取消且不计费'
+                  }
+                  placeholder={
+                    '为一个 JSON 文本，键为分组名称，值为阈值毫秒数，例如：{"vip": 1500}'
+                  }
+                  field={'GroupHedgeDelay'}
+                  autosize={{ minRows: 4, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => {
+                        return verifyJSON(value);
+                      },
+                      message: '不是合法的 JSON 字符串',
+                    },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      GroupHedgeDelay: value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
             <Row>
               <Button size='large' onClick={onSubmit}>
                 保存监控设置
@@ -3,7 +3,7 @@ import { Layout, TabPane, Tabs } from '@douyinfe/semi-ui';
 import { useNavigate, useLocation } from 'react-router-dom';
 
 import SystemSetting from '../../components/SystemSetting';
-import { isRoot } from '../../helpers';
+import { hasPermission } from '../../helpers';
 import OtherSetting from '../../components/OtherSetting';
 import PersonalSetting from '../../components/PersonalSetting';
 import OperationSetting from '../../components/OperationSetting';
@@ -19,7 +19,7 @@ const Setting = () => {
     },
   ];
 
-  if (isRoot()) {
+  if (hasPermission('options:read')) {
     panes.push({
       tab: '运营设置',
       content: <OperationSetting />,
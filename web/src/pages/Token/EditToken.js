@@ -2,6 +2,7 @@ import React, { useEffect, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import {
   API,
+  copy,
   isMobile,
   showError,
   showSuccess,
@@ -15,10 +16,12 @@ import {
   Checkbox,
   DatePicker,
   Input,
+  Modal,
   Select,
   SideSheet,
   Space,
   Spin,
+  TextArea,
   Typography,
 } from '@douyinfe/semi-ui';
 import Title from '@douyinfe/semi-ui/lib/es/typography/title';
@@ -34,6 +37,11 @@ const EditToken = (props) => {
     unlimited_quota: false,
     model_limits_enabled: false,
     model_limits: [],
+    rpm_limit: 0,
+    tpm_limit: 0,
+    concurrency_limit: 0,
+    allow_ips: '',
+    scopes: [],
   };
   const [inputs, setInputs] = useState(originInputs);
   const {
@@ -43,9 +51,21 @@ const EditToken = (props) => {
     unlimited_quota,
     model_limits_enabled,
     model_limits,
+    rpm_limit,
+    tpm_limit,
+    concurrency_limit,
+    allow_ips,
+    scopes,
   } = inputs;
   // const [visible, setVisible] = useState(false);
   const [models, setModels] = useState({});
+  const scopeOptions = [
+    { label: '对话 (chat)', value: 'chat' },
+    { label: '向量 (embeddings)', value: 'embeddings' },
+    { label: '图像 (images)', value: 'images' },
+    { label: '音频 (audio)', value: 'audio' },
+    { label: 'Midjourney (midjourney)', value: 'midjourney' },
+  ];
   const navigate = useNavigate();
   const handleInputChange = (name, value) => {
     setInputs((inputs) => ({ ...inputs, [name]: value }));
@@ -99,6 +119,7 @@ const EditToken = (props) => {
       } else {
         data.model_limits = [];
       }
+      data.scopes = data.scopes ? data.scopes.split(',') : [];
       setInputs(data);
     } else {
       showError(message);
@@ -145,6 +166,40 @@ const EditToken = (props) => {
     return result;
   };
 
+  const showCreatedKeys = (keys) => {
+    Modal.info({
+      title: '请立即复制并妥善保存令牌',
+      content: (
+        <>
+          <Banner
+            type={'warning'}
+            description={'令牌只会显示这一次，关闭后将无法再次查看完整令牌。'}
+          ></Banner>
+          <TextArea
+            style={{ marginTop: 10 }}
+            value={keys}
+            autosize
+            readOnly
+          />
+          <Button
+            style={{ marginTop: 10 }}
+            onClick={async () => {
+              if (await copy(keys)) {
+                showSuccess('已复制到剪贴板！');
+              } else {
+                showError('无法复制到剪贴板，请手动复制');
+              }
+            }}
+          >
+            复制
+          </Button>
+        </>
+      ),
+      okText: '我已保存',
+      hasCancel: false,
+    });
+  };
+
   const submit = async () => {
     setLoading(true);
     if (isEdit) {
@@ -161,6 +216,11 @@ const EditToken = (props) => {
         localInputs.expired_time = Math.ceil(time / 1000);
       }
       localInputs.model_limits = localInputs.model_limits.join(',');
+      localInputs.scopes = localInputs.scopes.join(',');
+      localInputs.rpm_limit = parseInt(localInputs.rpm_limit) || 0;
+      localInputs.tpm_limit = parseInt(localInputs.tpm_limit) || 0;
+      localInputs.concurrency_limit =
+        parseInt(localInputs.concurrency_limit) || 0;
       let res = await API.put(`/api/token/`, {
         ...localInputs,
         id: parseInt(props.editingToken.id),
@@ -176,6 +236,7 @@ const EditToken = (props) => {
     } else {
       // 处理新增多个令牌的情况
       let successCount = 0; // 记录成功创建的令牌数量
+      let createdKeys = []; // 令牌只在创建时返回一次
       for (let i = 0; i < tokenCount; i++) {
         let localInputs = { ...inputs };
         if (i !== 0) {
@@ -194,11 +255,17 @@ const EditToken = (props) => {
           localInputs.expired_time = Math.ceil(time / 1000);
         }
         localInputs.model_limits = localInputs.model_limits.join(',');
+        localInputs.scopes = localInputs.scopes.join(',');
+        localInputs.rpm_limit = parseInt(localInputs.rpm_limit) || 0;
+        localInputs.tpm_limit = parseInt(localInputs.tpm_limit) || 0;
+        localInputs.concurrency_limit =
+          parseInt(localInputs.concurrency_limit) || 0;
         let res = await API.post(`/api/token/`, localInputs);
-        const { success, message } = res.data;
+        const { success, message, data } = res.data;
 
         if (success) {
           successCount++;
+          createdKeys.push(`sk-${data.key}`);
         } else {
           showError(message);
           break; // 如果创建失败，终止循环
@@ -206,9 +273,8 @@ const EditToken = (props) => {
       }
 
       if (successCount > 0) {
-        showSuccess(
-          `${successCount}个令牌创建成功，请在列表页面点击复制获取令牌！`,
-        );
+        showSuccess(`${successCount}个令牌创建成功！`);
+        showCreatedKeys(createdKeys.join('\n'));
         props.refresh();
         props.handleClose();
       }
@@ -404,6 +470,65 @@ const EditToken = (props) => {
             optionList={models}
             disabled={!model_limits_enabled}
           />
+          <Divider />
+          <div style={{ marginTop: 10 }}>
+            <Typography.Text>速率限制（0 表示不限制）</Typography.Text>
+          </div>
+          <Input
+            style={{ marginTop: 8 }}
+            label='每分钟请求数'
+            name='rpm_limit'
+            placeholder={'每分钟最多请求次数'}
+            onChange={(value) => handleInputChange('rpm_limit', value)}
+            value={rpm_limit}
+            autoComplete='off'
+            type='number'
+          />
+          <Input
+            style={{ marginTop: 8 }}
+            label='每分钟 Token 数'
+            name='tpm_limit'
+            placeholder={'每分钟最多消耗的 token 数'}
+            onChange={(value) => handleInputChange('tpm_limit', value)}
+            value={tpm_limit}
+            autoComplete='off'
+            type='number'
+          />
+          <Input
+            style={{ marginTop: 8 }}
+            label='并发请求数'
+            name='concurrency_limit'
+            placeholder={'同时进行的最大请求数'}
+            onChange={(value) => handleInputChange('concurrency_limit', value)}
+            value={concurrency_limit}
+            autoComplete='off'
+            type='number'
+          />
+          <Divider />
+          <div style={{ marginTop: 10 }}>
+            <Typography.Text>IP 白名单（留空表示不限制）</Typography.Text>
+          </div>
+          <TextArea
+            style={{ marginTop: 8 }}
+            name='allow_ips'
+            placeholder={'允许访问的 IP 或 CIDR，逗号或换行分隔，例如 10.0.0.0/8'}
+            onChange={(value) => handleInputChange('allow_ips', value)}
+            value={allow_ips}
+            autosize
+          />
+          <Divider />
+          <div style={{ marginTop: 10 }}>
+            <Typography.Text>接口范围（留空表示不限制）</Typography.Text>
+          </div>
+          <Select
+            style={{ marginTop: 8, width: '100%' }}
+            placeholder={'请选择该令牌可以调用的接口'}
+            name='scopes'
+            multiple
+            onChange={(value) => handleInputChange('scopes', value)}
+            value={scopes}
+            optionList={scopeOptions}
+          />
         </Spin>
       </SideSheet>
     </>
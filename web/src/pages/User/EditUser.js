@@ -1,6 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
-import { API, isMobile, showError, showSuccess } from '../../helpers';
+import { API, isMobile, isRoot, showError, showSuccess } from '../../helpers';
 import { renderQuota, renderQuotaWithPrompt } from '../../helpers/render';
 import Title from '@douyinfe/semi-ui/lib/es/typography/title';
 import {
@@ -25,10 +25,12 @@ const EditUser = (props) => {
     display_name: '',
     password: '',
     github_id: '',
+    oidc_id: '',
     wechat_id: '',
     email: '',
     quota: 0,
     group: 'default',
+    admin_role: '',
   });
   const [groupOptions, setGroupOptions] = useState([]);
   const {
@@ -36,6 +38,7 @@ const EditUser = (props) => {
     display_name,
     password,
     github_id,
+    oidc_id,
     wechat_id,
     telegram_id,
     email,
@@ -202,6 +205,22 @@ const EditUser = (props) => {
                 autoComplete='new-password'
                 optionList={groupOptions}
               />
+              {isRoot() && inputs.role >= 10 && inputs.role < 100 && (
+                <>
+                  <div style={{ marginTop: 20 }}>
+                    <Typography.Text>管理角色</Typography.Text>
+                  </div>
+                  <Input
+                    name='admin_role'
+                    placeholder={
+                      '留空则拥有除系统设置外的全部管理权限，可用角色在系统设置中配置'
+                    }
+                    onChange={(value) => handleInputChange('admin_role', value)}
+                    value={inputs.admin_role}
+                    autoComplete='new-password'
+                  />
+                </>
+              )}
               <div style={{ marginTop: 20 }}>
                 <Typography.Text>{`剩余额度${renderQuotaWithPrompt(quota)}`}</Typography.Text>
               </div>
@@ -229,6 +248,16 @@ const EditUser = (props) => {
             placeholder='此项只读，需要用户通过个人设置页面的相关绑定按钮进行绑定，不可直接修改'
             readonly
           />
+          <div style={{ marginTop: 20 }}>
+            <Typography.Text>已绑定的 OIDC 账户</Typography.Text>
+          </div>
+          <Input
+            name='oidc_id'
+            value={oidc_id}
+            autoComplete='new-password'
+            placeholder='此项只读，需要用户通过个人设置页面的相关绑定按钮进行绑定，不可直接修改'
+            readonly
+          />
           <div style={{ marginTop: 20 }}>
             <Typography.Text>已绑定的微信账户</Typography.Text>
           </div>